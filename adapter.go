@@ -0,0 +1,256 @@
+package cidranger
+
+import (
+	"iter"
+	"net"
+	"net/netip"
+	"reflect"
+
+	rnet "github.com/yl2chen/cidranger/net"
+)
+
+// entryRanger adapts a TypedRanger storing RangerEntry values to the Ranger
+// interface, using the network reported by each entry as its key.
+type entryRanger struct {
+	ranger TypedRanger[RangerEntry]
+}
+
+// newEntryRanger returns a Ranger backed by given TypedRanger.
+func newEntryRanger(ranger TypedRanger[RangerEntry]) Ranger {
+	return &entryRanger{ranger: ranger}
+}
+
+// Insert inserts a RangerEntry into ranger.
+func (e *entryRanger) Insert(entry RangerEntry) error {
+	return e.ranger.Insert(entry.Network(), entry)
+}
+
+// InsertPrefix inserts a basic RangerEntry for given prefix into ranger.
+func (e *entryRanger) InsertPrefix(prefix netip.Prefix) error {
+	network := rnet.NewNetworkFromPrefix(prefix)
+	if network.Number == nil {
+		return ErrInvalidNetworkInput
+	}
+	return e.ranger.InsertPrefix(prefix, NewBasicRangerEntry(network.IPNet))
+}
+
+// Upsert stores the RangerEntry returned by fn for given network.
+func (e *entryRanger) Upsert(network net.IPNet, fn func(old RangerEntry, exists bool) RangerEntry) error {
+	return e.ranger.Upsert(network, fn)
+}
+
+// Swap inserts a RangerEntry into ranger, returning the RangerEntry it
+// replaced.
+func (e *entryRanger) Swap(entry RangerEntry) (RangerEntry, bool, error) {
+	return e.ranger.Swap(entry.Network(), entry)
+}
+
+// Remove removes a RangerEntry identified by given network from ranger.
+func (e *entryRanger) Remove(network net.IPNet) (RangerEntry, error) {
+	entry, _, err := e.ranger.Remove(network)
+	return entry, err
+}
+
+// RemovePrefix removes a RangerEntry identified by given prefix from ranger.
+func (e *entryRanger) RemovePrefix(prefix netip.Prefix) (RangerEntry, error) {
+	entry, _, err := e.ranger.RemovePrefix(prefix)
+	return entry, err
+}
+
+// CompareAndRemove removes the RangerEntry stored for given network only if it
+// is expected.
+func (e *entryRanger) CompareAndRemove(network net.IPNet, expected RangerEntry) (bool, error) {
+	_, removed, err := e.ranger.RemoveIf(network, func(entry RangerEntry) bool {
+		return sameEntry(entry, expected)
+	})
+	return removed, err
+}
+
+// sameEntry reports whether entry == expected, without panicking on entries
+// that are not comparable, such as structs holding a slice, which never
+// match.
+func sameEntry(entry, expected RangerEntry) bool {
+	if entry == nil || expected == nil {
+		return entry == expected
+	}
+	if reflect.TypeOf(entry) != reflect.TypeOf(expected) ||
+		!reflect.ValueOf(entry).Comparable() || !reflect.ValueOf(expected).Comparable() {
+		return false
+	}
+	return entry == expected
+}
+
+// RemoveCovered removes all RangerEntry(s) covered by given network from
+// ranger.
+func (e *entryRanger) RemoveCovered(network net.IPNet) ([]RangerEntry, error) {
+	entries, err := e.ranger.RemoveCovered(network)
+	return entryValues(entries), err
+}
+
+// Clear removes all RangerEntry(s) from ranger.
+func (e *entryRanger) Clear() {
+	e.ranger.Clear()
+}
+
+// Get returns the RangerEntry stored for exactly given network.
+func (e *entryRanger) Get(network net.IPNet) (RangerEntry, bool, error) {
+	return e.ranger.Get(network)
+}
+
+// GetPrefix returns the RangerEntry stored for exactly given prefix.
+func (e *entryRanger) GetPrefix(prefix netip.Prefix) (RangerEntry, bool, error) {
+	return e.ranger.GetPrefix(prefix)
+}
+
+// Contains returns bool indicating whether given ip is contained by any
+// network in ranger.
+func (e *entryRanger) Contains(ip net.IP) (bool, error) {
+	return e.ranger.Contains(ip)
+}
+
+// ContainsAddr returns bool indicating whether given addr is contained by any
+// network in ranger.
+func (e *entryRanger) ContainsAddr(addr netip.Addr) (bool, error) {
+	return e.ranger.ContainsAddr(addr)
+}
+
+// ContainingNetworks returns all RangerEntry(s) that given ip contained in.
+func (e *entryRanger) ContainingNetworks(ip net.IP) ([]RangerEntry, error) {
+	entries, err := e.ranger.ContainingNetworks(ip)
+	return entryValues(entries), err
+}
+
+// ContainingPrefixes returns all RangerEntry(s) that given addr contained in.
+func (e *entryRanger) ContainingPrefixes(addr netip.Addr) ([]RangerEntry, error) {
+	entries, err := e.ranger.ContainingPrefixes(addr)
+	return entryValues(entries), err
+}
+
+// LongestMatch returns the most specific RangerEntry containing given ip.
+func (e *entryRanger) LongestMatch(ip net.IP) (RangerEntry, bool, error) {
+	entry, found, err := e.ranger.LongestMatch(ip)
+	return entry.Value, found, err
+}
+
+// LongestMatchAddr returns the most specific RangerEntry containing given
+// addr.
+func (e *entryRanger) LongestMatchAddr(addr netip.Addr) (RangerEntry, bool, error) {
+	entry, found, err := e.ranger.LongestMatchAddr(addr)
+	return entry.Value, found, err
+}
+
+// ShortestMatch returns the least specific RangerEntry containing given ip.
+func (e *entryRanger) ShortestMatch(ip net.IP) (RangerEntry, bool, error) {
+	entry, found, err := e.ranger.ShortestMatch(ip)
+	return entry.Value, found, err
+}
+
+// ShortestMatchAddr returns the least specific RangerEntry containing given
+// addr.
+func (e *entryRanger) ShortestMatchAddr(addr netip.Addr) (RangerEntry, bool, error) {
+	entry, found, err := e.ranger.ShortestMatchAddr(addr)
+	return entry.Value, found, err
+}
+
+// CoveredNetworks returns the list of RangerEntry(s) the given ipnet
+// covers.  That is, the networks that are completely subsumed by the
+// specified network.
+func (e *entryRanger) CoveredNetworks(network net.IPNet) ([]RangerEntry, error) {
+	entries, err := e.ranger.CoveredNetworks(network)
+	return entryValues(entries), err
+}
+
+// CoveredPrefixes returns the list of RangerEntry(s) the given prefix covers.
+func (e *entryRanger) CoveredPrefixes(prefix netip.Prefix) ([]RangerEntry, error) {
+	entries, err := e.ranger.CoveredPrefixes(prefix)
+	return entryValues(entries), err
+}
+
+// CoveringNetworks returns the list of RangerEntry(s) that cover the given
+// ipnet.
+func (e *entryRanger) CoveringNetworks(network net.IPNet) ([]RangerEntry, error) {
+	entries, err := e.ranger.CoveringNetworks(network)
+	return entryValues(entries), err
+}
+
+// CoveringPrefixes returns the list of RangerEntry(s) that cover the given
+// prefix.
+func (e *entryRanger) CoveringPrefixes(prefix netip.Prefix) ([]RangerEntry, error) {
+	entries, err := e.ranger.CoveringPrefixes(prefix)
+	return entryValues(entries), err
+}
+
+// OverlappingNetworks returns the list of RangerEntry(s) that either cover or
+// are covered by the given ipnet.
+func (e *entryRanger) OverlappingNetworks(network net.IPNet) ([]RangerEntry, error) {
+	entries, err := e.ranger.OverlappingNetworks(network)
+	return entryValues(entries), err
+}
+
+// OverlappingPrefixes returns the list of RangerEntry(s) that either cover or
+// are covered by the given prefix.
+func (e *entryRanger) OverlappingPrefixes(prefix netip.Prefix) ([]RangerEntry, error) {
+	entries, err := e.ranger.OverlappingPrefixes(prefix)
+	return entryValues(entries), err
+}
+
+// Walk calls fn for every RangerEntry in ranger until fn returns false.
+func (e *entryRanger) Walk(fn func(RangerEntry) bool) {
+	e.ranger.Walk(func(entry Entry[RangerEntry]) bool {
+		return fn(entry.Value)
+	})
+}
+
+// All returns an iterator over every RangerEntry in ranger.
+func (e *entryRanger) All() iter.Seq[RangerEntry] {
+	return e.Walk
+}
+
+// Backward returns an iterator over every RangerEntry in ranger in reverse
+// order.
+func (e *entryRanger) Backward() iter.Seq[RangerEntry] {
+	return func(yield func(RangerEntry) bool) {
+		for entry := range e.ranger.Backward() {
+			if !yield(entry.Value) {
+				return
+			}
+		}
+	}
+}
+
+// Next returns the RangerEntry following given network.
+func (e *entryRanger) Next(network net.IPNet) (RangerEntry, bool, error) {
+	entry, found, err := e.ranger.Next(network)
+	return entry.Value, found, err
+}
+
+// Prev returns the RangerEntry preceding given network.
+func (e *entryRanger) Prev(network net.IPNet) (RangerEntry, bool, error) {
+	entry, found, err := e.ranger.Prev(network)
+	return entry.Value, found, err
+}
+
+// EntriesBetween returns the RangerEntry(s) overlapping the address range from
+// start to end inclusive.
+func (e *entryRanger) EntriesBetween(start, end net.IP) ([]RangerEntry, error) {
+	entries, err := e.ranger.EntriesBetween(start, end)
+	return entryValues(entries), err
+}
+
+// Len returns number of networks in ranger.
+func (e *entryRanger) Len() int {
+	return e.ranger.Len()
+}
+
+// entryValues returns the values of given entries, preserving nil-ness of the
+// input slice.
+func entryValues(entries []Entry[RangerEntry]) []RangerEntry {
+	if entries == nil {
+		return nil
+	}
+	values := make([]RangerEntry, len(entries))
+	for i, entry := range entries {
+		values[i] = entry.Value
+	}
+	return values
+}
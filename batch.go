@@ -0,0 +1,152 @@
+package cidranger
+
+import (
+	"net"
+	"net/netip"
+
+	rnet "github.com/yl2chen/cidranger/net"
+)
+
+// Batch stages insertions and removals to be committed to a Ranger all at
+// once.  Commit validates every staged operation before applying any, and
+// undoes the applied ones should one still fail, so a ranger is either left
+// as it was or has every operation applied.
+//
+// Operations are applied in the order they were staged, a later operation
+// sees the effect of earlier ones.  Removing a network without entry is not
+// an error.
+//
+// The loaders of the subpackages, such as rir.Load or mmdb.Reader.Import,
+// stage their whole input in a single Batch, so that input failing to load
+// leaves a ranger unchanged and concurrent readers see it loaded at once.
+type Batch struct {
+	ops []batchOp
+}
+
+type batchOp struct {
+	network net.IPNet
+	entry   RangerEntry // nil for removals
+}
+
+// batchCommitter is implemented by rangers that commit a batch on their own,
+// so that concurrent readers never see it partially applied.
+type batchCommitter interface {
+	commitBatch(b *Batch) error
+}
+
+// NewBatch returns an empty Batch.
+func NewBatch() *Batch {
+	return &Batch{}
+}
+
+// Insert stages the insertion of given RangerEntry.
+func (b *Batch) Insert(entry RangerEntry) {
+	var network net.IPNet
+	if entry != nil {
+		network = entry.Network()
+	}
+	b.ops = append(b.ops, batchOp{network: network, entry: entry})
+}
+
+// InsertPrefix stages the insertion of a basic RangerEntry for given prefix.
+func (b *Batch) InsertPrefix(prefix netip.Prefix) {
+	network := rnet.NewNetworkFromPrefix(prefix).IPNet
+	b.ops = append(b.ops, batchOp{network: network, entry: NewBasicRangerEntry(network)})
+}
+
+// Remove stages the removal of the RangerEntry identified by given network.
+func (b *Batch) Remove(network net.IPNet) {
+	b.ops = append(b.ops, batchOp{network: network})
+}
+
+// RemovePrefix stages the removal of the RangerEntry identified by given
+// prefix.
+func (b *Batch) RemovePrefix(prefix netip.Prefix) {
+	b.ops = append(b.ops, batchOp{network: rnet.NewNetworkFromPrefix(prefix).IPNet})
+}
+
+// Len returns the number of staged operations.
+func (b *Batch) Len() int {
+	return len(b.ops)
+}
+
+// Reset discards every staged operation.
+func (b *Batch) Reset() {
+	b.ops = b.ops[:0]
+}
+
+// Commit applies the staged operations to given ranger, atomically or not at
+// all.  Rangers returned by NewConcurrentRanger and NewSnapshotPCTrieRanger
+// only ever expose the state before or after the whole batch to concurrent
+// readers.  The batch is left untouched and may be committed again.
+func (b *Batch) Commit(ranger Ranger) error {
+	for _, op := range b.ops {
+		if err := validateBatchOp(op); err != nil {
+			return err
+		}
+	}
+	return b.commit(ranger)
+}
+
+func (b *Batch) commit(ranger Ranger) error {
+	if committer, ok := ranger.(batchCommitter); ok {
+		return committer.commitBatch(b)
+	}
+	return b.apply(ranger)
+}
+
+// apply applies the staged operations to ranger in order, should one fail,
+// the ones already applied are undone in reverse order.
+func (b *Batch) apply(ranger Ranger) error {
+	// Each applied operation is undone by restoring the entry previously
+	// stored for its network, or removing the network if there was none.
+	return applyOrUndo(b.ops, func(op batchOp) (batchOp, error) {
+		var previous RangerEntry
+		var err error
+		if op.entry != nil {
+			previous, _, err = ranger.Swap(op.entry)
+		} else {
+			previous, err = ranger.Remove(op.network)
+		}
+		return batchOp{network: op.network, entry: previous}, err
+	}, func(op batchOp) {
+		if op.entry != nil {
+			ranger.Insert(op.entry)
+		} else {
+			ranger.Remove(op.network)
+		}
+	})
+}
+
+// applyOrUndo applies ops in order, should one fail, the ones already
+// applied are undone in reverse order, each passed what apply returned for
+// it.
+func applyOrUndo[O, U any](ops []O, apply func(O) (U, error), undo func(U)) error {
+	applied := make([]U, 0, len(ops))
+	for _, op := range ops {
+		u, err := apply(op)
+		if err != nil {
+			for i := len(applied) - 1; i >= 0; i-- {
+				undo(applied[i])
+			}
+			return err
+		}
+		applied = append(applied, u)
+	}
+	return nil
+}
+
+// validateBatchOp returns the error inserting or removing the network of op
+// would fail with, checked upfront.
+func validateBatchOp(op batchOp) error {
+	bits := 8 * net.IPv6len
+	if op.network.IP.To4() != nil {
+		bits = 8 * net.IPv4len
+	} else if op.network.IP.To16() == nil {
+		return ErrInvalidNetworkNumberInput
+	}
+	if _, maskBits := op.network.Mask.Size(); maskBits != bits {
+		return ErrInvalidNetworkInput
+	}
+	return nil
+}
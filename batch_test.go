@@ -0,0 +1,140 @@
+package cidranger
+
+import (
+	"net"
+	"net/netip"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchCommit(t *testing.T) {
+	rangers := map[string]Ranger{
+		"pctrie":     NewPCTrieRanger(),
+		"brute":      newBruteRanger(),
+		"concurrent": NewConcurrentRanger(NewPCTrieRanger()),
+		"snapshot":   NewSnapshotPCTrieRanger(),
+	}
+	for name, ranger := range rangers {
+		assert.NoError(t, ranger.InsertPrefix(netip.MustParsePrefix("10.0.0.0/8")), name)
+		assert.NoError(t, ranger.InsertPrefix(netip.MustParsePrefix("172.16.0.0/12")), name)
+
+		batch := NewBatch()
+		batch.InsertPrefix(netip.MustParsePrefix("192.168.0.0/16"))
+		batch.InsertPrefix(netip.MustParsePrefix("8000::/16"))
+		batch.RemovePrefix(netip.MustParsePrefix("10.0.0.0/8"))
+		batch.Remove(*parseCIDRUnsafe("203.0.113.0/24"))
+		batch.RemovePrefix(netip.MustParsePrefix("8000::/16"))
+		assert.Equal(t, 5, batch.Len(), name)
+		assert.NoError(t, batch.Commit(ranger), name)
+
+		var networks []string
+		for entry := range ranger.All() {
+			network := entry.Network()
+			networks = append(networks, network.String())
+		}
+		assert.Equal(t, []string{"172.16.0.0/12", "192.168.0.0/16"}, networks, name)
+	}
+}
+
+func TestBatchValidation(t *testing.T) {
+	for name, ranger := range map[string]Ranger{
+		"pctrie":   NewPCTrieRanger(),
+		"snapshot": NewSnapshotPCTrieRanger(),
+	} {
+		assert.NoError(t, ranger.InsertPrefix(netip.MustParsePrefix("10.0.0.0/8")), name)
+
+		batch := NewBatch()
+		batch.RemovePrefix(netip.MustParsePrefix("10.0.0.0/8"))
+		batch.InsertPrefix(netip.MustParsePrefix("192.168.0.0/16"))
+		batch.Insert(NewBasicRangerEntry(net.IPNet{IP: net.IP{1, 2, 3}, Mask: net.CIDRMask(24, 32)}))
+		assert.Equal(t, ErrInvalidNetworkNumberInput, batch.Commit(ranger), name)
+		assert.Equal(t, 1, ranger.Len(), name)
+
+		batch.Reset()
+		batch.Insert(NewBasicRangerEntry(net.IPNet{IP: net.ParseIP("1.2.3.0"), Mask: net.CIDRMask(24, 128)}))
+		assert.Equal(t, ErrInvalidNetworkInput, batch.Commit(ranger), name)
+		batch.Reset()
+		batch.InsertPrefix(netip.Prefix{})
+		assert.Equal(t, ErrInvalidNetworkNumberInput, batch.Commit(ranger), name)
+
+		contains, err := ranger.Contains(net.ParseIP("10.0.0.1"))
+		assert.NoError(t, err)
+		assert.True(t, contains, name)
+	}
+}
+
+// failingRanger fails every Swap once its budget is exhausted.
+type failingRanger struct {
+	Ranger
+	swaps int
+}
+
+func (f *failingRanger) Swap(entry RangerEntry) (RangerEntry, bool, error) {
+	if f.swaps == 0 {
+		return nil, false, ErrInvalidNetworkInput
+	}
+	f.swaps--
+	return f.Ranger.Swap(entry)
+}
+
+func TestBatchRollback(t *testing.T) {
+	ranger := &failingRanger{Ranger: NewPCTrieRanger(), swaps: 2}
+	original := &taggedRangerEntry{*parseCIDRUnsafe("10.0.0.0/8"), []string{"original"}}
+	assert.NoError(t, ranger.Insert(original))
+	assert.NoError(t, ranger.InsertPrefix(netip.MustParsePrefix("172.16.0.0/12")))
+
+	batch := NewBatch()
+	batch.Insert(&taggedRangerEntry{*parseCIDRUnsafe("10.0.0.0/8"), []string{"update"}})
+	batch.RemovePrefix(netip.MustParsePrefix("172.16.0.0/12"))
+	batch.InsertPrefix(netip.MustParsePrefix("192.168.0.0/16"))
+	batch.InsertPrefix(netip.MustParsePrefix("8000::/16"))
+	assert.Equal(t, ErrInvalidNetworkInput, batch.Commit(ranger))
+
+	// Every applied operation was undone.
+	assert.Equal(t, 2, ranger.Len())
+	entry, _, err := ranger.Get(*parseCIDRUnsafe("10.0.0.0/8"))
+	assert.NoError(t, err)
+	assert.Same(t, original, entry)
+	_, found, err := ranger.Get(*parseCIDRUnsafe("172.16.0.0/12"))
+	assert.NoError(t, err)
+	assert.True(t, found)
+	_, found, err = ranger.Get(*parseCIDRUnsafe("192.168.0.0/16"))
+	assert.NoError(t, err)
+	assert.False(t, found)
+}
+
+func TestBatchCommitIsAtomicForReaders(t *testing.T) {
+	for name, ranger := range map[string]Ranger{
+		"concurrent": NewConcurrentRanger(NewPCTrieRanger()),
+		"snapshot":   NewSnapshotPCTrieRanger(),
+	} {
+		batch := NewBatch()
+		for i := 0; i < 64; i++ {
+			batch.InsertPrefix(netip.PrefixFrom(netip.AddrFrom4([4]byte{10, byte(i), 0, 0}), 16))
+		}
+
+		done := make(chan struct{})
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				if n := ranger.Len(); n != 0 && n != 64 {
+					t.Errorf("%s: observed partially committed batch of %d entries", name, n)
+					return
+				}
+			}
+		}()
+		assert.NoError(t, batch.Commit(ranger), name)
+		close(done)
+		wg.Wait()
+		assert.Equal(t, 64, ranger.Len(), name)
+	}
+}
@@ -0,0 +1,407 @@
+package cidranger
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"net"
+
+	rnet "github.com/yl2chen/cidranger/net"
+)
+
+// The binary format holds the nodes of the IPv4 and then IPv6 path compressed
+// tries as is, so decoding rebuilds the tries without inserting a single
+// network:
+//
+//	magic    "CIDR"
+//	version  byte, binaryVersion
+//	tries    IPv4 trie then IPv6 trie, each as entry count (uvarint) and root node
+//	checksum CRC-32 (IEEE) of all preceding bytes, big endian
+//
+// with every node encoded in pre-order as:
+//
+//	flags           byte, flagHasValue if node holds an entry
+//	numBitsSkipped  uvarint
+//	numBitsHandled  uvarint
+//	address         4 or 16 bytes, the node network masked to numBitsSkipped
+//	value           if flagHasValue, length (uvarint) and codec encoded value
+//	children        count (uvarint), then index (uvarint) and node of each
+const (
+	binaryMagic   = "CIDR"
+	binaryVersion = 1
+
+	flagHasValue = 1 << 0
+
+	// slotsPerByte bounds the children allocated while decoding to that many
+	// per byte of input, on top of the children of a single maximal node.
+	// Level compressed nodes use at least a quarter of their children, each
+	// taking several bytes, so valid input stays well below it.
+	slotsPerByte = 4
+)
+
+// ErrInvalidBinaryInput is returned upon decoding corrupted or truncated data.
+var ErrInvalidBinaryInput = fmt.Errorf("Invalid binary input")
+
+// ErrUnsupportedBinaryVersion is returned upon decoding data written in an
+// unknown format version.
+var ErrUnsupportedBinaryVersion = fmt.Errorf("Unsupported binary format version")
+
+// ErrUnsupportedEntry is returned by BasicEntryCodec upon encoding an entry
+// that is not a basic RangerEntry.
+var ErrUnsupportedEntry = fmt.Errorf("Unsupported entry type")
+
+// Codec encodes the values stored in a ranger, see MarshalTypedRanger.
+type Codec[V any] interface {
+	// MarshalValue returns the encoding of value stored for network.
+	MarshalValue(network net.IPNet, value V) ([]byte, error)
+	// UnmarshalValue decodes the value stored for network from data.
+	UnmarshalValue(network net.IPNet, data []byte) (V, error)
+}
+
+// BasicEntryCodec is the Codec of basic RangerEntry(s), which have no payload
+// besides their network.  It fails with ErrUnsupportedEntry on custom entries,
+// those need a Codec of their own to be marshaled.
+var BasicEntryCodec Codec[RangerEntry] = basicEntryCodec{}
+
+type basicEntryCodec struct{}
+
+func (basicEntryCodec) MarshalValue(network net.IPNet, entry RangerEntry) ([]byte, error) {
+	if _, ok := entry.(*basicRangerEntry); !ok {
+		return nil, ErrUnsupportedEntry
+	}
+	return nil, nil
+}
+
+func (basicEntryCodec) UnmarshalValue(network net.IPNet, data []byte) (RangerEntry, error) {
+	return NewBasicRangerEntry(network), nil
+}
+
+// StringCodec is the Codec of string values, stored as is.
+var StringCodec Codec[string] = stringCodec{}
+
+type stringCodec struct{}
+
+func (stringCodec) MarshalValue(network net.IPNet, value string) ([]byte, error) {
+	return []byte(value), nil
+}
+
+func (stringCodec) UnmarshalValue(network net.IPNet, data []byte) (string, error) {
+	return string(data), nil
+}
+
+// MarshalRanger encodes the entries of ranger using codec.  Path compressed
+// trie rangers are encoded as is, any other ranger is first copied into one.
+func MarshalRanger(ranger Ranger, codec Codec[RangerEntry]) ([]byte, error) {
+	typed, err := typedRangerOf(ranger)
+	if err != nil {
+		return nil, err
+	}
+	return MarshalTypedRanger(typed, codec)
+}
+
+// UnmarshalRanger returns a path compressed trie Ranger decoded from data
+// produced by MarshalRanger, using codec to decode the entries.
+func UnmarshalRanger(data []byte, codec Codec[RangerEntry]) (Ranger, error) {
+	ranger, err := UnmarshalTypedRanger(data, codec)
+	if err != nil {
+		return nil, err
+	}
+	return newEntryRanger(ranger), nil
+}
+
+// MarshalTypedRanger encodes the entries of ranger using codec, see
+// MarshalRanger.
+func MarshalTypedRanger[V any](ranger TypedRanger[V], codec Codec[V]) ([]byte, error) {
+	v, err := prefixTriesOf(ranger)
+	if err != nil {
+		return nil, err
+	}
+	return v.appendBinary([]byte(binaryMagic), codec)
+}
+
+// UnmarshalTypedRanger returns a path compressed trie TypedRanger decoded from
+// data produced by MarshalTypedRanger, using codec to decode the values.
+func UnmarshalTypedRanger[V any](data []byte, codec Codec[V]) (TypedRanger[V], error) {
+	v := &versionedRanger[V]{}
+	if err := v.unmarshalBinary(data, codec); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler using BasicEntryCodec.
+func (e *entryRanger) MarshalBinary() ([]byte, error) {
+	return MarshalRanger(e, BasicEntryCodec)
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler using BasicEntryCodec,
+// replacing every entry of ranger.  Only rangers returned by NewPCTrieRanger
+// and UnmarshalRanger can be replaced, others fail with ErrReadOnlyRanger.
+func (e *entryRanger) UnmarshalBinary(data []byte) error {
+	if v, ok := e.ranger.(*versionedRanger[RangerEntry]); !ok || !v.isPrefixTrie() {
+		return ErrReadOnlyRanger
+	}
+	ranger, err := UnmarshalTypedRanger(data, BasicEntryCodec)
+	if err != nil {
+		return err
+	}
+	e.ranger = ranger
+	return nil
+}
+
+// typedRangerOf returns the TypedRanger backing ranger, or a copy of ranger
+// into path compressed tries if it has none.
+func typedRangerOf(ranger Ranger) (TypedRanger[RangerEntry], error) {
+	switch r := ranger.(type) {
+	case *entryRanger:
+		return r.ranger, nil
+	case *snapshotEntryRanger:
+		return r.trie, nil
+	}
+	typed := NewTypedPCTrieRanger[RangerEntry]()
+	for entry := range ranger.All() {
+		if err := typed.Insert(entry.Network(), entry); err != nil {
+			return nil, err
+		}
+	}
+	return typed, nil
+}
+
+// prefixTriesOf returns the path compressed tries of ranger, or a copy of
+// ranger into new ones if it is not backed by any.
+func prefixTriesOf[V any](ranger TypedRanger[V]) (*versionedRanger[V], error) {
+	if s, ok := ranger.(*snapshotTrie[V]); ok {
+		ranger = s.current.Load()
+	}
+	if v, ok := ranger.(*versionedRanger[V]); ok && v.isPrefixTrie() {
+		return v, nil
+	}
+	v := NewTypedPCTrieRanger[V]().(*versionedRanger[V])
+	for entry := range ranger.All() {
+		if err := v.Insert(entry.Network, entry.Value); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+func (v *versionedRanger[V]) isPrefixTrie() bool {
+	_, ok4 := v.ipV4Ranger.(*prefixTrie[V])
+	_, ok6 := v.ipV6Ranger.(*prefixTrie[V])
+	return ok4 && ok6
+}
+
+// appendBinary appends the format version, both tries and the checksum of
+// dst with them to dst.
+func (v *versionedRanger[V]) appendBinary(dst []byte, codec Codec[V]) ([]byte, error) {
+	dst = append(dst, binaryVersion)
+	for _, ranger := range []TypedRanger[V]{v.ipV4Ranger, v.ipV6Ranger} {
+		trie := ranger.(*prefixTrie[V])
+		dst = binary.AppendUvarint(dst, uint64(trie.size))
+		var err error
+		if dst, err = trie.appendBinary(dst, codec); err != nil {
+			return nil, err
+		}
+	}
+	return binary.BigEndian.AppendUint32(dst, crc32.ChecksumIEEE(dst)), nil
+}
+
+func (v *versionedRanger[V]) unmarshalBinary(data []byte, codec Codec[V]) error {
+	if len(data) < len(binaryMagic)+1+4 || string(data[:len(binaryMagic)]) != binaryMagic {
+		return ErrInvalidBinaryInput
+	}
+	body, checksum := data[:len(data)-4], data[len(data)-4:]
+	if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(checksum) {
+		return ErrInvalidBinaryInput
+	}
+	if body[len(binaryMagic)] != binaryVersion {
+		return ErrUnsupportedBinaryVersion
+	}
+	tries := body[len(binaryMagic)+1:]
+	d := &trieDecoder{data: tries, slots: slotsPerByte*len(tries) + 1<<maxBitsHandled}
+	var roots [2]*prefixTrie[V]
+	for i, version := range []rnet.IPVersion{rnet.IPv4, rnet.IPv6} {
+		size, err := d.uvarint()
+		if err != nil {
+			return err
+		}
+		var count int
+		root, err := decodeTrie(d, version, codec, &count)
+		if err != nil {
+			return err
+		}
+		if root.numBitsSkipped != 0 || uint64(count) != size {
+			return ErrInvalidBinaryInput
+		}
+		root.size = count
+		roots[i] = root
+	}
+	if len(d.data) != 0 {
+		return ErrInvalidBinaryInput
+	}
+	v.ipV4Ranger, v.ipV6Ranger = roots[0], roots[1]
+	return nil
+}
+
+func (p *prefixTrie[V]) appendBinary(dst []byte, codec Codec[V]) ([]byte, error) {
+	var flags byte
+	if p.hasEntry() {
+		flags |= flagHasValue
+	}
+	dst = append(dst, flags)
+	dst = binary.AppendUvarint(dst, uint64(p.numBitsSkipped))
+	dst = binary.AppendUvarint(dst, uint64(p.numBitsHandled))
+	ip := p.network.IP
+	if p.totalNumberOfBits() == 8*net.IPv4len {
+		ip = ip.To4()
+	} else {
+		ip = ip.To16()
+	}
+	dst = append(dst, ip...)
+	if p.hasEntry() {
+		value, err := codec.MarshalValue(p.network.IPNet, p.value)
+		if err != nil {
+			return nil, err
+		}
+		dst = binary.AppendUvarint(dst, uint64(len(value)))
+		dst = append(dst, value...)
+	}
+	// Children are counted rather than taken from numChildren, which is not
+	// maintained by persistent tries.
+	numChildren := 0
+	for _, child := range p.children {
+		if child != nil {
+			numChildren++
+		}
+	}
+	dst = binary.AppendUvarint(dst, uint64(numChildren))
+	for i, child := range p.children {
+		if child == nil {
+			continue
+		}
+		dst = binary.AppendUvarint(dst, uint64(i))
+		var err error
+		if dst, err = child.appendBinary(dst, codec); err != nil {
+			return nil, err
+		}
+	}
+	return dst, nil
+}
+
+// decodeTrie decodes a node and its children, adding the number of entries
+// decoded to count.  Children are only attached once complete, so that the
+// population counters of their parent account for their entry.
+func decodeTrie[V any](d *trieDecoder, version rnet.IPVersion, codec Codec[V], count *int) (*prefixTrie[V], error) {
+	flags, err := d.bytes(1)
+	if err != nil {
+		return nil, err
+	}
+	numBitsSkipped, err := d.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	numBitsHandled, err := d.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	bits := uint64(8 * net.IPv4len)
+	if version == rnet.IPv6 {
+		bits = 8 * net.IPv6len
+	}
+	if flags[0]&^flagHasValue != 0 || numBitsSkipped > bits || numBitsHandled < 1 ||
+		numBitsHandled > maxBitsHandled || numBitsSkipped+numBitsHandled > bits && numBitsSkipped != bits {
+		return nil, ErrInvalidBinaryInput
+	}
+	ip, err := d.bytes(int(bits / 8))
+	if err != nil {
+		return nil, err
+	}
+	ipNet := net.IPNet{
+		IP:   append(net.IP(nil), ip...),
+		Mask: net.CIDRMask(int(numBitsSkipped), int(bits)),
+	}
+	if !ipNet.IP.Mask(ipNet.Mask).Equal(ipNet.IP) {
+		return nil, ErrInvalidBinaryInput
+	}
+	p := &prefixTrie[V]{
+		numBitsSkipped: uint(numBitsSkipped),
+		numBitsHandled: uint(numBitsHandled),
+		network:        rnet.NewNetwork(ipNet),
+	}
+	if flags[0]&flagHasValue != 0 {
+		length, err := d.uvarint()
+		if err != nil {
+			return nil, err
+		}
+		if length > uint64(len(d.data)) {
+			return nil, ErrInvalidBinaryInput
+		}
+		data, err := d.bytes(int(length))
+		if err != nil {
+			return nil, err
+		}
+		if p.value, err = codec.UnmarshalValue(ipNet, data); err != nil {
+			return nil, err
+		}
+		p.hasValue = true
+		*count++
+	}
+	numChildren, err := d.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	// Children are allocated once known to fit in the input left.
+	slots := 1 << numBitsHandled
+	if numChildren > uint64(slots) || numChildren > uint64(len(d.data)) || slots > d.slots ||
+		numChildren > 0 && numBitsSkipped+numBitsHandled > bits {
+		return nil, ErrInvalidBinaryInput
+	}
+	d.slots -= slots
+	p.children = make([]*prefixTrie[V], slots)
+	for ; numChildren > 0; numChildren-- {
+		index, err := d.uvarint()
+		if err != nil {
+			return nil, err
+		}
+		if index >= uint64(len(p.children)) || p.children[index] != nil {
+			return nil, ErrInvalidBinaryInput
+		}
+		child, err := decodeTrie(d, version, codec, count)
+		if err != nil {
+			return nil, err
+		}
+		if child.numBitsSkipped < p.numBitsSkipped+p.numBitsHandled || !p.network.Contains(child.network.Number) {
+			return nil, ErrInvalidBinaryInput
+		}
+		if bit, err := p.childIndex(child.network.Number); err != nil || uint64(bit) != index {
+			return nil, ErrInvalidBinaryInput
+		}
+		p.setChild(uint32(index), child)
+	}
+	return p, nil
+}
+
+// trieDecoder consumes the encoded tries.
+type trieDecoder struct {
+	data []byte
+	// slots is the number of children left to allocate.
+	slots int
+}
+
+func (d *trieDecoder) uvarint() (uint64, error) {
+	value, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		return 0, ErrInvalidBinaryInput
+	}
+	d.data = d.data[n:]
+	return value, nil
+}
+
+func (d *trieDecoder) bytes(n int) ([]byte, error) {
+	if n > len(d.data) {
+		return nil, ErrInvalidBinaryInput
+	}
+	b := d.data[:n]
+	d.data = d.data[n:]
+	return b, nil
+}
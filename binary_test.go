@@ -0,0 +1,224 @@
+package cidranger
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"hash/crc32"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarshalBinaryRoundTrip(t *testing.T) {
+	ranger := NewPCTrieRanger()
+	configureRangerWithAWSRanges(t, ranger)
+	data, err := ranger.(encoding.BinaryMarshaler).MarshalBinary()
+	assert.NoError(t, err)
+
+	decoded := NewPCTrieRanger()
+	assert.NoError(t, decoded.(encoding.BinaryUnmarshaler).UnmarshalBinary(data))
+	assert.Equal(t, ranger.Len(), decoded.Len())
+	assert.Equal(t, networksOf(ranger), networksOf(decoded))
+
+	// The tries are rebuilt node for node, level compression included.
+	for _, version := range []func(*versionedRanger[RangerEntry]) TypedRanger[RangerEntry]{
+		func(v *versionedRanger[RangerEntry]) TypedRanger[RangerEntry] { return v.ipV4Ranger },
+		func(v *versionedRanger[RangerEntry]) TypedRanger[RangerEntry] { return v.ipV6Ranger },
+	} {
+		expected := version(ranger.(*entryRanger).ranger.(*versionedRanger[RangerEntry])).(*prefixTrie[RangerEntry])
+		actual := version(decoded.(*entryRanger).ranger.(*versionedRanger[RangerEntry])).(*prefixTrie[RangerEntry])
+		assert.Equal(t, expected.String(), actual.String())
+	}
+
+	// The decoded ranger remains fully functional.
+	for _, network := range ipV4AWSRangesIPNets[:100] {
+		_, err := decoded.Remove(*network)
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, decoded.InsertPrefix(netip.MustParsePrefix("10.0.0.0/8")))
+	for _, ip := range []string{"10.1.2.3", "52.95.110.1", "2600:1f00::1"} {
+		expected, err := ranger.ContainingNetworks(net.ParseIP(ip))
+		assert.NoError(t, err)
+		actual, err := decoded.ContainingNetworks(net.ParseIP(ip))
+		assert.NoError(t, err)
+		assert.Subset(t, networkStrings(append(expected, NewBasicRangerEntry(*parseCIDRUnsafe("10.0.0.0/8")))), networkStrings(actual))
+	}
+}
+
+func TestMarshalRangerWithCodec(t *testing.T) {
+	ranger := NewPCTrieRanger()
+	for _, network := range []string{"192.168.0.0/16", "192.168.1.0/24", "8000::/16"} {
+		assert.NoError(t, ranger.Insert(&taggedRangerEntry{*parseCIDRUnsafe(network), []string{"feed", network}}))
+	}
+	_, err := MarshalRanger(ranger, BasicEntryCodec)
+	assert.Equal(t, ErrUnsupportedEntry, err)
+
+	data, err := MarshalRanger(ranger, taggedCodec{})
+	assert.NoError(t, err)
+	decoded, err := UnmarshalRanger(data, taggedCodec{})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, decoded.Len())
+	entry, found, err := decoded.Get(*parseCIDRUnsafe("192.168.1.0/24"))
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, []string{"feed", "192.168.1.0/24"}, entry.(*taggedRangerEntry).tags)
+}
+
+func TestMarshalTypedRanger(t *testing.T) {
+	for name, ranger := range map[string]TypedRanger[string]{
+		"pctrie":   NewTypedPCTrieRanger[string](),
+		"snapshot": NewTypedSnapshotPCTrieRanger[string](),
+	} {
+		assert.NoError(t, ranger.InsertPrefix(netip.MustParsePrefix("10.0.0.0/8"), "private"), name)
+		assert.NoError(t, ranger.InsertPrefix(netip.MustParsePrefix("2001:db8::/32"), "documentation"), name)
+
+		data, err := MarshalTypedRanger(ranger, StringCodec)
+		assert.NoError(t, err, name)
+		decoded, err := UnmarshalTypedRanger(data, StringCodec)
+		assert.NoError(t, err, name)
+		entry, found, err := decoded.LongestMatchAddr(netip.MustParseAddr("2001:db8::1"))
+		assert.NoError(t, err, name)
+		assert.True(t, found, name)
+		assert.Equal(t, "documentation", entry.Value, name)
+		assert.Equal(t, 2, decoded.Len(), name)
+	}
+}
+
+func TestMarshalRangerCopiesOtherRangers(t *testing.T) {
+	ranger := newBruteRanger()
+	configureRangerWithAWSRanges(t, ranger)
+	data, err := MarshalRanger(ranger, BasicEntryCodec)
+	assert.NoError(t, err)
+	decoded, err := UnmarshalRanger(data, BasicEntryCodec)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, networksOf(ranger), networksOf(decoded))
+}
+
+func TestUnmarshalBinaryInvalidInput(t *testing.T) {
+	ranger := NewPCTrieRanger()
+	configureRangerWithAWSRanges(t, ranger)
+	size := ranger.Len()
+	data, err := MarshalRanger(ranger, BasicEntryCodec)
+	assert.NoError(t, err)
+
+	corrupted := append([]byte(nil), data...)
+	corrupted[len(corrupted)/2] ^= 0x40
+	_, err = UnmarshalRanger(corrupted, BasicEntryCodec)
+	assert.Equal(t, ErrInvalidBinaryInput, err)
+
+	_, err = UnmarshalRanger(data[:len(data)-1], BasicEntryCodec)
+	assert.Equal(t, ErrInvalidBinaryInput, err)
+	_, err = UnmarshalRanger(nil, BasicEntryCodec)
+	assert.Equal(t, ErrInvalidBinaryInput, err)
+
+	future := append([]byte(nil), data[:len(data)-4]...)
+	future[len(binaryMagic)] = binaryVersion + 1
+	future = binary.BigEndian.AppendUint32(future, crc32.ChecksumIEEE(future))
+	_, err = UnmarshalRanger(future, BasicEntryCodec)
+	assert.Equal(t, ErrUnsupportedBinaryVersion, err)
+
+	// A well formed checksum does not make up for an invalid structure.
+	invalid := append([]byte(nil), data[:len(binaryMagic)+2]...)
+	invalid = append(invalid, 0xff)
+	invalid = binary.BigEndian.AppendUint32(invalid, crc32.ChecksumIEEE(invalid))
+	_, err = UnmarshalRanger(invalid, BasicEntryCodec)
+	assert.Equal(t, ErrInvalidBinaryInput, err)
+
+	// A failed decoding leaves the ranger untouched.
+	assert.Error(t, ranger.(encoding.BinaryUnmarshaler).UnmarshalBinary(corrupted))
+	assert.Equal(t, size, ranger.Len())
+}
+
+func TestUnmarshalBinaryReadOnlyRangers(t *testing.T) {
+	ranger := NewSnapshotPCTrieRanger()
+	assert.NoError(t, ranger.InsertPrefix(netip.MustParsePrefix("10.0.0.0/8")))
+	var buf bytes.Buffer
+	assert.NoError(t, WriteMappedRanger(&buf, ranger, BasicEntryCodec))
+	mapped, err := NewMappedRanger(buf.Bytes(), BasicEntryCodec)
+	assert.NoError(t, err)
+
+	replacement := NewPCTrieRanger()
+	assert.NoError(t, replacement.InsertPrefix(netip.MustParsePrefix("192.168.0.0/16")))
+	assert.NoError(t, replacement.InsertPrefix(netip.MustParsePrefix("172.16.0.0/12")))
+	data, err := MarshalRanger(replacement, BasicEntryCodec)
+	assert.NoError(t, err)
+
+	for _, readOnly := range []Ranger{ranger.Snapshot(), mapped} {
+		unmarshaler, ok := readOnly.(encoding.BinaryUnmarshaler)
+		if assert.True(t, ok) {
+			assert.Equal(t, ErrReadOnlyRanger, unmarshaler.UnmarshalBinary(data))
+		}
+		assert.Equal(t, 1, readOnly.Len())
+		assert.Equal(t, ErrReadOnlyRanger, readOnly.InsertPrefix(netip.MustParsePrefix("203.0.113.0/24")))
+		assert.Equal(t, 1, readOnly.Len())
+	}
+}
+
+func TestUnmarshalBinaryBoundsAllocation(t *testing.T) {
+	node := func(dst []byte, numBitsSkipped, numBitsHandled uint64, ip net.IP) []byte {
+		dst = append(dst, 0)
+		dst = binary.AppendUvarint(dst, numBitsSkipped)
+		dst = binary.AppendUvarint(dst, numBitsHandled)
+		return append(dst, ip...)
+	}
+	encode := func(ipV6Root []byte) []byte {
+		data := append([]byte(binaryMagic), binaryVersion, 0)
+		data = append(node(data, 0, 1, net.IPv4zero.To4()), 0, 0)
+		data = append(data, ipV6Root...)
+		return binary.BigEndian.AppendUint32(data, crc32.ChecksumIEEE(data))
+	}
+
+	ranger, err := UnmarshalRanger(encode(append(node(nil, 0, 1, net.IPv6zero), 0)), BasicEntryCodec)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, ranger.Len())
+
+	// Nodes never handle more bits than level compression inflates them to.
+	wide := append(node(nil, 0, maxBitsHandled+1, net.IPv6zero), 0)
+	_, err = UnmarshalRanger(encode(wide), BasicEntryCodec)
+	assert.Equal(t, ErrInvalidBinaryInput, err)
+
+	// A few bytes per node do not allocate 65536 children each.
+	var sparse []byte
+	for i := uint64(0); i < 8; i++ {
+		sparse = node(sparse, 16*i, 16, net.IPv6zero)
+		if i < 7 {
+			sparse = append(sparse, 1, 0)
+		} else {
+			sparse = append(sparse, 0)
+		}
+	}
+	_, err = UnmarshalRanger(encode(sparse), BasicEntryCodec)
+	assert.Equal(t, ErrInvalidBinaryInput, err)
+}
+
+// taggedCodec encodes the tags of taggedRangerEntry(s), one per line.
+type taggedCodec struct{}
+
+func (taggedCodec) MarshalValue(network net.IPNet, entry RangerEntry) ([]byte, error) {
+	return []byte(strings.Join(entry.(*taggedRangerEntry).tags, "\n")), nil
+}
+
+func (taggedCodec) UnmarshalValue(network net.IPNet, data []byte) (RangerEntry, error) {
+	return &taggedRangerEntry{network, strings.Split(string(data), "\n")}, nil
+}
+
+func networksOf(ranger Ranger) []string {
+	var entries []RangerEntry
+	for entry := range ranger.All() {
+		entries = append(entries, entry)
+	}
+	return networkStrings(entries)
+}
+
+func networkStrings(entries []RangerEntry) []string {
+	networks := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		network := entry.Network()
+		networks = append(networks, network.String())
+	}
+	return networks
+}
@@ -1,7 +1,10 @@
 package cidranger
 
 import (
+	"iter"
 	"net"
+	"net/netip"
+	"sort"
 
 	rnet "github.com/yl2chen/cidranger/net"
 )
@@ -29,27 +32,53 @@ func newBruteRanger() Ranger {
 	}
 }
 
-// Insert inserts a RangerEntry into ranger.
+// Insert inserts a RangerEntry into ranger, replacing any RangerEntry of the
+// same network.
 func (b *bruteRanger) Insert(entry RangerEntry) error {
-	network := entry.Network()
-	key := network.String()
-	if _, found := b.ipV4Entries[key]; !found {
-		entries, err := b.getEntriesByVersion(entry.Network().IP)
-		if err != nil {
-			return err
-		}
-		entries[key] = entry
+	_, _, err := b.Swap(entry)
+	return err
+}
+
+// Upsert stores the RangerEntry returned by fn for given network.
+func (b *bruteRanger) Upsert(network net.IPNet, fn func(old RangerEntry, exists bool) RangerEntry) error {
+	entries, err := b.getEntriesByVersion(network.IP)
+	if err != nil {
+		return err
 	}
+	key := networkKey(network)
+	old, exists := entries[key]
+	entries[key] = fn(old, exists)
 	return nil
 }
 
+// Swap inserts a RangerEntry into ranger, returning the RangerEntry it
+// replaced.
+func (b *bruteRanger) Swap(entry RangerEntry) (RangerEntry, bool, error) {
+	var previous RangerEntry
+	var replaced bool
+	err := b.Upsert(entry.Network(), func(old RangerEntry, exists bool) RangerEntry {
+		previous, replaced = old, exists
+		return entry
+	})
+	return previous, replaced, err
+}
+
+// InsertPrefix inserts a basic RangerEntry for given prefix into ranger.
+func (b *bruteRanger) InsertPrefix(prefix netip.Prefix) error {
+	network := rnet.NewNetworkFromPrefix(prefix)
+	if network.Number == nil {
+		return ErrInvalidNetworkInput
+	}
+	return b.Insert(NewBasicRangerEntry(network.IPNet))
+}
+
 // Remove removes a RangerEntry identified by given network from ranger.
 func (b *bruteRanger) Remove(network net.IPNet) (RangerEntry, error) {
 	networks, err := b.getEntriesByVersion(network.IP)
 	if err != nil {
 		return nil, err
 	}
-	key := network.String()
+	key := networkKey(network)
 	if networkToDelete, found := networks[key]; found {
 		delete(networks, key)
 		return networkToDelete, nil
@@ -57,6 +86,83 @@ func (b *bruteRanger) Remove(network net.IPNet) (RangerEntry, error) {
 	return nil, nil
 }
 
+// RemovePrefix removes a RangerEntry identified by given prefix from ranger.
+func (b *bruteRanger) RemovePrefix(prefix netip.Prefix) (RangerEntry, error) {
+	network := rnet.NewNetworkFromPrefix(prefix)
+	if network.Number == nil {
+		return nil, ErrInvalidNetworkInput
+	}
+	return b.Remove(network.IPNet)
+}
+
+// CompareAndRemove removes the RangerEntry stored for given network only if it
+// is expected.
+func (b *bruteRanger) CompareAndRemove(network net.IPNet, expected RangerEntry) (bool, error) {
+	entries, err := b.getEntriesByVersion(network.IP)
+	if err != nil {
+		return false, err
+	}
+	key := networkKey(network)
+	if entry, found := entries[key]; found && sameEntry(entry, expected) {
+		delete(entries, key)
+		return true, nil
+	}
+	return false, nil
+}
+
+// RemoveCovered removes all RangerEntry(s) covered by given network from
+// ranger.
+func (b *bruteRanger) RemoveCovered(network net.IPNet) ([]RangerEntry, error) {
+	entries, err := b.getEntriesByVersion(network.IP)
+	if err != nil {
+		return nil, err
+	}
+	var results []RangerEntry
+	testNetwork := rnet.NewNetwork(network)
+	for key, entry := range entries {
+		if testNetwork.Covers(rnet.NewNetwork(entry.Network())) {
+			results = append(results, entry)
+			delete(entries, key)
+		}
+	}
+	return results, nil
+}
+
+// networkKey returns the key of network in the entry maps, its host bits
+// cleared as the prefix trie does.
+func networkKey(network net.IPNet) string {
+	canonical := net.IPNet{IP: network.IP.Mask(network.Mask), Mask: network.Mask}
+	if canonical.IP == nil {
+		return network.String()
+	}
+	return canonical.String()
+}
+
+// Clear removes all RangerEntry(s) from ranger.
+func (b *bruteRanger) Clear() {
+	b.ipV4Entries = make(map[string]RangerEntry)
+	b.ipV6Entries = make(map[string]RangerEntry)
+}
+
+// Get returns the RangerEntry stored for exactly given network.
+func (b *bruteRanger) Get(network net.IPNet) (RangerEntry, bool, error) {
+	networks, err := b.getEntriesByVersion(network.IP)
+	if err != nil {
+		return nil, false, err
+	}
+	entry, found := networks[networkKey(network)]
+	return entry, found, nil
+}
+
+// GetPrefix returns the RangerEntry stored for exactly given prefix.
+func (b *bruteRanger) GetPrefix(prefix netip.Prefix) (RangerEntry, bool, error) {
+	network := rnet.NewNetworkFromPrefix(prefix)
+	if network.Number == nil {
+		return nil, false, ErrInvalidNetworkInput
+	}
+	return b.Get(network.IPNet)
+}
+
 // Contains returns bool indicating whether given ip is contained by any
 // network in ranger.
 func (b *bruteRanger) Contains(ip net.IP) (bool, error) {
@@ -73,6 +179,12 @@ func (b *bruteRanger) Contains(ip net.IP) (bool, error) {
 	return false, nil
 }
 
+// ContainsAddr returns bool indicating whether given addr is contained by any
+// network in ranger.
+func (b *bruteRanger) ContainsAddr(addr netip.Addr) (bool, error) {
+	return b.Contains(addrToIP(addr))
+}
+
 // ContainingNetworks returns all RangerEntry(s) that given ip contained in.
 func (b *bruteRanger) ContainingNetworks(ip net.IP) ([]RangerEntry, error) {
 	entries, err := b.getEntriesByVersion(ip)
@@ -89,6 +201,48 @@ func (b *bruteRanger) ContainingNetworks(ip net.IP) ([]RangerEntry, error) {
 	return results, nil
 }
 
+// ContainingPrefixes returns all RangerEntry(s) that given addr contained in.
+func (b *bruteRanger) ContainingPrefixes(addr netip.Addr) ([]RangerEntry, error) {
+	return b.ContainingNetworks(addrToIP(addr))
+}
+
+// LongestMatch returns the most specific RangerEntry containing given ip.
+func (b *bruteRanger) LongestMatch(ip net.IP) (RangerEntry, bool, error) {
+	return b.match(ip, true)
+}
+
+// LongestMatchAddr returns the most specific RangerEntry containing given
+// addr.
+func (b *bruteRanger) LongestMatchAddr(addr netip.Addr) (RangerEntry, bool, error) {
+	return b.match(addrToIP(addr), true)
+}
+
+// ShortestMatch returns the least specific RangerEntry containing given ip.
+func (b *bruteRanger) ShortestMatch(ip net.IP) (RangerEntry, bool, error) {
+	return b.match(ip, false)
+}
+
+// ShortestMatchAddr returns the least specific RangerEntry containing given
+// addr.
+func (b *bruteRanger) ShortestMatchAddr(addr netip.Addr) (RangerEntry, bool, error) {
+	return b.match(addrToIP(addr), false)
+}
+
+func (b *bruteRanger) match(ip net.IP, longest bool) (RangerEntry, bool, error) {
+	entries, err := b.ContainingNetworks(ip)
+	if err != nil || len(entries) == 0 {
+		return nil, false, err
+	}
+	matched, matchedOnes := entries[0], maskSize(entries[0])
+	for _, entry := range entries[1:] {
+		ones := maskSize(entry)
+		if (longest && ones > matchedOnes) || (!longest && ones < matchedOnes) {
+			matched, matchedOnes = entry, ones
+		}
+	}
+	return matched, true, nil
+}
+
 // CoveredNetworks returns the list of RangerEntry(s) the given ipnet
 // covers.  That is, the networks that are completely subsumed by the
 // specified network.
@@ -108,6 +262,149 @@ func (b *bruteRanger) CoveredNetworks(network net.IPNet) ([]RangerEntry, error)
 	return results, nil
 }
 
+// CoveredPrefixes returns the list of RangerEntry(s) the given prefix covers.
+func (b *bruteRanger) CoveredPrefixes(prefix netip.Prefix) ([]RangerEntry, error) {
+	network := rnet.NewNetworkFromPrefix(prefix)
+	if network.Number == nil {
+		return nil, ErrInvalidNetworkInput
+	}
+	return b.CoveredNetworks(network.IPNet)
+}
+
+// CoveringNetworks returns the list of RangerEntry(s) that cover the given
+// ipnet.  That is, the networks that completely subsume the specified network.
+func (b *bruteRanger) CoveringNetworks(network net.IPNet) ([]RangerEntry, error) {
+	entries, err := b.getEntriesByVersion(network.IP)
+	if err != nil {
+		return nil, err
+	}
+	var results []RangerEntry
+	testNetwork := rnet.NewNetwork(network)
+	for _, entry := range entries {
+		entryNetwork := rnet.NewNetwork(entry.Network())
+		if entryNetwork.Covers(testNetwork) {
+			results = append(results, entry)
+		}
+	}
+	return results, nil
+}
+
+// CoveringPrefixes returns the list of RangerEntry(s) that cover the given
+// prefix.
+func (b *bruteRanger) CoveringPrefixes(prefix netip.Prefix) ([]RangerEntry, error) {
+	network := rnet.NewNetworkFromPrefix(prefix)
+	if network.Number == nil {
+		return nil, ErrInvalidNetworkInput
+	}
+	return b.CoveringNetworks(network.IPNet)
+}
+
+// OverlappingNetworks returns the list of RangerEntry(s) that either cover or
+// are covered by the given ipnet.
+func (b *bruteRanger) OverlappingNetworks(network net.IPNet) ([]RangerEntry, error) {
+	entries, err := b.getEntriesByVersion(network.IP)
+	if err != nil {
+		return nil, err
+	}
+	var results []RangerEntry
+	testNetwork := rnet.NewNetwork(network)
+	for _, entry := range entries {
+		entryNetwork := rnet.NewNetwork(entry.Network())
+		if entryNetwork.Covers(testNetwork) || testNetwork.Covers(entryNetwork) {
+			results = append(results, entry)
+		}
+	}
+	return results, nil
+}
+
+// OverlappingPrefixes returns the list of RangerEntry(s) that either cover or
+// are covered by the given prefix.
+func (b *bruteRanger) OverlappingPrefixes(prefix netip.Prefix) ([]RangerEntry, error) {
+	network := rnet.NewNetworkFromPrefix(prefix)
+	if network.Number == nil {
+		return nil, ErrInvalidNetworkInput
+	}
+	return b.OverlappingNetworks(network.IPNet)
+}
+
+// Walk calls fn for every RangerEntry in ranger in address order until fn
+// returns false, sorting all entries up front.
+func (b *bruteRanger) Walk(fn func(RangerEntry) bool) {
+	for _, entry := range b.sortedEntries() {
+		if !fn(entry) {
+			return
+		}
+	}
+}
+
+// All returns an iterator over every RangerEntry in ranger.
+func (b *bruteRanger) All() iter.Seq[RangerEntry] {
+	return b.Walk
+}
+
+// Backward returns an iterator over every RangerEntry in ranger in reverse
+// address order.
+func (b *bruteRanger) Backward() iter.Seq[RangerEntry] {
+	return func(yield func(RangerEntry) bool) {
+		entries := b.sortedEntries()
+		for i := len(entries) - 1; i >= 0; i-- {
+			if !yield(entries[i]) {
+				return
+			}
+		}
+	}
+}
+
+// Next returns the RangerEntry following given network in address order.
+func (b *bruteRanger) Next(network net.IPNet) (RangerEntry, bool, error) {
+	if _, err := b.getEntriesByVersion(network.IP); err != nil {
+		return nil, false, err
+	}
+	testNetwork := rnet.NewNetwork(network)
+	for _, entry := range b.sortedEntries() {
+		if rnet.NewNetwork(entry.Network()).Compare(testNetwork) > 0 {
+			return entry, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+// Prev returns the RangerEntry preceding given network in address order.
+func (b *bruteRanger) Prev(network net.IPNet) (RangerEntry, bool, error) {
+	if _, err := b.getEntriesByVersion(network.IP); err != nil {
+		return nil, false, err
+	}
+	testNetwork := rnet.NewNetwork(network)
+	entries := b.sortedEntries()
+	for i := len(entries) - 1; i >= 0; i-- {
+		if rnet.NewNetwork(entries[i].Network()).Compare(testNetwork) < 0 {
+			return entries[i], true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+// EntriesBetween returns the RangerEntry(s) overlapping the address range from
+// start to end inclusive, in address order.
+func (b *bruteRanger) EntriesBetween(start, end net.IP) ([]RangerEntry, error) {
+	startNumber, endNumber := rnet.NewNetworkNumber(start), rnet.NewNetworkNumber(end)
+	if startNumber == nil || endNumber == nil || len(startNumber) != len(endNumber) {
+		return nil, ErrInvalidNetworkNumberInput
+	}
+	var results []RangerEntry
+	for _, entry := range b.sortedEntries() {
+		network := rnet.NewNetwork(entry.Network())
+		lastNumber := make(rnet.NetworkNumber, len(network.Number))
+		for i := range lastNumber {
+			lastNumber[i] = network.Number[i] | ^network.Mask[i]
+		}
+		if network.Number.Compare(endNumber) <= 0 && lastNumber.Compare(startNumber) >= 0 {
+			results = append(results, entry)
+		}
+	}
+	return results, nil
+}
+
 // Len returns number of networks in ranger.
 func (b *bruteRanger) Len() int {
 	return len(b.ipV4Entries) + len(b.ipV6Entries)
@@ -122,3 +419,33 @@ func (b *bruteRanger) getEntriesByVersion(ip net.IP) (map[string]RangerEntry, er
 	}
 	return nil, ErrInvalidNetworkInput
 }
+
+// sortedEntries returns all entries in address order.
+func (b *bruteRanger) sortedEntries() []RangerEntry {
+	entries := make([]RangerEntry, 0, b.Len())
+	for _, entry := range b.ipV4Entries {
+		entries = append(entries, entry)
+	}
+	for _, entry := range b.ipV6Entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return rnet.NewNetwork(entries[i].Network()).Compare(rnet.NewNetwork(entries[j].Network())) < 0
+	})
+	return entries
+}
+
+func maskSize(entry RangerEntry) int {
+	network := entry.Network()
+	ones, _ := network.Mask.Size()
+	return ones
+}
+
+// addrToIP returns the net.IP equivalent to given addr, dropping any zone,
+// or nil if addr is not valid.
+func addrToIP(addr netip.Addr) net.IP {
+	if !addr.IsValid() {
+		return nil
+	}
+	return net.IP(addr.Unmap().AsSlice())
+}
@@ -2,6 +2,7 @@ package cidranger
 
 import (
 	"net"
+	"net/netip"
 	"sort"
 	"testing"
 
@@ -24,6 +25,19 @@ func TestInsert(t *testing.T) {
 	assert.Equal(t, entryIPv6, ranger.ipV6Entries["8000::/96"])
 }
 
+func TestInsertReplaces(t *testing.T) {
+	ranger := newBruteRanger().(*bruteRanger)
+	_, networkIPv6, _ := net.ParseCIDR("8000::/96")
+	first := NewBasicRangerEntry(*networkIPv6)
+	second := NewBasicRangerEntry(*networkIPv6)
+
+	ranger.Insert(first)
+	ranger.Insert(second)
+
+	assert.Equal(t, 1, len(ranger.ipV6Entries))
+	assert.Same(t, second, ranger.ipV6Entries["8000::/96"])
+}
+
 func TestInsertError(t *testing.T) {
 	bRanger := newBruteRanger().(*bruteRanger)
 	_, networkIPv4, _ := net.ParseCIDR("0.0.1.0/24")
@@ -68,6 +82,31 @@ func TestRemoveError(t *testing.T) {
 	assert.Equal(t, ErrInvalidNetworkInput, err)
 }
 
+func TestGet(t *testing.T) {
+	r := newBruteRanger().(*bruteRanger)
+	_, network, _ := net.ParseCIDR("0.0.1.0/24")
+	_, network1, _ := net.ParseCIDR("8000::/112")
+	_, notInserted, _ := net.ParseCIDR("0.0.1.0/25")
+	entry := NewBasicRangerEntry(*network)
+	r.Insert(entry)
+	r.Insert(NewBasicRangerEntry(*network1))
+
+	actual, found, err := r.Get(*network)
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, entry, actual)
+
+	actual, found, err = r.Get(*notInserted)
+	assert.NoError(t, err)
+	assert.False(t, found)
+	assert.Nil(t, actual)
+
+	_, found, err = r.GetPrefix(netip.MustParsePrefix("8000::/112"))
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, 2, r.Len())
+}
+
 func TestContains(t *testing.T) {
 	r := newBruteRanger().(*bruteRanger)
 	_, network, _ := net.ParseCIDR("0.0.1.0/24")
@@ -146,6 +185,34 @@ func TestContainingNetworks(t *testing.T) {
 	}
 }
 
+func TestMatch(t *testing.T) {
+	r := newBruteRanger().(*bruteRanger)
+	_, network1, _ := net.ParseCIDR("0.0.1.0/24")
+	_, network2, _ := net.ParseCIDR("0.0.1.0/25")
+	entry1 := NewBasicRangerEntry(*network1)
+	entry2 := NewBasicRangerEntry(*network2)
+	r.Insert(entry1)
+	r.Insert(entry2)
+
+	entry, found, err := r.LongestMatch(net.ParseIP("0.0.1.1"))
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, entry2, entry)
+
+	entry, found, err = r.ShortestMatch(net.ParseIP("0.0.1.1"))
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, entry1, entry)
+
+	entry, found, err = r.LongestMatch(net.ParseIP("0.0.2.1"))
+	assert.NoError(t, err)
+	assert.False(t, found)
+	assert.Nil(t, entry)
+
+	_, _, err = r.LongestMatch(append(net.ParseIP("8000::1:7fff"), byte(0)))
+	assert.Equal(t, ErrInvalidNetworkInput, err)
+}
+
 func TestCoveredNetworks(t *testing.T) {
 	for _, tc := range coveredNetworkTests {
 		t.Run(tc.name, func(t *testing.T) {
@@ -175,3 +242,33 @@ func TestCoveredNetworks(t *testing.T) {
 		})
 	}
 }
+
+func TestCoveringNetworks(t *testing.T) {
+	for _, tc := range coveringNetworkTests {
+		t.Run(tc.name, func(t *testing.T) {
+			ranger := newBruteRanger()
+			for _, insert := range tc.inserts {
+				_, network, _ := net.ParseCIDR(insert)
+				err := ranger.Insert(NewBasicRangerEntry(*network))
+				assert.NoError(t, err)
+			}
+			var expectedEntries []string
+			for _, network := range tc.networks {
+				expectedEntries = append(expectedEntries, network)
+			}
+			sort.Strings(expectedEntries)
+			_, snet, _ := net.ParseCIDR(tc.search)
+			networks, err := ranger.CoveringNetworks(*snet)
+			assert.NoError(t, err)
+
+			var results []string
+			for _, result := range networks {
+				net := result.Network()
+				results = append(results, net.String())
+			}
+			sort.Strings(results)
+
+			assert.Equal(t, expectedEntries, results)
+		})
+	}
+}
@@ -13,6 +13,23 @@ interface):
 			ranger.Insert(NewBasicRangerEntry(*network))
 			ranger.Remove(network)
 
+To merge an entry with the one already stored for its network, or find out
+which entry an insert replaced:
+
+			ranger.Upsert(*network, func(old RangerEntry, exists bool) RangerEntry {
+				return merge(old, exists, newEntry)
+			})
+			// returns RangerEntry, bool, error
+			previous, replaced, err := ranger.Swap(NewBasicRangerEntry(*network))
+
+To remove an entry only if it is still the one expected, or every entry
+covered by a network at once:
+
+			// returns bool, error
+			removed, err := ranger.CompareAndRemove(*network, previous)
+			// returns []RangerEntry, error
+			entries, err := ranger.RemoveCovered(*network)
+
 If you desire for any value to be attached to the entry, simply
 create custom struct that satisfies the RangerEntry interface:
 
@@ -20,6 +37,17 @@ create custom struct that satisfies the RangerEntry interface:
 				Network() net.IPNet
 			}
 
+To get the entry stored for exactly a network, without removing it:
+
+			// returns RangerEntry, bool, error
+			entry, found, err := ranger.Get(*network)
+
+To get the most specific entry of a custom type containing an IP, looking
+past the entries of other types:
+
+			// returns *customEntry, error
+			entry, err := LookupEntry[*customEntry](ranger, net.ParseIP("10.0.0.1"))
+
 To test whether an IP is contained in the constructed networks ranger:
 
 			// returns bool, error
@@ -30,18 +58,119 @@ To get a list of CIDR blocks in constructed ranger that contains IP:
 			// returns []RangerEntry, error
 			entries, err := ranger.ContainingNetworks(net.ParseIP("192.168.0.1"))
 
+To get only the most (or least) specific CIDR block containing IP:
+
+			// returns RangerEntry, bool, error
+			entry, found, err := ranger.LongestMatch(net.ParseIP("192.168.0.1"))
+			entry, found, err := ranger.ShortestMatch(net.ParseIP("192.168.0.1"))
+
 To get a list of all IPv4/IPv6 rangers respectively:
 
 			// returns []RangerEntry, error
 			entries, err := ranger.CoveredNetworks(*AllIPv4)
 			entries, err := ranger.CoveredNetworks(*AllIPv6)
 
+To get a list of CIDR blocks in constructed ranger that contain a whole
+network, or that overlap it in either direction:
+
+			// returns []RangerEntry, error
+			entries, err := ranger.CoveringNetworks(*network)
+			entries, err := ranger.OverlappingNetworks(*network)
+
+Insert, Remove and the lookup methods have net/netip counterparts taking a
+netip.Addr or netip.Prefix, ContainsAddr performs the lookup without
+allocating:
+
+			containsBool, err := ranger.ContainsAddr(netip.MustParseAddr("192.168.0.1"))
+
+IPv4-mapped IPv6 addresses (::ffff:192.168.0.1) and prefixes (of 96 bits or
+longer) are treated as their IPv4 equivalent, as net.IP.To4 does, and
+address zones are ignored.
+
+To iterate over every entry in ranger in address order, stopping whenever
+desired:
+
+			for entry := range ranger.All() {
+				fmt.Println(entry.Network())
+			}
+
+To page through entries in address order, or list the entries overlapping an
+arbitrary address range:
+
+			// returns RangerEntry, bool, error
+			entry, found, err := ranger.Next(entry.Network())
+
+			// returns []RangerEntry, error
+			entries, err := ranger.EntriesBetween(net.ParseIP("192.168.0.10"), net.ParseIP("192.168.3.20"))
+
+Rangers are not safe for concurrent use, wrap one with NewConcurrentRanger
+to share it between goroutines, lookups then run in parallel while mutations
+are serialized.  Walk, All and Backward iterate over the entries as of when
+iteration started, and may mutate the ranger as they go:
+
+			ranger := NewConcurrentRanger(NewPCTrieRanger())
+
+For read-heavy workloads, NewSnapshotPCTrieRanger copies the path to every
+mutated network instead of locking, so lookups never block.  Snapshot returns
+a read-only Ranger that later mutations never affect:
+
+			ranger := NewSnapshotPCTrieRanger()
+			snapshot := ranger.Snapshot()
+
+To apply many insertions and removals atomically, stage them in a Batch,
+Commit validates them all and applies every one or none:
+
+			batch := NewBatch()
+			batch.Insert(NewBasicRangerEntry(*network))
+			batch.RemovePrefix(netip.MustParsePrefix("10.0.0.0/8"))
+			err := batch.Commit(ranger)
+
+To persist a ranger and load it back without rebuilding it network by
+network, use encoding.BinaryMarshaler on rangers of basic entries, or
+MarshalRanger with a Codec encoding the payload of custom entries:
+
+			// returns []byte, error
+			data, err := ranger.(encoding.BinaryMarshaler).MarshalBinary()
+			data, err := MarshalRanger(ranger, codec)
+			// returns Ranger, error
+			ranger, err := UnmarshalRanger(data, codec)
+
+To dump a ranger in address order for debugging or configuration, as JSON or
+as lines of network and value separated by a tab, and load it back:
+
+			err := WriteJSON(os.Stdout, ranger, BasicEntryCodec)
+			err := ReadText(file, ranger, BasicEntryCodec)
+
+To share a large read-only ranger between processes, write it in the flat
+mapped format once, then map the file and query it in place, loading takes
+constant time and the page cache is shared:
+
+			err := WriteMappedRanger(file, ranger, BasicEntryCodec)
+
+			mappedFile, err := MapFile(path)
+			ranger, err := NewMappedRanger(mappedFile.Bytes(), BasicEntryCodec)
+
+To store values of a known type without type asserting RangerEntry(s) on
+every lookup, use the generic TypedRanger instead:
+
+			ranger := NewTypedPCTrieRanger[string]()
+			_, network, _ := net.ParseCIDR("192.168.0.0/24")
+			ranger.Insert(*network, "office")
+
+			// returns []Entry[string], error
+			entries, err := ranger.ContainingNetworks(net.ParseIP("192.168.0.1"))
+			fmt.Println(entries[0].Network, entries[0].Value)
+
 */
 package cidranger
 
 import (
 	"fmt"
+	"iter"
 	"net"
+	"net/netip"
+
+	rnet "github.com/yl2chen/cidranger/net"
 )
 
 // ErrInvalidNetworkInput is returned upon invalid network input.
@@ -50,6 +179,10 @@ var ErrInvalidNetworkInput = fmt.Errorf("Invalid network input")
 // ErrInvalidNetworkNumberInput is returned upon invalid network input.
 var ErrInvalidNetworkNumberInput = fmt.Errorf("Invalid network number input")
 
+// ErrReadOnlyRanger is returned upon mutating a read-only ranger.  Clear,
+// which can not report it, leaves read-only rangers unchanged.
+var ErrReadOnlyRanger = fmt.Errorf("Ranger is read-only")
+
 // AllIPv4 is a IPv4 CIDR that contains all networks
 var AllIPv4 = parseCIDRUnsafe("0.0.0.0/0")
 
@@ -84,16 +217,169 @@ func NewBasicRangerEntry(ipNet net.IPNet) RangerEntry {
 
 // Ranger is an interface for cidr block containment lookups.
 type Ranger interface {
+	// Insert inserts entry, replacing any entry of the same network.
 	Insert(entry RangerEntry) error
+	// Upsert stores the entry returned by fn for network, fn is passed the
+	// entry currently stored for network and whether there is one.  The
+	// returned entry must be for the same network.
+	Upsert(network net.IPNet, fn func(old RangerEntry, exists bool) RangerEntry) error
+	// Swap inserts entry, returning the entry it replaced and whether there
+	// was one.
+	Swap(entry RangerEntry) (RangerEntry, bool, error)
+	// InsertPrefix inserts a basic RangerEntry for given prefix, use Insert
+	// with a custom RangerEntry to attach values.
+	InsertPrefix(prefix netip.Prefix) error
 	Remove(network net.IPNet) (RangerEntry, error)
+	RemovePrefix(prefix netip.Prefix) (RangerEntry, error)
+	// CompareAndRemove removes the entry stored for network only if it is
+	// expected, compared using ==, and returns whether it was removed.
+	// Entries that are not comparable, such as structs holding a slice,
+	// never match.
+	CompareAndRemove(network net.IPNet, expected RangerEntry) (bool, error)
+	// RemoveCovered removes every entry whose network is covered by given
+	// network in one operation, returning the removed entries.
+	RemoveCovered(network net.IPNet) ([]RangerEntry, error)
+	// Clear removes every entry.
+	Clear()
+	// Get returns the entry stored for exactly given network, and whether
+	// one was found.
+	Get(network net.IPNet) (RangerEntry, bool, error)
+	GetPrefix(prefix netip.Prefix) (RangerEntry, bool, error)
 	Contains(ip net.IP) (bool, error)
+	ContainsAddr(addr netip.Addr) (bool, error)
 	ContainingNetworks(ip net.IP) ([]RangerEntry, error)
+	ContainingPrefixes(addr netip.Addr) ([]RangerEntry, error)
+	// LongestMatch returns the most specific entry containing ip, and whether
+	// one was found.
+	LongestMatch(ip net.IP) (RangerEntry, bool, error)
+	LongestMatchAddr(addr netip.Addr) (RangerEntry, bool, error)
+	// ShortestMatch returns the least specific entry containing ip, and
+	// whether one was found.
+	ShortestMatch(ip net.IP) (RangerEntry, bool, error)
+	ShortestMatchAddr(addr netip.Addr) (RangerEntry, bool, error)
 	CoveredNetworks(network net.IPNet) ([]RangerEntry, error)
+	CoveredPrefixes(prefix netip.Prefix) ([]RangerEntry, error)
+	// CoveringNetworks returns the entries whose network completely subsumes
+	// the given network.
+	CoveringNetworks(network net.IPNet) ([]RangerEntry, error)
+	CoveringPrefixes(prefix netip.Prefix) ([]RangerEntry, error)
+	// OverlappingNetworks returns the union of CoveringNetworks and
+	// CoveredNetworks for the given network.
+	OverlappingNetworks(network net.IPNet) ([]RangerEntry, error)
+	OverlappingPrefixes(prefix netip.Prefix) ([]RangerEntry, error)
+	// Walk calls fn for every entry in ranger until fn returns false.
+	// Entries are visited in address order, IPv4 before IPv6 and a network
+	// before the networks it covers.
+	Walk(fn func(RangerEntry) bool)
+	// All returns an iterator over every entry in ranger, in the same order
+	// as Walk.
+	All() iter.Seq[RangerEntry]
+	// Backward returns an iterator over every entry in ranger, in the
+	// reverse order of Walk.
+	Backward() iter.Seq[RangerEntry]
+	// Next returns the entry following given network in the order of Walk,
+	// and whether there is one.  The network does not need to be stored.
+	Next(network net.IPNet) (RangerEntry, bool, error)
+	// Prev returns the entry preceding given network in the order of Walk,
+	// and whether there is one.  The network does not need to be stored.
+	Prev(network net.IPNet) (RangerEntry, bool, error)
+	// EntriesBetween returns the entries overlapping the address range from
+	// start to end inclusive, in the order of Walk.
+	EntriesBetween(start, end net.IP) ([]RangerEntry, error)
 	Len() int
 }
 
 // NewPCTrieRanger returns a versionedRanger that supports both IPv4 and IPv6
 // using the path compressed trie implemention.
 func NewPCTrieRanger() Ranger {
-	return newVersionedRanger(newPrefixTree)
+	return newEntryRanger(NewTypedPCTrieRanger[RangerEntry]())
+}
+
+// LookupEntry returns the most specific entry of type T containing given ip,
+// or the zero T if there is none.  Entries of other types, such as the ones
+// loaded from elsewhere into a shared ranger, are looked past.
+//
+// The longest match is tried first, so that lookups do not allocate unless
+// it is of another type.
+func LookupEntry[T RangerEntry](ranger Ranger, ip net.IP) (T, error) {
+	var zero T
+	longestMatch, found, err := ranger.LongestMatch(ip)
+	if err != nil || !found {
+		return zero, err
+	}
+	if typed, ok := longestMatch.(T); ok {
+		return typed, nil
+	}
+	entries, err := ranger.ContainingNetworks(ip)
+	if err != nil {
+		return zero, err
+	}
+	// Rangers need not return containing networks in prefix order.
+	match, longest := zero, -1
+	for _, entry := range entries {
+		typed, ok := entry.(T)
+		if !ok {
+			continue
+		}
+		network := entry.Network()
+		if ones, _ := network.Mask.Size(); ones > longest {
+			match, longest = typed, ones
+		}
+	}
+	return match, nil
+}
+
+// Entry is a network stored in a TypedRanger along with its value.
+type Entry[V any] struct {
+	Network net.IPNet
+	Value   V
+}
+
+// Prefix returns the network of the entry as a netip.Prefix.
+func (e Entry[V]) Prefix() netip.Prefix {
+	return rnet.Network{IPNet: e.Network}.Prefix()
+}
+
+// TypedRanger is an interface for cidr block containment lookups that stores
+// a value of type V against each network.  It is the generic counterpart of
+// Ranger, returning typed values instead of RangerEntry(s).
+type TypedRanger[V any] interface {
+	Insert(network net.IPNet, value V) error
+	InsertPrefix(prefix netip.Prefix, value V) error
+	Upsert(network net.IPNet, fn func(old V, exists bool) V) error
+	Swap(network net.IPNet, value V) (V, bool, error)
+	Remove(network net.IPNet) (V, bool, error)
+	RemovePrefix(prefix netip.Prefix) (V, bool, error)
+	RemoveIf(network net.IPNet, fn func(value V) bool) (V, bool, error)
+	RemoveCovered(network net.IPNet) ([]Entry[V], error)
+	Clear()
+	Get(network net.IPNet) (V, bool, error)
+	GetPrefix(prefix netip.Prefix) (V, bool, error)
+	Contains(ip net.IP) (bool, error)
+	ContainsAddr(addr netip.Addr) (bool, error)
+	ContainingNetworks(ip net.IP) ([]Entry[V], error)
+	ContainingPrefixes(addr netip.Addr) ([]Entry[V], error)
+	LongestMatch(ip net.IP) (Entry[V], bool, error)
+	LongestMatchAddr(addr netip.Addr) (Entry[V], bool, error)
+	ShortestMatch(ip net.IP) (Entry[V], bool, error)
+	ShortestMatchAddr(addr netip.Addr) (Entry[V], bool, error)
+	CoveredNetworks(network net.IPNet) ([]Entry[V], error)
+	CoveredPrefixes(prefix netip.Prefix) ([]Entry[V], error)
+	CoveringNetworks(network net.IPNet) ([]Entry[V], error)
+	CoveringPrefixes(prefix netip.Prefix) ([]Entry[V], error)
+	OverlappingNetworks(network net.IPNet) ([]Entry[V], error)
+	OverlappingPrefixes(prefix netip.Prefix) ([]Entry[V], error)
+	Walk(fn func(Entry[V]) bool)
+	All() iter.Seq[Entry[V]]
+	Backward() iter.Seq[Entry[V]]
+	Next(network net.IPNet) (Entry[V], bool, error)
+	Prev(network net.IPNet) (Entry[V], bool, error)
+	EntriesBetween(start, end net.IP) ([]Entry[V], error)
+	Len() int
+}
+
+// NewTypedPCTrieRanger returns a versionedRanger that supports both IPv4 and
+// IPv6 using the path compressed trie implemention, storing values of type V.
+func NewTypedPCTrieRanger[V any]() TypedRanger[V] {
+	return newVersionedRanger(newPrefixTree[V])
 }
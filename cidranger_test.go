@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"math/rand"
 	"net"
+	"net/netip"
 	"testing"
 	"time"
 
@@ -26,6 +27,10 @@ func TestContainingNetworksAgaistBaseIPv4(t *testing.T) {
 	testContainingNetworksAgainstBase(t, 100000, randIPv4Gen)
 }
 
+func TestMatchAgainstBaseIPv4(t *testing.T) {
+	testMatchAgainstBase(t, 100000, randIPv4Gen)
+}
+
 func TestCoveredNetworksAgainstBaseIPv4(t *testing.T) {
 	testCoversNetworksAgainstBase(t, 100000, randomIPNetGenFactory(ipV4AWSRangesIPNets))
 }
@@ -41,6 +46,18 @@ func TestContainingNetworksAgaistBaseIPv6(t *testing.T) {
 	testContainingNetworksAgainstBase(t, 100000, curatedAWSIPv6Gen)
 }
 
+func TestCoveringNetworksAgainstBaseIPv4(t *testing.T) {
+	testCoveringNetworksAgainstBase(t, 10000, resizedIPNetGenFactory(ipV4AWSRangesIPNets))
+}
+
+func TestCoveringNetworksAgainstBaseIPv6(t *testing.T) {
+	testCoveringNetworksAgainstBase(t, 10000, resizedIPNetGenFactory(ipV6AWSRangesIPNets))
+}
+
+func TestMatchAgainstBaseIPv6(t *testing.T) {
+	testMatchAgainstBase(t, 100000, curatedAWSIPv6Gen)
+}
+
 func TestCoveredNetworksAgainstBaseIPv6(t *testing.T) {
 	testCoversNetworksAgainstBase(t, 100000, randomIPNetGenFactory(ipV6AWSRangesIPNets))
 }
@@ -49,7 +66,7 @@ func testContainsAgainstBase(t *testing.T, iterations int, ipGen ipGenerator) {
 	if testing.Short() {
 		t.Skip("Skipping memory test in `-short` mode")
 	}
-	rangers := []Ranger{NewPCTrieRanger()}
+	rangers := []Ranger{NewPCTrieRanger(), NewSnapshotPCTrieRanger()}
 	baseRanger := newBruteRanger()
 	for _, ranger := range rangers {
 		configureRangerWithAWSRanges(t, ranger)
@@ -64,6 +81,9 @@ func testContainsAgainstBase(t *testing.T, iterations int, ipGen ipGenerator) {
 			actual, err := ranger.Contains(nn.ToIP())
 			assert.NoError(t, err)
 			assert.Equal(t, expected, actual)
+			actual, err = ranger.ContainsAddr(nn.ToAddr())
+			assert.NoError(t, err)
+			assert.Equal(t, expected, actual)
 		}
 	}
 }
@@ -72,7 +92,7 @@ func testContainingNetworksAgainstBase(t *testing.T, iterations int, ipGen ipGen
 	if testing.Short() {
 		t.Skip("Skipping memory test in `-short` mode")
 	}
-	rangers := []Ranger{NewPCTrieRanger()}
+	rangers := []Ranger{NewPCTrieRanger(), NewSnapshotPCTrieRanger()}
 	baseRanger := newBruteRanger()
 	for _, ranger := range rangers {
 		configureRangerWithAWSRanges(t, ranger)
@@ -90,6 +110,42 @@ func testContainingNetworksAgainstBase(t *testing.T, iterations int, ipGen ipGen
 			for _, network := range actual {
 				assert.Contains(t, expected, network)
 			}
+			actual, err = ranger.ContainingPrefixes(nn.ToAddr())
+			assert.NoError(t, err)
+			assert.Equal(t, len(expected), len(actual))
+			for _, network := range actual {
+				assert.Contains(t, expected, network)
+			}
+		}
+	}
+}
+
+func testMatchAgainstBase(t *testing.T, iterations int, ipGen ipGenerator) {
+	if testing.Short() {
+		t.Skip("Skipping memory test in `-short` mode")
+	}
+	rangers := []Ranger{NewPCTrieRanger(), NewSnapshotPCTrieRanger()}
+	baseRanger := newBruteRanger()
+	for _, ranger := range rangers {
+		configureRangerWithAWSRanges(t, ranger)
+	}
+	configureRangerWithAWSRanges(t, baseRanger)
+
+	for i := 0; i < iterations; i++ {
+		nn := ipGen()
+		expectedLongest, expectedFound, err := baseRanger.LongestMatch(nn.ToIP())
+		assert.NoError(t, err)
+		expectedShortest, _, err := baseRanger.ShortestMatch(nn.ToIP())
+		assert.NoError(t, err)
+		for _, ranger := range rangers {
+			actual, found, err := ranger.LongestMatch(nn.ToIP())
+			assert.NoError(t, err)
+			assert.Equal(t, expectedFound, found)
+			assert.Equal(t, expectedLongest, actual)
+			actual, found, err = ranger.ShortestMatchAddr(nn.ToAddr())
+			assert.NoError(t, err)
+			assert.Equal(t, expectedFound, found)
+			assert.Equal(t, expectedShortest, actual)
 		}
 	}
 }
@@ -98,7 +154,7 @@ func testCoversNetworksAgainstBase(t *testing.T, iterations int, netGen networkG
 	if testing.Short() {
 		t.Skip("Skipping memory test in `-short` mode")
 	}
-	rangers := []Ranger{NewPCTrieRanger()}
+	rangers := []Ranger{NewPCTrieRanger(), NewSnapshotPCTrieRanger()}
 	baseRanger := newBruteRanger()
 	for _, ranger := range rangers {
 		configureRangerWithAWSRanges(t, ranger)
@@ -120,6 +176,411 @@ func testCoversNetworksAgainstBase(t *testing.T, iterations int, netGen networkG
 	}
 }
 
+func TestTypedPCTrieRanger(t *testing.T) {
+	ranger := NewTypedPCTrieRanger[int]()
+	_, network1, _ := net.ParseCIDR("192.168.0.0/16")
+	_, network2, _ := net.ParseCIDR("192.168.1.0/24")
+	_, network3, _ := net.ParseCIDR("8000::/96")
+	assert.NoError(t, ranger.Insert(*network1, 1))
+	assert.NoError(t, ranger.Insert(*network2, 2))
+	assert.NoError(t, ranger.Insert(*network3, 3))
+	assert.Equal(t, 3, ranger.Len())
+
+	entries, err := ranger.ContainingNetworks(net.ParseIP("192.168.1.1"))
+	assert.NoError(t, err)
+	assert.Equal(t, []Entry[int]{{*network1, 1}, {*network2, 2}}, entries)
+
+	entries, err = ranger.CoveredNetworks(*AllIPv6)
+	assert.NoError(t, err)
+	assert.Equal(t, []Entry[int]{{*network3, 3}}, entries)
+
+	value, removed, err := ranger.Remove(*network2)
+	assert.NoError(t, err)
+	assert.True(t, removed)
+	assert.Equal(t, 2, value)
+
+	value, removed, err = ranger.Remove(*network2)
+	assert.NoError(t, err)
+	assert.False(t, removed)
+	assert.Equal(t, 0, value)
+	assert.Equal(t, 2, ranger.Len())
+}
+
+func TestWalkAgainstBase(t *testing.T) {
+	rangers := []Ranger{NewPCTrieRanger(), NewSnapshotPCTrieRanger()}
+	baseRanger := newBruteRanger()
+	for _, ranger := range rangers {
+		configureRangerWithAWSRanges(t, ranger)
+	}
+	configureRangerWithAWSRanges(t, baseRanger)
+
+	var expected []RangerEntry
+	for entry := range baseRanger.All() {
+		expected = append(expected, entry)
+	}
+	assert.Equal(t, baseRanger.Len(), len(expected))
+	for _, ranger := range rangers {
+		var actual []RangerEntry
+		ranger.Walk(func(entry RangerEntry) bool {
+			actual = append(actual, entry)
+			return true
+		})
+		assert.ElementsMatch(t, expected, actual)
+
+		// Stop walking as soon as the first IPv6 entry is seen.
+		count := 0
+		for entry := range ranger.All() {
+			count++
+			if network := entry.Network(); network.IP.To4() == nil {
+				break
+			}
+		}
+		assert.Equal(t, len(baseRanger.(*bruteRanger).ipV4Entries)+1, count)
+	}
+}
+
+func TestOrderedAgainstBase(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping memory test in `-short` mode")
+	}
+	rangers := []Ranger{NewPCTrieRanger(), NewSnapshotPCTrieRanger()}
+	baseRanger := newBruteRanger()
+	for _, ranger := range rangers {
+		configureRangerWithAWSRanges(t, ranger)
+	}
+	configureRangerWithAWSRanges(t, baseRanger)
+
+	var expected, expectedBackward []RangerEntry
+	baseRanger.Walk(func(entry RangerEntry) bool {
+		expected = append(expected, entry)
+		return true
+	})
+	for entry := range baseRanger.Backward() {
+		expectedBackward = append(expectedBackward, entry)
+	}
+	for _, ranger := range rangers {
+		var actual, actualBackward []RangerEntry
+		for entry := range ranger.All() {
+			actual = append(actual, entry)
+		}
+		for entry := range ranger.Backward() {
+			actualBackward = append(actualBackward, entry)
+		}
+		assert.Equal(t, expected, actual)
+		assert.Equal(t, expectedBackward, actualBackward)
+	}
+
+	netGens := []networkGenerator{
+		resizedIPNetGenFactory(ipV4AWSRangesIPNets),
+		resizedIPNetGenFactory(ipV6AWSRangesIPNets),
+	}
+	for i := 0; i < 2000; i++ {
+		network := netGens[i%len(netGens)]()
+		expectedNext, expectedNextFound, err := baseRanger.Next(network.IPNet)
+		assert.NoError(t, err)
+		expectedPrev, expectedPrevFound, err := baseRanger.Prev(network.IPNet)
+		assert.NoError(t, err)
+		start := network.IP
+		end := rnet.NewNetworkNumber(rnet.NewNetwork(*pickIPNet(network)).IP).Next().ToIP()
+		if rnet.NewNetworkNumber(end).Compare(rnet.NewNetworkNumber(start)) < 0 {
+			start, end = end, start
+		}
+		expectedBetween, err := baseRanger.EntriesBetween(start, end)
+		assert.NoError(t, err)
+		for _, ranger := range rangers {
+			actual, found, err := ranger.Next(network.IPNet)
+			assert.NoError(t, err)
+			assert.Equal(t, expectedNextFound, found)
+			assert.Equal(t, expectedNext, actual)
+			actual, found, err = ranger.Prev(network.IPNet)
+			assert.NoError(t, err)
+			assert.Equal(t, expectedPrevFound, found)
+			assert.Equal(t, expectedPrev, actual)
+			between, err := ranger.EntriesBetween(start, end)
+			assert.NoError(t, err)
+			assert.Equal(t, expectedBetween, between)
+		}
+	}
+}
+
+type taggedRangerEntry struct {
+	ipNet net.IPNet
+	tags  []string
+}
+
+func (e *taggedRangerEntry) Network() net.IPNet {
+	return e.ipNet
+}
+
+func TestUpsertAndSwap(t *testing.T) {
+	for _, ranger := range []Ranger{NewPCTrieRanger(), NewSnapshotPCTrieRanger(), newBruteRanger()} {
+		network := *parseCIDRUnsafe("192.168.0.0/24")
+		first := &taggedRangerEntry{network, []string{"feed1"}}
+		second := &taggedRangerEntry{network, []string{"feed2"}}
+
+		previous, replaced, err := ranger.Swap(first)
+		assert.NoError(t, err)
+		assert.False(t, replaced)
+		assert.Nil(t, previous)
+
+		previous, replaced, err = ranger.Swap(second)
+		assert.NoError(t, err)
+		assert.True(t, replaced)
+		assert.Same(t, first, previous)
+		entry, _, _ := ranger.Get(network)
+		assert.Same(t, second, entry)
+
+		merge := func(tag string) func(RangerEntry, bool) RangerEntry {
+			return func(old RangerEntry, exists bool) RangerEntry {
+				merged := &taggedRangerEntry{ipNet: network}
+				if exists {
+					merged.tags = append(merged.tags, old.(*taggedRangerEntry).tags...)
+				}
+				merged.tags = append(merged.tags, tag)
+				return merged
+			}
+		}
+		assert.NoError(t, ranger.Upsert(network, merge("feed3")))
+		entry, _, _ = ranger.Get(network)
+		assert.Equal(t, []string{"feed2", "feed3"}, entry.(*taggedRangerEntry).tags)
+
+		other := *parseCIDRUnsafe("8000::/16")
+		assert.NoError(t, ranger.Upsert(other, merge("feed1")))
+		entry, _, _ = ranger.Get(other)
+		assert.Equal(t, []string{"feed1"}, entry.(*taggedRangerEntry).tags)
+		assert.Equal(t, 2, ranger.Len())
+
+		// Insert replaces the stored entry in every implementation.
+		assert.NoError(t, ranger.Insert(first))
+		entry, _, _ = ranger.Get(network)
+		assert.Same(t, first, entry)
+	}
+}
+
+func TestRemoveCoveredAndCompareAndRemove(t *testing.T) {
+	for _, ranger := range []Ranger{NewPCTrieRanger(), NewSnapshotPCTrieRanger(), newBruteRanger()} {
+		for _, network := range []string{"192.168.0.0/16", "203.0.113.0/25", "203.0.113.128/26", "8000::/16"} {
+			assert.NoError(t, ranger.InsertPrefix(netip.MustParsePrefix(network)))
+		}
+		removed, err := ranger.RemoveCovered(*parseCIDRUnsafe("203.0.113.0/24"))
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []RangerEntry{
+			NewBasicRangerEntry(*parseCIDRUnsafe("203.0.113.0/25")),
+			NewBasicRangerEntry(*parseCIDRUnsafe("203.0.113.128/26")),
+		}, removed)
+		assert.Equal(t, 2, ranger.Len())
+
+		network := *parseCIDRUnsafe("192.168.0.0/16")
+		stored, _, _ := ranger.Get(network)
+		removedEntry, err := ranger.CompareAndRemove(network, NewBasicRangerEntry(network))
+		assert.NoError(t, err)
+		assert.False(t, removedEntry)
+		removedEntry, err = ranger.CompareAndRemove(network, stored)
+		assert.NoError(t, err)
+		assert.True(t, removedEntry)
+		assert.Equal(t, 1, ranger.Len())
+
+		ranger.Clear()
+		assert.Equal(t, 0, ranger.Len())
+		contains, err := ranger.Contains(net.ParseIP("8000::1"))
+		assert.NoError(t, err)
+		assert.False(t, contains)
+	}
+}
+
+func TestLookupEntry(t *testing.T) {
+	for _, ranger := range []Ranger{NewPCTrieRanger(), NewSnapshotPCTrieRanger(), newBruteRanger()} {
+		for _, network := range []string{"10.0.0.0/8", "10.1.0.0/16"} {
+			assert.NoError(t, ranger.Insert(&taggedRangerEntry{ipNet: *parseCIDRUnsafe(network), tags: []string{network}}))
+		}
+		assert.NoError(t, ranger.InsertPrefix(netip.MustParsePrefix("10.1.2.0/24")))
+
+		entry, err := LookupEntry[*taggedRangerEntry](ranger, net.ParseIP("10.1.2.3"))
+		assert.NoError(t, err)
+		if assert.NotNil(t, entry) {
+			assert.Equal(t, []string{"10.1.0.0/16"}, entry.tags)
+		}
+		entry, err = LookupEntry[*taggedRangerEntry](ranger, net.ParseIP("11.0.0.1"))
+		assert.NoError(t, err)
+		assert.Nil(t, entry)
+		_, err = LookupEntry[*taggedRangerEntry](ranger, nil)
+		assert.Error(t, err)
+		entry, err = LookupEntry[*taggedRangerEntry](ranger, net.ParseIP("10.1.5.5"))
+		assert.NoError(t, err)
+		if assert.NotNil(t, entry) {
+			assert.Equal(t, []string{"10.1.0.0/16"}, entry.tags)
+		}
+	}
+
+	// A longest match of the looked up type needs no containing networks.
+	ranger := NewPCTrieRanger()
+	assert.NoError(t, ranger.Insert(&taggedRangerEntry{ipNet: *parseCIDRUnsafe("10.0.0.0/8")}))
+	ip := net.ParseIP("10.1.2.3")
+	assert.Zero(t, testing.AllocsPerRun(100, func() {
+		LookupEntry[*taggedRangerEntry](ranger, ip)
+	}))
+}
+
+// listedRangerEntry is a value type entry that is not comparable.
+type listedRangerEntry struct {
+	ipNet net.IPNet
+	lists []string
+}
+
+func (e listedRangerEntry) Network() net.IPNet {
+	return e.ipNet
+}
+
+func TestCompareAndRemoveNotComparable(t *testing.T) {
+	for _, ranger := range []Ranger{NewPCTrieRanger(), NewSnapshotPCTrieRanger(), newBruteRanger()} {
+		network := *parseCIDRUnsafe("192.168.0.0/16")
+		entry := listedRangerEntry{ipNet: network, lists: []string{"drop"}}
+		assert.NoError(t, ranger.Insert(entry))
+
+		assert.NotPanics(t, func() {
+			removed, err := ranger.CompareAndRemove(network, entry)
+			assert.NoError(t, err)
+			assert.False(t, removed)
+		})
+		removed, err := ranger.CompareAndRemove(network, NewBasicRangerEntry(network))
+		assert.NoError(t, err)
+		assert.False(t, removed)
+		assert.Equal(t, 1, ranger.Len())
+	}
+}
+
+func TestNextPrevAcrossVersions(t *testing.T) {
+	for _, ranger := range []Ranger{NewPCTrieRanger(), NewSnapshotPCTrieRanger(), newBruteRanger()} {
+		ranger.InsertPrefix(netip.MustParsePrefix("10.0.0.0/8"))
+		ranger.InsertPrefix(netip.MustParsePrefix("8000::/16"))
+
+		entry, found, err := ranger.Next(*parseCIDRUnsafe("10.0.0.0/8"))
+		assert.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, *parseCIDRUnsafe("8000::/16"), entry.Network())
+
+		entry, found, err = ranger.Prev(*parseCIDRUnsafe("8000::/16"))
+		assert.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, *parseCIDRUnsafe("10.0.0.0/8"), entry.Network())
+
+		_, err = ranger.EntriesBetween(net.ParseIP("10.0.0.0"), net.ParseIP("8000::"))
+		assert.Equal(t, ErrInvalidNetworkNumberInput, err)
+		_, err = ranger.EntriesBetween(nil, net.ParseIP("10.0.0.0"))
+		assert.Equal(t, ErrInvalidNetworkNumberInput, err)
+	}
+}
+
+// pickIPNet returns a random network from the AWS ranges of the same version
+// as network.
+func pickIPNet(network rnet.Network) *net.IPNet {
+	if network.IP.To4() != nil {
+		return ipV4AWSRangesIPNets[rand.Intn(len(ipV4AWSRangesIPNets))]
+	}
+	return ipV6AWSRangesIPNets[rand.Intn(len(ipV6AWSRangesIPNets))]
+}
+
+func TestPrefixMethods(t *testing.T) {
+	for _, ranger := range []Ranger{NewPCTrieRanger(), NewSnapshotPCTrieRanger(), newBruteRanger()} {
+		assert.NoError(t, ranger.InsertPrefix(netip.MustParsePrefix("192.168.0.0/16")))
+		assert.NoError(t, ranger.InsertPrefix(netip.MustParsePrefix("::ffff:10.0.0.0/104")))
+		assert.NoError(t, ranger.InsertPrefix(netip.MustParsePrefix("fe80::/10")))
+		assert.Equal(t, ErrInvalidNetworkInput, ranger.InsertPrefix(netip.Prefix{}))
+		assert.Equal(t, 3, ranger.Len())
+
+		cases := []struct {
+			addr     netip.Addr
+			networks []string
+			name     string
+		}{
+			{netip.MustParseAddr("192.168.1.1"), []string{"192.168.0.0/16"}, "IPv4"},
+			{netip.MustParseAddr("::ffff:192.168.1.1"), []string{"192.168.0.0/16"}, "IPv4-mapped IPv6 addr"},
+			{netip.MustParseAddr("10.1.1.1"), []string{"10.0.0.0/8"}, "IPv4-mapped IPv6 prefix"},
+			{netip.MustParseAddr("fe80::1%eth0"), []string{"fe80::/10"}, "IPv6 with zone"},
+			{netip.MustParseAddr("2001:db8::1"), nil, "IPv6 not contained"},
+		}
+		for _, tc := range cases {
+			t.Run(tc.name, func(t *testing.T) {
+				contains, err := ranger.ContainsAddr(tc.addr)
+				assert.NoError(t, err)
+				assert.Equal(t, len(tc.networks) > 0, contains)
+				entries, err := ranger.ContainingPrefixes(tc.addr)
+				assert.NoError(t, err)
+				var networks []string
+				for _, entry := range entries {
+					network := entry.Network()
+					networks = append(networks, network.String())
+				}
+				assert.Equal(t, tc.networks, networks)
+			})
+		}
+
+		_, err := ranger.ContainsAddr(netip.Addr{})
+		assert.Error(t, err)
+
+		entries, err := ranger.CoveredPrefixes(netip.MustParsePrefix("192.0.0.0/8"))
+		assert.NoError(t, err)
+		assert.Len(t, entries, 1)
+
+		entry, found, err := ranger.GetPrefix(netip.MustParsePrefix("::ffff:10.0.0.0/104"))
+		assert.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, *parseCIDRUnsafe("10.0.0.0/8"), entry.Network())
+
+		removed, err := ranger.RemovePrefix(netip.MustParsePrefix("10.0.0.0/8"))
+		assert.NoError(t, err)
+		assert.NotNil(t, removed)
+		assert.Equal(t, 2, ranger.Len())
+	}
+}
+
+func TestContainsAddrAllocs(t *testing.T) {
+	ranger := NewPCTrieRanger()
+	configureRangerWithAWSRanges(t, ranger)
+	for _, addr := range []netip.Addr{
+		netip.MustParseAddr("52.95.110.1"),
+		netip.MustParseAddr("123.123.123.123"),
+		netip.MustParseAddr("2620:107:300f::36b7:ff81"),
+	} {
+		allocs := testing.AllocsPerRun(100, func() {
+			ranger.ContainsAddr(addr)
+		})
+		assert.Equal(t, float64(0), allocs, addr.String())
+		allocs = testing.AllocsPerRun(100, func() {
+			ranger.LongestMatchAddr(addr)
+		})
+		assert.Equal(t, float64(0), allocs, addr.String())
+	}
+}
+
+func testCoveringNetworksAgainstBase(t *testing.T, iterations int, netGen networkGenerator) {
+	if testing.Short() {
+		t.Skip("Skipping memory test in `-short` mode")
+	}
+	rangers := []Ranger{NewPCTrieRanger(), NewSnapshotPCTrieRanger()}
+	baseRanger := newBruteRanger()
+	for _, ranger := range rangers {
+		configureRangerWithAWSRanges(t, ranger)
+	}
+	configureRangerWithAWSRanges(t, baseRanger)
+
+	for i := 0; i < iterations; i++ {
+		network := netGen()
+		expectedCovering, err := baseRanger.CoveringNetworks(network.IPNet)
+		assert.NoError(t, err)
+		expectedOverlapping, err := baseRanger.OverlappingNetworks(network.IPNet)
+		assert.NoError(t, err)
+		for _, ranger := range rangers {
+			actual, err := ranger.CoveringNetworks(network.IPNet)
+			assert.NoError(t, err)
+			assert.ElementsMatch(t, expectedCovering, actual)
+			actual, err = ranger.OverlappingNetworks(network.IPNet)
+			assert.NoError(t, err)
+			assert.ElementsMatch(t, expectedOverlapping, actual)
+		}
+	}
+}
+
 /*
  ******************************************************************
  Benchmarks.
@@ -182,6 +643,26 @@ func BenchmarkBruteRangerMissContainingNetworksIPv6UsingAWSRanges(b *testing.B)
 	benchmarkContainingNetworksUsingAWSRanges(b, net.ParseIP("2620::ffff"), newBruteRanger())
 }
 
+func BenchmarkPCTrieHitContainsAddrIPv4UsingAWSRanges(b *testing.B) {
+	ranger := NewPCTrieRanger()
+	configureRangerWithAWSRanges(b, ranger)
+	addr := netip.MustParseAddr("52.95.110.1")
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		ranger.ContainsAddr(addr)
+	}
+}
+
+func BenchmarkPCTrieHitLongestMatchIPv4UsingAWSRanges(b *testing.B) {
+	ranger := NewPCTrieRanger()
+	configureRangerWithAWSRanges(b, ranger)
+	ip := net.ParseIP("52.95.110.1")
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		ranger.LongestMatch(ip)
+	}
+}
+
 func BenchmarkNewPathprefixTriev4(b *testing.B) {
 	benchmarkNewPathprefixTrie(b, "192.128.0.0/24")
 }
@@ -213,7 +694,7 @@ func benchmarkNewPathprefixTrie(b *testing.B, net1 string) {
 
 	b.ResetTimer()
 	for n := 0; n < b.N; n++ {
-		newPathprefixTrie(n1, uOnes)
+		newPathprefixTrie[RangerEntry](n1, uOnes)
 	}
 }
 
@@ -252,6 +733,23 @@ func randomIPNetGenFactory(pool []*net.IPNet) networkGenerator {
 	}
 }
 
+// resizedIPNetGenFactory generates networks from the pool with their prefix
+// length randomly shortened or extended, so that they both cover and are
+// covered by networks in the pool.
+func resizedIPNetGenFactory(pool []*net.IPNet) networkGenerator {
+	return func() rnet.Network {
+		network := rnet.NewNetwork(*pool[rand.Intn(len(pool))])
+		ones, bits := network.IPNet.Mask.Size()
+		ones += rand.Intn(17) - 8
+		if ones < 0 {
+			ones = 0
+		} else if ones > bits {
+			ones = bits
+		}
+		return network.Masked(ones)
+	}
+}
+
 type AWSRanges struct {
 	Prefixes     []Prefix     `json:"prefixes"`
 	IPv6Prefixes []IPv6Prefix `json:"ipv6_prefixes"`
@@ -0,0 +1,201 @@
+/*
+Package cloud loads the IP ranges published by cloud providers into a
+cidranger.Ranger, to find out which provider, region and service an IP
+belongs to.
+
+The supported documents are the AWS ip-ranges.json, the GCP cloud.json and
+goog.json, and the Azure Service Tags JSON files.  Providers list networks
+several times, once for a broad service and again for the services nested in
+it, or once globally and again per region.  Such networks get a single Entry,
+keeping the most specific region and service listed.
+*/
+package cloud
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/yl2chen/cidranger"
+)
+
+// Provider identifies a cloud provider.
+type Provider string
+
+// Supported providers.
+const (
+	AWS   Provider = "aws"
+	GCP   Provider = "gcp"
+	Azure Provider = "azure"
+)
+
+// genericServices are the services providers list their whole ranges under,
+// replaced by any other service listed for the same network.
+var genericServices = map[Provider]string{
+	AWS:   "AMAZON",
+	Azure: "AzureCloud",
+}
+
+// Entry is a cidranger.RangerEntry for a network published by a provider.
+// Region and Service are empty when the provider does not tell.
+type Entry struct {
+	ipNet    net.IPNet
+	Provider Provider
+	Region   string
+	Service  string
+}
+
+// Network returns the network of entry.
+func (e *Entry) Network() net.IPNet {
+	return e.ipNet
+}
+
+// Lookup returns the Entry of the most specific provider network containing
+// given ip, or nil if no provider lists it.
+func Lookup(ranger cidranger.Ranger, ip net.IP) (*Entry, error) {
+	return cidranger.LookupEntry[*Entry](ranger, ip)
+}
+
+type awsDocument struct {
+	Prefixes []struct {
+		IPPrefix string `json:"ip_prefix"`
+		Region   string `json:"region"`
+		Service  string `json:"service"`
+	} `json:"prefixes"`
+	IPv6Prefixes []struct {
+		IPv6Prefix string `json:"ipv6_prefix"`
+		Region     string `json:"region"`
+		Service    string `json:"service"`
+	} `json:"ipv6_prefixes"`
+}
+
+// LoadAWS inserts the networks of an AWS ip-ranges.json document into ranger.
+func LoadAWS(r io.Reader, ranger cidranger.Ranger) error {
+	var document awsDocument
+	if err := json.NewDecoder(r).Decode(&document); err != nil {
+		return err
+	}
+	var entries []*Entry
+	for _, prefix := range document.Prefixes {
+		entry, err := newEntry(prefix.IPPrefix, AWS, prefix.Region, prefix.Service)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry)
+	}
+	for _, prefix := range document.IPv6Prefixes {
+		entry, err := newEntry(prefix.IPv6Prefix, AWS, prefix.Region, prefix.Service)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry)
+	}
+	return insert(ranger, entries)
+}
+
+type gcpDocument struct {
+	Prefixes []struct {
+		IPv4Prefix string `json:"ipv4Prefix"`
+		IPv6Prefix string `json:"ipv6Prefix"`
+		Service    string `json:"service"`
+		Scope      string `json:"scope"`
+	} `json:"prefixes"`
+}
+
+// LoadGCP inserts the networks of a GCP cloud.json or goog.json document into
+// ranger, the scope of cloud.json prefixes being their region.
+func LoadGCP(r io.Reader, ranger cidranger.Ranger) error {
+	var document gcpDocument
+	if err := json.NewDecoder(r).Decode(&document); err != nil {
+		return err
+	}
+	var entries []*Entry
+	for _, prefix := range document.Prefixes {
+		cidr := prefix.IPv4Prefix
+		if cidr == "" {
+			cidr = prefix.IPv6Prefix
+		}
+		entry, err := newEntry(cidr, GCP, prefix.Scope, prefix.Service)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry)
+	}
+	return insert(ranger, entries)
+}
+
+type azureDocument struct {
+	Values []struct {
+		Name       string `json:"name"`
+		Properties struct {
+			Region          string   `json:"region"`
+			SystemService   string   `json:"systemService"`
+			AddressPrefixes []string `json:"addressPrefixes"`
+		} `json:"properties"`
+	} `json:"values"`
+}
+
+// LoadAzure inserts the networks of an Azure Service Tags document into
+// ranger.  The service of a tag is its system service, or the tag name up to
+// its region suffix for tags without one, such as AzureCloud.eastus.
+func LoadAzure(r io.Reader, ranger cidranger.Ranger) error {
+	var document azureDocument
+	if err := json.NewDecoder(r).Decode(&document); err != nil {
+		return err
+	}
+	var entries []*Entry
+	for _, tag := range document.Values {
+		service := tag.Properties.SystemService
+		if service == "" {
+			service, _, _ = strings.Cut(tag.Name, ".")
+		}
+		for _, cidr := range tag.Properties.AddressPrefixes {
+			entry, err := newEntry(cidr, Azure, tag.Properties.Region, service)
+			if err != nil {
+				return err
+			}
+			entries = append(entries, entry)
+		}
+	}
+	return insert(ranger, entries)
+}
+
+func newEntry(cidr string, provider Provider, region, service string) (*Entry, error) {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, cidranger.ErrInvalidNetworkInput
+	}
+	return &Entry{ipNet: *network, Provider: provider, Region: region, Service: service}, nil
+}
+
+// insert inserts entries into ranger, merging the entries of the same network.
+func insert(ranger cidranger.Ranger, entries []*Entry) error {
+	for _, entry := range entries {
+		err := ranger.Upsert(entry.ipNet, func(old cidranger.RangerEntry, exists bool) cidranger.RangerEntry {
+			if previous, ok := old.(*Entry); ok && exists && previous.Provider == entry.Provider {
+				return merge(previous, entry)
+			}
+			return entry
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// merge returns an Entry with the most specific region and service of given
+// entries of the same network.
+func merge(previous, entry *Entry) *Entry {
+	merged := *previous
+	if merged.Region == "" {
+		merged.Region = entry.Region
+	}
+	if merged.Service == "" || merged.Service == genericServices[merged.Provider] {
+		if entry.Service != "" {
+			merged.Service = entry.Service
+		}
+	}
+	return &merged
+}
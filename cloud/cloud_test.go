@@ -0,0 +1,150 @@
+package cloud
+
+import (
+	"net"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yl2chen/cidranger"
+)
+
+func TestLoadAWS(t *testing.T) {
+	file, err := os.Open("../testdata/aws_ip_ranges.json")
+	assert.NoError(t, err)
+	defer file.Close()
+
+	ranger := cidranger.NewPCTrieRanger()
+	assert.NoError(t, LoadAWS(file, ranger))
+	assert.Greater(t, ranger.Len(), 0)
+
+	// 52.95.110.0/24 is listed under AMAZON and again under ROUTE53.
+	entry, err := Lookup(ranger, net.ParseIP("52.95.110.1"))
+	assert.NoError(t, err)
+	if assert.NotNil(t, entry) {
+		assert.Equal(t, AWS, entry.Provider)
+		assert.Equal(t, "GLOBAL", entry.Region)
+		assert.Equal(t, "ROUTE53", entry.Service)
+		network := entry.Network()
+		assert.Equal(t, "52.95.110.0/24", network.String())
+	}
+
+	entry, err = Lookup(ranger, net.ParseIP("13.56.0.1"))
+	assert.NoError(t, err)
+	if assert.NotNil(t, entry) {
+		assert.Equal(t, "us-west-1", entry.Region)
+	}
+
+	entry, err = Lookup(ranger, net.ParseIP("10.0.0.1"))
+	assert.NoError(t, err)
+	assert.Nil(t, entry)
+}
+
+func TestLoadGCP(t *testing.T) {
+	document := `{
+  "syncToken": "1700000000000",
+  "creationTime": "2023-11-14T22:13:20.000000",
+  "prefixes": [{
+    "ipv4Prefix": "34.80.0.0/15",
+    "service": "Google Cloud",
+    "scope": "asia-east1"
+  }, {
+    "ipv6Prefix": "2600:1900:4030::/44",
+    "service": "Google Cloud",
+    "scope": "asia-east1"
+  }]
+}`
+	ranger := cidranger.NewPCTrieRanger()
+	assert.NoError(t, LoadGCP(strings.NewReader(document), ranger))
+	assert.Equal(t, 2, ranger.Len())
+
+	// goog.json lists Google wide ranges without service nor scope.
+	assert.NoError(t, LoadGCP(strings.NewReader(`{"prefixes": [{"ipv4Prefix": "34.0.0.0/9"}]}`), ranger))
+	entry, err := Lookup(ranger, net.ParseIP("2600:1900:4030::1"))
+	assert.NoError(t, err)
+	if assert.NotNil(t, entry) {
+		assert.Equal(t, GCP, entry.Provider)
+		assert.Equal(t, "asia-east1", entry.Region)
+		assert.Equal(t, "Google Cloud", entry.Service)
+	}
+	entry, err = Lookup(ranger, net.ParseIP("34.1.0.1"))
+	assert.NoError(t, err)
+	if assert.NotNil(t, entry) {
+		assert.Equal(t, "", entry.Region)
+		assert.Equal(t, "", entry.Service)
+	}
+}
+
+func TestLoadAzure(t *testing.T) {
+	document := `{
+  "changeNumber": 250,
+  "cloud": "Public",
+  "values": [{
+    "name": "AzureCloud",
+    "id": "AzureCloud",
+    "properties": {
+      "region": "",
+      "platform": "Azure",
+      "systemService": "",
+      "addressPrefixes": ["20.38.96.0/19", "40.112.0.0/13"]
+    }
+  }, {
+    "name": "AzureCloud.eastus",
+    "id": "AzureCloud.eastus",
+    "properties": {
+      "region": "eastus",
+      "platform": "Azure",
+      "systemService": "",
+      "addressPrefixes": ["20.38.96.0/19"]
+    }
+  }, {
+    "name": "Storage.EastUS",
+    "id": "Storage.EastUS",
+    "properties": {
+      "region": "eastus",
+      "platform": "Azure",
+      "systemService": "AzureStorage",
+      "addressPrefixes": ["20.38.96.0/19", "2603:1030:40b::/48"]
+    }
+  }]
+}`
+	ranger := cidranger.NewPCTrieRanger()
+	assert.NoError(t, LoadAzure(strings.NewReader(document), ranger))
+	assert.Equal(t, 3, ranger.Len())
+
+	entry, err := Lookup(ranger, net.ParseIP("20.38.100.1"))
+	assert.NoError(t, err)
+	if assert.NotNil(t, entry) {
+		assert.Equal(t, Azure, entry.Provider)
+		assert.Equal(t, "eastus", entry.Region)
+		assert.Equal(t, "AzureStorage", entry.Service)
+	}
+	entry, err = Lookup(ranger, net.ParseIP("40.112.0.1"))
+	assert.NoError(t, err)
+	if assert.NotNil(t, entry) {
+		assert.Equal(t, "", entry.Region)
+		assert.Equal(t, "AzureCloud", entry.Service)
+	}
+}
+
+func TestLoadInvalidInput(t *testing.T) {
+	ranger := cidranger.NewPCTrieRanger()
+	err := LoadAWS(strings.NewReader(`{"prefixes": [{"ip_prefix": "10.0.0.0/33"}]}`), ranger)
+	assert.Equal(t, cidranger.ErrInvalidNetworkInput, err)
+	assert.Error(t, LoadAzure(strings.NewReader(`{"values": [`), ranger))
+	assert.Equal(t, 0, ranger.Len())
+}
+
+func TestLookupSkipsOtherEntries(t *testing.T) {
+	ranger := cidranger.NewPCTrieRanger()
+	assert.NoError(t, LoadGCP(strings.NewReader(`{"prefixes": [{"ipv4Prefix": "34.80.0.0/15", "scope": "asia-east1"}]}`), ranger))
+	_, network, _ := net.ParseCIDR("34.80.1.0/24")
+	assert.NoError(t, ranger.Insert(cidranger.NewBasicRangerEntry(*network)))
+
+	entry, err := Lookup(ranger, net.ParseIP("34.80.1.1"))
+	assert.NoError(t, err)
+	if assert.NotNil(t, entry) {
+		assert.Equal(t, "asia-east1", entry.Region)
+	}
+}
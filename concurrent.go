@@ -0,0 +1,293 @@
+package cidranger
+
+import (
+	"iter"
+	"net"
+	"net/netip"
+	"sync"
+)
+
+// concurrentRanger wraps a Ranger with a sync.RWMutex, making it safe for
+// concurrent use.  Lookups hold the read lock so they run in parallel, while
+// insertions and removals hold the write lock.
+//
+// Walk, All and Backward iterate over a copy of the entries taken under the
+// read lock, fn is called without holding the lock.  Iteration therefore sees
+// the entries as of when it started, unaffected by mutations made during the
+// iteration, and fn is free to mutate the ranger.
+type concurrentRanger struct {
+	mu     sync.RWMutex
+	ranger Ranger
+}
+
+// NewConcurrentRanger returns a Ranger backed by given ranger that is safe for
+// concurrent use by multiple goroutines.  The given ranger must not be used
+// directly afterwards.
+func NewConcurrentRanger(ranger Ranger) Ranger {
+	return &concurrentRanger{ranger: ranger}
+}
+
+// Insert inserts a RangerEntry into ranger.
+func (c *concurrentRanger) Insert(entry RangerEntry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ranger.Insert(entry)
+}
+
+// Upsert stores the RangerEntry returned by fn for given network, fn is called
+// while holding the write lock and must not use ranger.
+func (c *concurrentRanger) Upsert(network net.IPNet, fn func(old RangerEntry, exists bool) RangerEntry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ranger.Upsert(network, fn)
+}
+
+// Swap inserts a RangerEntry into ranger, returning the RangerEntry it
+// replaced.
+func (c *concurrentRanger) Swap(entry RangerEntry) (RangerEntry, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ranger.Swap(entry)
+}
+
+// InsertPrefix inserts a basic RangerEntry for given prefix into ranger.
+func (c *concurrentRanger) InsertPrefix(prefix netip.Prefix) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ranger.InsertPrefix(prefix)
+}
+
+// Remove removes a RangerEntry identified by given network from ranger.
+func (c *concurrentRanger) Remove(network net.IPNet) (RangerEntry, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ranger.Remove(network)
+}
+
+// RemovePrefix removes a RangerEntry identified by given prefix from ranger.
+func (c *concurrentRanger) RemovePrefix(prefix netip.Prefix) (RangerEntry, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ranger.RemovePrefix(prefix)
+}
+
+// CompareAndRemove removes the RangerEntry stored for given network only if it
+// is expected, atomically.
+func (c *concurrentRanger) CompareAndRemove(network net.IPNet, expected RangerEntry) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ranger.CompareAndRemove(network, expected)
+}
+
+// RemoveCovered removes all RangerEntry(s) covered by given network from
+// ranger.
+func (c *concurrentRanger) RemoveCovered(network net.IPNet) ([]RangerEntry, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ranger.RemoveCovered(network)
+}
+
+// Clear removes all RangerEntry(s) from ranger.
+func (c *concurrentRanger) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ranger.Clear()
+}
+
+// commitBatch commits given batch to ranger while holding the write lock.
+func (c *concurrentRanger) commitBatch(b *Batch) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return b.commit(c.ranger)
+}
+
+// Get returns the RangerEntry stored for exactly given network.
+func (c *concurrentRanger) Get(network net.IPNet) (RangerEntry, bool, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ranger.Get(network)
+}
+
+// GetPrefix returns the RangerEntry stored for exactly given prefix.
+func (c *concurrentRanger) GetPrefix(prefix netip.Prefix) (RangerEntry, bool, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ranger.GetPrefix(prefix)
+}
+
+// Contains returns bool indicating whether given ip is contained by any
+// network in ranger.
+func (c *concurrentRanger) Contains(ip net.IP) (bool, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ranger.Contains(ip)
+}
+
+// ContainsAddr returns bool indicating whether given addr is contained by any
+// network in ranger.
+func (c *concurrentRanger) ContainsAddr(addr netip.Addr) (bool, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ranger.ContainsAddr(addr)
+}
+
+// ContainingNetworks returns all RangerEntry(s) that given ip contained in.
+func (c *concurrentRanger) ContainingNetworks(ip net.IP) ([]RangerEntry, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ranger.ContainingNetworks(ip)
+}
+
+// ContainingPrefixes returns all RangerEntry(s) that given addr contained in.
+func (c *concurrentRanger) ContainingPrefixes(addr netip.Addr) ([]RangerEntry, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ranger.ContainingPrefixes(addr)
+}
+
+// LongestMatch returns the most specific RangerEntry containing given ip.
+func (c *concurrentRanger) LongestMatch(ip net.IP) (RangerEntry, bool, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ranger.LongestMatch(ip)
+}
+
+// LongestMatchAddr returns the most specific RangerEntry containing given
+// addr.
+func (c *concurrentRanger) LongestMatchAddr(addr netip.Addr) (RangerEntry, bool, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ranger.LongestMatchAddr(addr)
+}
+
+// ShortestMatch returns the least specific RangerEntry containing given ip.
+func (c *concurrentRanger) ShortestMatch(ip net.IP) (RangerEntry, bool, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ranger.ShortestMatch(ip)
+}
+
+// ShortestMatchAddr returns the least specific RangerEntry containing given
+// addr.
+func (c *concurrentRanger) ShortestMatchAddr(addr netip.Addr) (RangerEntry, bool, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ranger.ShortestMatchAddr(addr)
+}
+
+// CoveredNetworks returns the list of RangerEntry(s) the given ipnet covers.
+func (c *concurrentRanger) CoveredNetworks(network net.IPNet) ([]RangerEntry, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ranger.CoveredNetworks(network)
+}
+
+// CoveredPrefixes returns the list of RangerEntry(s) the given prefix covers.
+func (c *concurrentRanger) CoveredPrefixes(prefix netip.Prefix) ([]RangerEntry, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ranger.CoveredPrefixes(prefix)
+}
+
+// CoveringNetworks returns the list of RangerEntry(s) that cover the given
+// ipnet.
+func (c *concurrentRanger) CoveringNetworks(network net.IPNet) ([]RangerEntry, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ranger.CoveringNetworks(network)
+}
+
+// CoveringPrefixes returns the list of RangerEntry(s) that cover the given
+// prefix.
+func (c *concurrentRanger) CoveringPrefixes(prefix netip.Prefix) ([]RangerEntry, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ranger.CoveringPrefixes(prefix)
+}
+
+// OverlappingNetworks returns the list of RangerEntry(s) that either cover or
+// are covered by the given ipnet.
+func (c *concurrentRanger) OverlappingNetworks(network net.IPNet) ([]RangerEntry, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ranger.OverlappingNetworks(network)
+}
+
+// OverlappingPrefixes returns the list of RangerEntry(s) that either cover or
+// are covered by the given prefix.
+func (c *concurrentRanger) OverlappingPrefixes(prefix netip.Prefix) ([]RangerEntry, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ranger.OverlappingPrefixes(prefix)
+}
+
+// Walk calls fn for every RangerEntry in ranger, as of when Walk is called,
+// until fn returns false.
+func (c *concurrentRanger) Walk(fn func(RangerEntry) bool) {
+	for _, entry := range c.entries() {
+		if !fn(entry) {
+			return
+		}
+	}
+}
+
+// All returns an iterator over every RangerEntry in ranger, as of when
+// iteration starts.
+func (c *concurrentRanger) All() iter.Seq[RangerEntry] {
+	return c.Walk
+}
+
+// Backward returns an iterator over every RangerEntry in ranger in reverse
+// order, as of when iteration starts.
+func (c *concurrentRanger) Backward() iter.Seq[RangerEntry] {
+	return func(yield func(RangerEntry) bool) {
+		entries := c.entries()
+		for i := len(entries) - 1; i >= 0; i-- {
+			if !yield(entries[i]) {
+				return
+			}
+		}
+	}
+}
+
+// Next returns the RangerEntry following given network.
+func (c *concurrentRanger) Next(network net.IPNet) (RangerEntry, bool, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ranger.Next(network)
+}
+
+// Prev returns the RangerEntry preceding given network.
+func (c *concurrentRanger) Prev(network net.IPNet) (RangerEntry, bool, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ranger.Prev(network)
+}
+
+// EntriesBetween returns the RangerEntry(s) overlapping the address range from
+// start to end inclusive.
+func (c *concurrentRanger) EntriesBetween(start, end net.IP) ([]RangerEntry, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ranger.EntriesBetween(start, end)
+}
+
+// Len returns number of networks in ranger.
+func (c *concurrentRanger) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ranger.Len()
+}
+
+// entries returns a copy of all entries in the order of Walk, taken under the
+// read lock.
+func (c *concurrentRanger) entries() []RangerEntry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	entries := make([]RangerEntry, 0, c.ranger.Len())
+	c.ranger.Walk(func(entry RangerEntry) bool {
+		entries = append(entries, entry)
+		return true
+	})
+	return entries
+}
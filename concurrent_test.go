@@ -0,0 +1,63 @@
+package cidranger
+
+import (
+	"net"
+	"net/netip"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConcurrentRanger(t *testing.T) {
+	ranger := NewConcurrentRanger(NewPCTrieRanger())
+	configureRangerWithAWSRanges(t, ranger)
+	base := ranger.Len()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 256; j++ {
+				prefix := netip.PrefixFrom(netip.AddrFrom4([4]byte{10, byte(i), byte(j), 0}), 24)
+				assert.NoError(t, ranger.InsertPrefix(prefix))
+				if j%2 == 0 {
+					_, err := ranger.RemovePrefix(prefix)
+					assert.NoError(t, err)
+				}
+			}
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 256; j++ {
+				_, err := ranger.ContainingNetworks(net.IPv4(10, byte(i), byte(j), 1))
+				assert.NoError(t, err)
+				_, _, err = ranger.LongestMatchAddr(netip.AddrFrom4([4]byte{52, 95, 110, byte(j)}))
+				assert.NoError(t, err)
+				for range ranger.All() {
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, base+4*128, ranger.Len())
+}
+
+func TestConcurrentRangerMutateWhileWalking(t *testing.T) {
+	ranger := NewConcurrentRanger(NewPCTrieRanger())
+	for _, network := range []string{"10.0.0.0/8", "192.168.0.0/16", "8000::/16"} {
+		assert.NoError(t, ranger.InsertPrefix(netip.MustParsePrefix(network)))
+	}
+
+	// Removing every entry while iterating does not deadlock, and the
+	// iteration still sees the entries as of when it started.
+	var walked []RangerEntry
+	for entry := range ranger.All() {
+		walked = append(walked, entry)
+		_, err := ranger.Remove(entry.Network())
+		assert.NoError(t, err)
+	}
+	assert.Len(t, walked, 3)
+	assert.Equal(t, 0, ranger.Len())
+}
@@ -44,6 +44,19 @@ func newCustomRangerEntry(ipNet net.IPNet, asn string) cidranger.RangerEntry {
 	}
 }
 
+// codec that conforms to cidranger.Codec, storing the ASN of each entry
+type customRangerEntryCodec struct{}
+
+// encode the ASN of entry
+func (customRangerEntryCodec) MarshalValue(network net.IPNet, entry cidranger.RangerEntry) ([]byte, error) {
+	return []byte(entry.(*customRangerEntry).asn), nil
+}
+
+// decode the entry of network from its ASN
+func (customRangerEntryCodec) UnmarshalValue(network net.IPNet, data []byte) (cidranger.RangerEntry, error) {
+	return newCustomRangerEntry(network, string(data)), nil
+}
+
 // entry point
 func main() {
 
@@ -91,4 +104,17 @@ func main() {
 		// Display
 		fmt.Println("\t", n, a)
 	}
+
+	// Serialize ranger along with the ASN of each entry, and load it back
+	data, err := cidranger.MarshalRanger(ranger, customRangerEntryCodec{})
+	if err != nil {
+		fmt.Println("cidranger.MarshalRanger()", err.Error())
+		os.Exit(1)
+	}
+	loaded, err := cidranger.UnmarshalRanger(data, customRangerEntryCodec{})
+	if err != nil {
+		fmt.Println("cidranger.UnmarshalRanger()", err.Error())
+		os.Exit(1)
+	}
+	fmt.Printf("Loaded %d entries from %d bytes\n", loaded.Len(), len(data))
 }
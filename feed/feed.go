@@ -0,0 +1,167 @@
+/*
+Package feed loads plain text IP blocklists and allowlists into a
+cidranger.Ranger, such as Spamhaus DROP and EDROP, FireHOL netsets, Emerging
+Threats lists and hand maintained lists.
+
+Each line holds an IP, a CIDR or an inclusive range of IPs, optionally
+followed by a comment introduced by ';' or '#':
+
+	# Hand maintained list
+	192.0.2.1
+	198.51.100.0/24 ; SBL123456
+	203.0.113.10-203.0.113.20 # partner office
+	2001:db8::/32
+
+IPs get an Entry for their /32 or /128 network, ranges one for each network
+of the fewest networks covering them.  Blank lines and lines holding only a
+comment are skipped.
+*/
+package feed
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strings"
+
+	"github.com/yl2chen/cidranger"
+	rnet "github.com/yl2chen/cidranger/net"
+)
+
+// ErrInvalidLine is wrapped by the LineError of lines that are neither an
+// IP, a CIDR nor a range.
+var ErrInvalidLine = fmt.Errorf("Invalid feed line")
+
+// Options configures Load.
+type Options struct {
+	// Source names the feed on its entries, such as "spamhaus-drop".
+	Source string
+}
+
+// Entry is a cidranger.RangerEntry for a network listed by a feed.
+type Entry struct {
+	ipNet  net.IPNet
+	Source string
+	// Line is the number of the line listing the network, counting from 1.
+	Line int
+	// Comment is the trimmed comment of the line, empty when there is none.
+	Comment string
+}
+
+// Network returns the network of entry.
+func (e *Entry) Network() net.IPNet {
+	return e.ipNet
+}
+
+// LineError reports a malformed line of a feed.
+type LineError struct {
+	Line int
+	Text string
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d: %v: %q", e.Line, e.Err, e.Text)
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// Lookup returns the Entry of the most specific listed network containing
+// given ip, or nil if the feed does not list ip.
+func Lookup(ranger cidranger.Ranger, ip net.IP) (*Entry, error) {
+	return cidranger.LookupEntry[*Entry](ranger, ip)
+}
+
+// Load inserts the networks listed by the feed read from r into ranger.
+// Malformed lines do not stop the load, they are skipped and reported once
+// the other lines are inserted, as an error joining a *LineError for each.
+func Load(r io.Reader, ranger cidranger.Ranger, options Options) error {
+	batch := cidranger.NewBatch()
+	var malformed []error
+	scanner := bufio.NewScanner(r)
+	for number := 1; scanner.Scan(); number++ {
+		text := scanner.Text()
+		entries, err := parseLine(text)
+		if err != nil {
+			malformed = append(malformed, &LineError{Line: number, Text: text, Err: err})
+			continue
+		}
+		for _, entry := range entries {
+			entry.Source, entry.Line = options.Source, number
+			batch.Insert(entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if err := batch.Commit(ranger); err != nil {
+		return err
+	}
+	return errors.Join(malformed...)
+}
+
+// parseLine returns the entries of a line, none for blank and comment
+// lines.
+func parseLine(line string) ([]*Entry, error) {
+	var comment string
+	if i := strings.IndexAny(line, ";#"); i >= 0 {
+		line, comment = line[:i], strings.TrimSpace(line[i+1:])
+	}
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return nil, nil
+	}
+
+	var prefixes []netip.Prefix
+	if start, end, ok := strings.Cut(line, "-"); ok {
+		startAddr, err := parseAddr(strings.TrimSpace(start))
+		if err != nil {
+			return nil, err
+		}
+		endAddr, err := parseAddr(strings.TrimSpace(end))
+		if err != nil {
+			return nil, err
+		}
+		if prefixes = rnet.RangePrefixes(startAddr, endAddr); prefixes == nil {
+			return nil, ErrInvalidLine
+		}
+	} else if strings.Contains(line, "/") {
+		prefix, err := netip.ParsePrefix(line)
+		if err != nil {
+			return nil, ErrInvalidLine
+		}
+		if prefix.Addr().Is4In6() && prefix.Bits() >= 96 {
+			prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+		}
+		prefixes = []netip.Prefix{prefix.Masked()}
+	} else {
+		addr, err := parseAddr(line)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = []netip.Prefix{netip.PrefixFrom(addr, addr.BitLen())}
+	}
+
+	entries := make([]*Entry, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		entries = append(entries, &Entry{
+			ipNet:   rnet.NewNetworkFromPrefix(prefix).IPNet,
+			Comment: comment,
+		})
+	}
+	return entries, nil
+}
+
+// parseAddr parses an IP, unmapping IPv4-mapped IPv6 addresses.
+func parseAddr(s string) (netip.Addr, error) {
+	addr, err := netip.ParseAddr(s)
+	if err != nil || addr.Zone() != "" {
+		return netip.Addr{}, ErrInvalidLine
+	}
+	return addr.Unmap(), nil
+}
@@ -0,0 +1,139 @@
+package feed
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yl2chen/cidranger"
+)
+
+func TestLoadSpamhausDROP(t *testing.T) {
+	drop := `; Spamhaus DROP List 2023/11/15 - (c) 2023 The Spamhaus Project SLU
+; https://www.spamhaus.org/drop/drop.txt
+; Last-Modified: Wed, 15 Nov 2023 12:00:00 GMT
+1.10.16.0/20 ; SBL256894
+1.19.0.0/16 ; SBL434604
+`
+	ranger := cidranger.NewPCTrieRanger()
+	assert.NoError(t, Load(strings.NewReader(drop), ranger, Options{Source: "spamhaus-drop"}))
+	assert.Equal(t, 2, ranger.Len())
+
+	entry, err := Lookup(ranger, net.ParseIP("1.10.20.1"))
+	assert.NoError(t, err)
+	if assert.NotNil(t, entry) {
+		network := entry.Network()
+		assert.Equal(t, "1.10.16.0/20", network.String())
+		assert.Equal(t, "spamhaus-drop", entry.Source)
+		assert.Equal(t, 4, entry.Line)
+		assert.Equal(t, "SBL256894", entry.Comment)
+	}
+}
+
+func TestLoadNetset(t *testing.T) {
+	netset := `#
+# firehol_level1
+#
+# Maintainer      : FireHOL
+#
+
+0.0.0.0/8
+5.134.128.0/19
+192.0.2.1
+2001:db8::/32
+2001:db8:ffff::1
+`
+	ranger := cidranger.NewPCTrieRanger()
+	assert.NoError(t, Load(strings.NewReader(netset), ranger, Options{}))
+	var networks []string
+	for entry := range ranger.All() {
+		network := entry.Network()
+		networks = append(networks, network.String())
+	}
+	assert.Equal(t, []string{
+		"0.0.0.0/8", "5.134.128.0/19", "192.0.2.1/32", "2001:db8::/32", "2001:db8:ffff::1/128",
+	}, networks)
+}
+
+func TestLoadMixed(t *testing.T) {
+	list := `# Hand maintained list
+192.0.2.1 ; scanner
+198.51.100.7/24
+203.0.113.10-203.0.113.20 # partner office
+203.0.114.0 - 203.0.114.255
+::ffff:192.0.2.200
+2001:db8::1-2001:db8::3;lab
+	10.0.0.0/8	#  internal  
+`
+	ranger := cidranger.NewPCTrieRanger()
+	assert.NoError(t, Load(strings.NewReader(list), ranger, Options{Source: "hand"}))
+
+	cases := []struct {
+		ip      string
+		network string
+		line    int
+		comment string
+	}{
+		{"192.0.2.1", "192.0.2.1/32", 2, "scanner"},
+		{"198.51.100.1", "198.51.100.0/24", 3, ""},
+		{"203.0.113.10", "203.0.113.10/31", 4, "partner office"},
+		{"203.0.113.13", "203.0.113.12/30", 4, "partner office"},
+		{"203.0.113.20", "203.0.113.20/32", 4, "partner office"},
+		{"203.0.114.9", "203.0.114.0/24", 5, ""},
+		{"192.0.2.200", "192.0.2.200/32", 6, ""},
+		{"2001:db8::1", "2001:db8::1/128", 7, "lab"},
+		{"2001:db8::3", "2001:db8::2/127", 7, "lab"},
+		{"10.1.2.3", "10.0.0.0/8", 8, "internal"},
+	}
+	for _, tc := range cases {
+		entry, err := Lookup(ranger, net.ParseIP(tc.ip))
+		assert.NoError(t, err)
+		if assert.NotNil(t, entry, tc.ip) {
+			network := entry.Network()
+			assert.Equal(t, tc.network, network.String(), tc.ip)
+			assert.Equal(t, tc.line, entry.Line, tc.ip)
+			assert.Equal(t, tc.comment, entry.Comment, tc.ip)
+			assert.Equal(t, "hand", entry.Source)
+		}
+	}
+	entry, err := Lookup(ranger, net.ParseIP("203.0.113.21"))
+	assert.NoError(t, err)
+	assert.Nil(t, entry)
+}
+
+func TestLoadMalformed(t *testing.T) {
+	list := `192.0.2.1
+192.0.2.256
+198.51.100.0/33 ; too long
+203.0.113.20-203.0.113.10
+203.0.113.1-2001:db8::1
+fe80::1%eth0
+192.0.2.2 192.0.2.3
+2001:db8::/32
+`
+	ranger := cidranger.NewPCTrieRanger()
+	err := Load(strings.NewReader(list), ranger, Options{})
+	assert.True(t, errors.Is(err, ErrInvalidLine))
+	// Valid lines are loaded regardless.
+	assert.Equal(t, 2, ranger.Len())
+
+	var lines []int
+	for _, err := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var lineError *LineError
+		if assert.True(t, errors.As(err, &lineError)) {
+			lines = append(lines, lineError.Line)
+		}
+	}
+	assert.Equal(t, []int{2, 3, 4, 5, 6, 7}, lines)
+	assert.Contains(t, err.Error(), `line 3: Invalid feed line: "198.51.100.0/33 ; too long"`)
+}
+
+func TestLoadReadOnly(t *testing.T) {
+	ranger := cidranger.NewSnapshotPCTrieRanger()
+	ranger.InsertPrefix(netip.MustParsePrefix("10.0.0.0/8"))
+	err := Load(strings.NewReader("192.0.2.1\n"), ranger.Snapshot(), Options{})
+	assert.Equal(t, cidranger.ErrReadOnlyRanger, err)
+}
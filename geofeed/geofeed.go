@@ -0,0 +1,238 @@
+/*
+Package geofeed reads and writes RFC 8805 self-published IP geolocation
+feeds, CSV files whose lines map a prefix to a location:
+
+	# prefix,country,region,city,postal code
+	192.0.2.0/24,US,US-CA,San Francisco,
+	2001:db8::/32,FR,FR-75,Paris,
+
+Load validates lines against the rules of the RFC: prefixes must be
+canonical, without host bits set, countries ISO 3166-1 alpha-2 codes and
+regions ISO 3166-2 codes of the country, and overlapping prefixes must not
+locate the same addresses differently.
+*/
+package geofeed
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strings"
+
+	"github.com/yl2chen/cidranger"
+	rnet "github.com/yl2chen/cidranger/net"
+)
+
+// Errors wrapped by the LineError of invalid lines.
+var (
+	ErrInvalidPrefix   = fmt.Errorf("Invalid geofeed prefix")
+	ErrInvalidCountry  = fmt.Errorf("Invalid geofeed country code")
+	ErrInvalidRegion   = fmt.Errorf("Invalid geofeed region code")
+	ErrConflictingData = fmt.Errorf("Geofeed prefix overlaps a prefix with different location")
+)
+
+// Location is the location of a prefix, its fields empty when not given.
+type Location struct {
+	// Country is an upper case ISO 3166-1 alpha-2 code.
+	Country string
+	// Region is an upper case ISO 3166-2 code, such as US-CA.
+	Region string
+	City   string
+	// PostalCode is deprecated by RFC 8805, but still read and written.
+	PostalCode string
+}
+
+// Entry is a cidranger.RangerEntry for a prefix of a geofeed.
+type Entry struct {
+	ipNet net.IPNet
+	Location
+}
+
+// NewEntry returns an Entry locating prefix at location.
+func NewEntry(prefix netip.Prefix, location Location) *Entry {
+	return &Entry{
+		ipNet:    rnet.NewNetworkFromPrefix(prefix).IPNet,
+		Location: location,
+	}
+}
+
+// Network returns the network of entry.
+func (e *Entry) Network() net.IPNet {
+	return e.ipNet
+}
+
+// LineError reports an invalid line of a geofeed.
+type LineError struct {
+	Line int
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// Lookup returns the Entry locating given ip, that of the most specific
+// prefix containing it, or nil if the feed does not locate ip.
+func Lookup(ranger cidranger.Ranger, ip net.IP) (*Entry, error) {
+	return cidranger.LookupEntry[*Entry](ranger, ip)
+}
+
+// Load inserts the valid prefixes of the geofeed read from r into ranger.
+// Invalid lines are skipped, and reported once the valid ones are inserted as
+// an error joining a *LineError for each.  Fields past the postal code are
+// ignored.
+func Load(r io.Reader, ranger cidranger.Ranger) error {
+	batch := cidranger.NewBatch()
+	// loaded holds the valid prefixes, to find those overlapping others.
+	loaded := cidranger.NewPCTrieRanger()
+	var invalid []error
+	scanner := bufio.NewScanner(r)
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		record, err := splitLine(line)
+		var entry *Entry
+		if err == nil {
+			entry, err = parseRecord(record)
+		}
+		if err == nil {
+			err = checkOverlaps(loaded, entry)
+		}
+		if err != nil {
+			invalid = append(invalid, &LineError{Line: number, Err: err})
+			continue
+		}
+		loaded.Insert(entry)
+		batch.Insert(entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if err := batch.Commit(ranger); err != nil {
+		return err
+	}
+	return errors.Join(invalid...)
+}
+
+// splitLine returns the fields of a line.  Feeds are not meant to be quoted,
+// quotes are only honoured within a line so that a stray one does not
+// swallow the lines following it.
+func splitLine(line string) ([]string, error) {
+	reader := csv.NewReader(strings.NewReader(line))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	return reader.Read()
+}
+
+func parseRecord(record []string) (*Entry, error) {
+	for len(record) < 5 {
+		record = append(record, "")
+	}
+	for i := range record {
+		record[i] = strings.TrimSpace(record[i])
+	}
+	prefix, err := netip.ParsePrefix(record[0])
+	if err != nil {
+		addr, err := netip.ParseAddr(record[0])
+		if err != nil || addr.Zone() != "" {
+			return nil, ErrInvalidPrefix
+		}
+		prefix = netip.PrefixFrom(addr, addr.BitLen())
+	}
+	if prefix != prefix.Masked() {
+		return nil, ErrInvalidPrefix
+	}
+	if prefix.Addr().Is4In6() && prefix.Bits() >= 96 {
+		prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+	}
+
+	location := Location{
+		Country:    strings.ToUpper(record[1]),
+		Region:     strings.ToUpper(record[2]),
+		City:       record[3],
+		PostalCode: record[4],
+	}
+	if location.Country != "" && !isCountryCode(location.Country) {
+		return nil, ErrInvalidCountry
+	}
+	if location.Region != "" {
+		country, subdivision, ok := strings.Cut(location.Region, "-")
+		if !ok || !isCountryCode(country) || location.Country != "" && country != location.Country ||
+			len(subdivision) < 1 || len(subdivision) > 3 || !isAlphanumeric(subdivision) {
+			return nil, ErrInvalidRegion
+		}
+	}
+	return NewEntry(prefix, location), nil
+}
+
+// checkOverlaps returns ErrConflictingData if entry overlaps a loaded entry
+// of a different location.
+func checkOverlaps(loaded cidranger.Ranger, entry *Entry) error {
+	overlapping, err := loaded.OverlappingNetworks(entry.ipNet)
+	if err != nil {
+		return err
+	}
+	for _, other := range overlapping {
+		if other.(*Entry).Location != entry.Location {
+			return ErrConflictingData
+		}
+	}
+	return nil
+}
+
+func isCountryCode(code string) bool {
+	if len(code) != 2 {
+		return false
+	}
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+func isAlphanumeric(s string) bool {
+	for _, c := range s {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// Write writes the Entry(s) of ranger to w as a geofeed, in address order.
+// Other entries are left out.
+func Write(w io.Writer, ranger cidranger.Ranger) error {
+	writer := csv.NewWriter(w)
+	var err error
+	ranger.Walk(func(entry cidranger.RangerEntry) bool {
+		located, ok := entry.(*Entry)
+		if !ok {
+			return true
+		}
+		err = writer.Write([]string{
+			rnet.Network{IPNet: located.ipNet}.Prefix().String(),
+			located.Country,
+			located.Region,
+			located.City,
+			located.PostalCode,
+		})
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+	writer.Flush()
+	return writer.Error()
+}
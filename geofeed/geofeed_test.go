@@ -0,0 +1,128 @@
+package geofeed
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yl2chen/cidranger"
+)
+
+const geofeed = `# prefix,country,region,city,postal code
+192.0.2.0/24,US,US-CA,San Francisco,94107
+192.0.2.128/25,us,us-ca,San Francisco,94107
+198.51.100.0/24,DE,,,
+203.0.113.7,FR,FR-75,Paris
+2001:db8::/32,GB,GB-ENG,"London, City of",,ignored
+2001:db9::/48,,,,
+`
+
+func TestLoad(t *testing.T) {
+	ranger := cidranger.NewPCTrieRanger()
+	assert.NoError(t, Load(strings.NewReader(geofeed), ranger))
+	assert.Equal(t, 6, ranger.Len())
+
+	cases := []struct {
+		ip       string
+		network  string
+		location Location
+	}{
+		{"192.0.2.1", "192.0.2.0/24", Location{"US", "US-CA", "San Francisco", "94107"}},
+		{"192.0.2.200", "192.0.2.128/25", Location{"US", "US-CA", "San Francisco", "94107"}},
+		{"198.51.100.1", "198.51.100.0/24", Location{Country: "DE"}},
+		{"203.0.113.7", "203.0.113.7/32", Location{"FR", "FR-75", "Paris", ""}},
+		{"2001:db8::1", "2001:db8::/32", Location{"GB", "GB-ENG", "London, City of", ""}},
+		{"2001:db9::1", "2001:db9::/48", Location{}},
+	}
+	for _, tc := range cases {
+		entry, err := Lookup(ranger, net.ParseIP(tc.ip))
+		assert.NoError(t, err)
+		if assert.NotNil(t, entry, tc.ip) {
+			network := entry.Network()
+			assert.Equal(t, tc.network, network.String())
+			assert.Equal(t, tc.location, entry.Location)
+		}
+	}
+	entry, err := Lookup(ranger, net.ParseIP("203.0.113.8"))
+	assert.NoError(t, err)
+	assert.Nil(t, entry)
+}
+
+func TestLoadInvalid(t *testing.T) {
+	feed := `192.0.2.0/24,US,US-CA,San Francisco,
+192.0.2.1/24,US,,,
+192.0.2.0/33,US,,,
+not a prefix,US,,,
+198.51.100.0/24,USA,,,
+198.51.100.0/24,U1,,,
+198.51.100.0/24,US,CA,,
+198.51.100.0/24,US,FR-75,,
+198.51.100.0/24,US,US-CALI,,
+192.0.2.128/25,US,US-NY,New York,
+192.0.0.0/16,CA,,,
+"bad,
+10.0.0.0/8,USA,,,
+203.0.113.0/24,"FR,,,
+`
+	ranger := cidranger.NewPCTrieRanger()
+	err := Load(strings.NewReader(feed), ranger)
+	assert.Equal(t, 1, ranger.Len())
+
+	expected := []struct {
+		line int
+		err  error
+	}{
+		{2, ErrInvalidPrefix},
+		{3, ErrInvalidPrefix},
+		{4, ErrInvalidPrefix},
+		{5, ErrInvalidCountry},
+		{6, ErrInvalidCountry},
+		{7, ErrInvalidRegion},
+		{8, ErrInvalidRegion},
+		{9, ErrInvalidRegion},
+		{10, ErrConflictingData},
+		{11, ErrConflictingData},
+		// Quotes do not carry fields over the following lines.
+		{12, ErrInvalidPrefix},
+		{13, ErrInvalidCountry},
+		{14, ErrInvalidCountry},
+	}
+	errs := err.(interface{ Unwrap() []error }).Unwrap()
+	if assert.Len(t, errs, len(expected)) {
+		for i, tc := range expected {
+			var lineError *LineError
+			if assert.True(t, errors.As(errs[i], &lineError)) {
+				assert.Equal(t, tc.line, lineError.Line)
+				assert.Equal(t, tc.err, lineError.Err)
+			}
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	ranger := cidranger.NewPCTrieRanger()
+	ranger.Insert(NewEntry(netip.MustParsePrefix("2001:db8::/32"), Location{"GB", "GB-ENG", "London, City of", ""}))
+	ranger.Insert(NewEntry(netip.MustParsePrefix("198.51.100.0/24"), Location{Country: "DE"}))
+	ranger.Insert(NewEntry(netip.MustParsePrefix("192.0.2.0/24"), Location{"US", "US-CA", "San Francisco", "94107"}))
+	ranger.InsertPrefix(netip.MustParsePrefix("10.0.0.0/8"))
+
+	var buf bytes.Buffer
+	assert.NoError(t, Write(&buf, ranger))
+	assert.Equal(t, `192.0.2.0/24,US,US-CA,San Francisco,94107
+198.51.100.0/24,DE,,,
+2001:db8::/32,GB,GB-ENG,"London, City of",
+`, buf.String())
+
+	loaded := cidranger.NewPCTrieRanger()
+	assert.NoError(t, Load(&buf, loaded))
+	assert.Equal(t, 3, loaded.Len())
+	entry, err := Lookup(loaded, net.ParseIP("2001:db8::1"))
+	assert.NoError(t, err)
+	if assert.NotNil(t, entry) {
+		assert.Equal(t, "London, City of", entry.City)
+	}
+}
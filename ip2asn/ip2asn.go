@@ -0,0 +1,207 @@
+/*
+Package ip2asn loads the IP to ASN datasets of iptoasn.com, such as
+ip2asn-combined.tsv, into a cidranger.Ranger, to find out which autonomous
+system announces an address.
+
+Files are made of tab separated records, giving an inclusive range of
+addresses, the number of the autonomous system announcing it, the ISO 3166
+code of its country and its description:
+
+	1.0.0.0	1.0.0.255	13335	US	CLOUDFLARENET
+	2001:db8::	2001:db8:ffff:ffff:ffff:ffff:ffff:ffff	64496	ZZ	EXAMPLE
+
+Ranges need not be aligned, and get an ASNEntry for each network of the
+fewest networks covering them.  Ranges of AS 0 are not routed and are
+skipped.  The addresses of ip2asn-v4-u32.tsv, written as integers, are
+supported as well.
+
+ASNDB wraps a ranger with an index of the networks of each autonomous
+system:
+
+	db, err := ip2asn.LoadASNDB(file)
+	asn, ok := db.Lookup(net.ParseIP("1.0.0.1"))
+	prefixes := db.PrefixesForASN(asn)
+*/
+package ip2asn
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/yl2chen/cidranger"
+	rnet "github.com/yl2chen/cidranger/net"
+)
+
+// ErrInvalidRecord is returned when a record of an ip2asn file is malformed.
+var ErrInvalidRecord = fmt.Errorf("Invalid ip2asn record")
+
+// ASN is an autonomous system number.
+type ASN uint32
+
+// String returns asn in the AS64496 notation.
+func (asn ASN) String() string {
+	return "AS" + strconv.FormatUint(uint64(asn), 10)
+}
+
+// ASNEntry is a cidranger.RangerEntry for a network announced by an
+// autonomous system.
+type ASNEntry struct {
+	ipNet net.IPNet
+	ASN   ASN
+	// Country is the ISO 3166 code of the country of the autonomous system,
+	// empty when the dataset does not tell.
+	Country     string
+	Description string
+}
+
+// Network returns the network of entry.
+func (e *ASNEntry) Network() net.IPNet {
+	return e.ipNet
+}
+
+// Lookup returns the ASNEntry of the routed range containing given ip, or nil
+// if ip is not routed.
+func Lookup(ranger cidranger.Ranger, ip net.IP) (*ASNEntry, error) {
+	return cidranger.LookupEntry[*ASNEntry](ranger, ip)
+}
+
+// Load inserts the routed ranges of an ip2asn file into ranger, nothing being
+// inserted if a record is malformed.  Errors name the line of the record.
+func Load(r io.Reader, ranger cidranger.Ranger) error {
+	batch := cidranger.NewBatch()
+	scanner := bufio.NewScanner(r)
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		entries, err := parseRecord(strings.Split(line, "\t"))
+		if err != nil {
+			return fmt.Errorf("line %d: %w", number, err)
+		}
+		for _, entry := range entries {
+			batch.Insert(entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return batch.Commit(ranger)
+}
+
+// parseRecord returns the entries of a record, none for ranges not routed.
+func parseRecord(fields []string) ([]*ASNEntry, error) {
+	if len(fields) < 5 {
+		return nil, ErrInvalidRecord
+	}
+	start, err := parseAddr(fields[0])
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseAddr(fields[1])
+	if err != nil {
+		return nil, err
+	}
+	asn, err := strconv.ParseUint(strings.TrimPrefix(fields[2], "AS"), 10, 32)
+	if err != nil {
+		return nil, ErrInvalidRecord
+	}
+	prefixes := rnet.RangePrefixes(start, end)
+	if prefixes == nil {
+		return nil, ErrInvalidRecord
+	}
+	if asn == 0 {
+		return nil, nil
+	}
+
+	entry := ASNEntry{
+		ASN:     ASN(asn),
+		Country: fields[3],
+		// Descriptions are the last field, tabs they hold included.
+		Description: strings.Join(fields[4:], "\t"),
+	}
+	if entry.Country == "None" || entry.Country == "Unknown" {
+		entry.Country = ""
+	}
+	entries := make([]*ASNEntry, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		announced := entry
+		announced.ipNet = rnet.NewNetworkFromPrefix(prefix).IPNet
+		entries = append(entries, &announced)
+	}
+	return entries, nil
+}
+
+// parseAddr parses an IP, or an IPv4 address written as an integer,
+// unmapping IPv4-mapped IPv6 addresses.
+func parseAddr(s string) (netip.Addr, error) {
+	if n, err := strconv.ParseUint(s, 10, 32); err == nil {
+		return netip.AddrFrom4([4]byte(binary.BigEndian.AppendUint32(nil, uint32(n)))), nil
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil || addr.Zone() != "" {
+		return netip.Addr{}, ErrInvalidRecord
+	}
+	return addr.Unmap(), nil
+}
+
+// ASNDB looks up the autonomous system announcing an address, and the
+// networks an autonomous system announces.  It is safe for concurrent use
+// as long as its ranger is not modified.
+type ASNDB struct {
+	ranger   cidranger.Ranger
+	prefixes map[ASN][]netip.Prefix
+}
+
+// NewASNDB returns an ASNDB over the ASNEntry values ranger holds, indexing
+// them by autonomous system.  Entries inserted into ranger later on are
+// found by Lookup but not by PrefixesForASN.
+func NewASNDB(ranger cidranger.Ranger) *ASNDB {
+	db := &ASNDB{ranger: ranger, prefixes: make(map[ASN][]netip.Prefix)}
+	ranger.Walk(func(entry cidranger.RangerEntry) bool {
+		if entry, ok := entry.(*ASNEntry); ok {
+			db.prefixes[entry.ASN] = append(db.prefixes[entry.ASN], rnet.Network{IPNet: entry.ipNet}.Prefix())
+		}
+		return true
+	})
+	return db
+}
+
+// LoadASNDB loads the ip2asn file read from r into a new ranger and returns
+// an ASNDB over it.
+func LoadASNDB(r io.Reader) (*ASNDB, error) {
+	ranger := cidranger.NewPCTrieRanger()
+	if err := Load(r, ranger); err != nil {
+		return nil, err
+	}
+	return NewASNDB(ranger), nil
+}
+
+// Ranger returns the ranger of db, for Lookup to get the country and
+// description of the autonomous system announcing an address.
+func (db *ASNDB) Ranger() cidranger.Ranger {
+	return db.ranger
+}
+
+// Lookup returns the autonomous system announcing given ip, and whether
+// there is one.
+func (db *ASNDB) Lookup(ip net.IP) (ASN, bool) {
+	entry, err := Lookup(db.ranger, ip)
+	if err != nil || entry == nil {
+		return 0, false
+	}
+	return entry.ASN, true
+}
+
+// PrefixesForASN returns the networks announced by given autonomous system,
+// in the order the ranger walks them, or nil if it announces none.
+func (db *ASNDB) PrefixesForASN(asn ASN) []netip.Prefix {
+	return slices.Clone(db.prefixes[asn])
+}
@@ -0,0 +1,142 @@
+package ip2asn
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yl2chen/cidranger"
+)
+
+const dataset = "0.0.0.0\t0.255.255.255\t0\tNone\tNot routed\n" +
+	"1.0.0.0\t1.0.0.255\t13335\tUS\tCLOUDFLARENET\n" +
+	"1.0.1.0\t1.0.3.255\t0\tNone\tNot routed\n" +
+	"1.0.4.0\t1.0.7.255\t38803\tAU\tGTELECOM-AUSTRALIA Gtelecom Pty Ltd\n" +
+	"1.1.1.0\t1.1.1.255\t13335\tUS\tCLOUDFLARENET\n" +
+	"203.0.113.10\t203.0.113.20\t64496\tUnknown\tEXAMPLE\n" +
+	"\n" +
+	"2001:db8::\t2001:db8:ffff:ffff:ffff:ffff:ffff:ffff\t64497\tZZ\tEXAMPLE-V6\n" +
+	"2606:4700::\t2606:4700:ffff:ffff:ffff:ffff:ffff:ffff\t13335\tUS\tCLOUDFLARENET\n"
+
+func TestLoad(t *testing.T) {
+	ranger := cidranger.NewPCTrieRanger()
+	assert.NoError(t, Load(strings.NewReader(dataset), ranger))
+	// 203.0.113.10-203.0.113.20 is split into 10/31, 12/30, 16/30 and 20/32.
+	assert.Equal(t, 9, ranger.Len())
+
+	entry, err := Lookup(ranger, net.ParseIP("1.0.5.1"))
+	assert.NoError(t, err)
+	if assert.NotNil(t, entry) {
+		assert.Equal(t, ASN(38803), entry.ASN)
+		assert.Equal(t, "AU", entry.Country)
+		assert.Equal(t, "GTELECOM-AUSTRALIA Gtelecom Pty Ltd", entry.Description)
+		network := entry.Network()
+		assert.Equal(t, "1.0.4.0/22", network.String())
+	}
+
+	entry, err = Lookup(ranger, net.ParseIP("203.0.113.13"))
+	assert.NoError(t, err)
+	if assert.NotNil(t, entry) {
+		assert.Equal(t, "AS64496", entry.ASN.String())
+		assert.Equal(t, "", entry.Country)
+		network := entry.Network()
+		assert.Equal(t, "203.0.113.12/30", network.String())
+	}
+
+	entry, err = Lookup(ranger, net.ParseIP("2001:db8::1"))
+	assert.NoError(t, err)
+	if assert.NotNil(t, entry) {
+		assert.Equal(t, ASN(64497), entry.ASN)
+		network := entry.Network()
+		assert.Equal(t, "2001:db8::/32", network.String())
+	}
+
+	for _, ip := range []string{"0.1.2.3", "1.0.2.1", "203.0.113.21"} {
+		entry, err = Lookup(ranger, net.ParseIP(ip))
+		assert.NoError(t, err)
+		assert.Nil(t, entry, ip)
+	}
+}
+
+func TestLoadIntegerAddresses(t *testing.T) {
+	ranger := cidranger.NewPCTrieRanger()
+	assert.NoError(t, Load(strings.NewReader("16777216\t16777471\t13335\tUS\tCLOUDFLARENET\n"), ranger))
+	entry, err := Lookup(ranger, net.ParseIP("1.0.0.1"))
+	assert.NoError(t, err)
+	if assert.NotNil(t, entry) {
+		network := entry.Network()
+		assert.Equal(t, "1.0.0.0/24", network.String())
+	}
+}
+
+func TestLoadInvalidRecord(t *testing.T) {
+	records := []string{
+		"1.0.0.0\t1.0.0.255\t13335\tUS",
+		"1.0.0.0\t1.0.0.256\t13335\tUS\tCLOUDFLARENET",
+		"1.0.0.255\t1.0.0.0\t13335\tUS\tCLOUDFLARENET",
+		"1.0.0.0\t2001:db8::\t13335\tUS\tCLOUDFLARENET",
+		"1.0.0.0\t1.0.0.255\tASX\tUS\tCLOUDFLARENET",
+		"1.0.0.0\t1.0.0.255\t4294967296\tUS\tCLOUDFLARENET",
+	}
+	for _, record := range records {
+		ranger := cidranger.NewPCTrieRanger()
+		err := Load(strings.NewReader("1.1.1.0\t1.1.1.255\t13335\tUS\tCLOUDFLARENET\n"+record+"\n"), ranger)
+		assert.True(t, errors.Is(err, ErrInvalidRecord), record)
+		assert.Contains(t, err.Error(), "line 2", record)
+		assert.Equal(t, 0, ranger.Len())
+	}
+}
+
+func TestASNDB(t *testing.T) {
+	db, err := LoadASNDB(strings.NewReader(dataset))
+	assert.NoError(t, err)
+
+	asn, ok := db.Lookup(net.ParseIP("1.1.1.1"))
+	assert.True(t, ok)
+	assert.Equal(t, ASN(13335), asn)
+	asn, ok = db.Lookup(net.ParseIP("2606:4700::1111"))
+	assert.True(t, ok)
+	assert.Equal(t, ASN(13335), asn)
+	_, ok = db.Lookup(net.ParseIP("1.0.2.1"))
+	assert.False(t, ok)
+	_, ok = db.Lookup(nil)
+	assert.False(t, ok)
+
+	assert.Equal(t, []netip.Prefix{
+		netip.MustParsePrefix("1.0.0.0/24"),
+		netip.MustParsePrefix("1.1.1.0/24"),
+		netip.MustParsePrefix("2606:4700::/32"),
+	}, db.PrefixesForASN(13335))
+	assert.Len(t, db.PrefixesForASN(64496), 4)
+	assert.Nil(t, db.PrefixesForASN(0))
+	assert.Nil(t, db.PrefixesForASN(64511))
+
+	// Returned prefixes are copies.
+	db.PrefixesForASN(13335)[0] = netip.Prefix{}
+	assert.Equal(t, netip.MustParsePrefix("1.0.0.0/24"), db.PrefixesForASN(13335)[0])
+
+	entry, err := Lookup(db.Ranger(), net.ParseIP("1.1.1.1"))
+	assert.NoError(t, err)
+	if assert.NotNil(t, entry) {
+		assert.Equal(t, "CLOUDFLARENET", entry.Description)
+	}
+
+	_, err = LoadASNDB(strings.NewReader("1.0.0.0\t1.0.0.255\n"))
+	assert.Error(t, err)
+}
+
+func TestNewASNDBSkipsOtherEntries(t *testing.T) {
+	ranger := cidranger.NewPCTrieRanger()
+	assert.NoError(t, Load(strings.NewReader(dataset), ranger))
+	_, network, _ := net.ParseCIDR("1.1.1.0/25")
+	assert.NoError(t, ranger.Insert(cidranger.NewBasicRangerEntry(*network)))
+
+	db := NewASNDB(ranger)
+	asn, ok := db.Lookup(net.ParseIP("1.1.1.1"))
+	assert.True(t, ok)
+	assert.Equal(t, ASN(13335), asn)
+	assert.Len(t, db.PrefixesForASN(13335), 3)
+}
@@ -0,0 +1,922 @@
+package cidranger
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"iter"
+	"math"
+	"net"
+	"net/netip"
+
+	rnet "github.com/yl2chen/cidranger/net"
+)
+
+// The mapped format lays the path compressed tries out flat, so that a file
+// can be memory mapped and queried in place, see NewMappedRanger.  Every
+// integer is a little endian uint32:
+//
+//	header  magic "CIDM", version, node count, slot count, value bytes,
+//	        IPv4 root node and entry count, IPv6 root node and entry count,
+//	        reserved
+//	nodes   mappedNodeSize bytes each, in pre-order
+//	slots   index of each child node or noMappedChild, 1<<numBitsHandled
+//	        slots per node with children
+//	values  codec encoded values
+//
+// with every node record holding:
+//
+//	address         16 bytes, IPv4 addresses in the first 4
+//	numBitsSkipped  byte
+//	numBitsHandled  byte
+//	flags           byte, flagHasValue and flagHasChildren
+//	reserved        byte
+//	first slot      uint32
+//	value offset    uint32
+//	value length    uint32
+const (
+	mappedMagic      = "CIDM"
+	mappedVersion    = 1
+	mappedHeaderSize = 40
+	mappedNodeSize   = 32
+
+	flagHasChildren = 1 << 1
+
+	noMappedChild = math.MaxUint32
+)
+
+// MappedFile is a read-only memory mapping of a file, see MapFile.
+type MappedFile struct {
+	data []byte
+}
+
+// MapFile maps the file at given path read-only into memory, so that it can
+// be queried in place by NewMappedRanger.  Processes mapping the same file
+// share its pages through the page cache.  Platforms without mmap support read
+// the file into memory instead.
+func MapFile(path string) (*MappedFile, error) {
+	data, err := mapFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &MappedFile{data: data}, nil
+}
+
+// Bytes returns the mapped content, valid until Close is called.
+func (f *MappedFile) Bytes() []byte {
+	return f.data
+}
+
+// Close unmaps the file, rangers querying it must no longer be used.
+func (f *MappedFile) Close() error {
+	data := f.data
+	f.data = nil
+	return unmapFile(data)
+}
+
+// ErrRangerTooLarge is returned upon writing a ranger too large for the
+// mapped format.
+var ErrRangerTooLarge = fmt.Errorf("Ranger too large for mapped format")
+
+// WriteMappedRanger writes the entries of ranger to w in the flat format read
+// by NewMappedRanger, using codec to encode the entries.
+func WriteMappedRanger(w io.Writer, ranger Ranger, codec Codec[RangerEntry]) error {
+	typed, err := typedRangerOf(ranger)
+	if err != nil {
+		return err
+	}
+	return WriteTypedMappedRanger(w, typed, codec)
+}
+
+// WriteTypedMappedRanger writes the entries of ranger to w in the flat format
+// read by NewTypedMappedRanger, using codec to encode the values.
+func WriteTypedMappedRanger[V any](w io.Writer, ranger TypedRanger[V], codec Codec[V]) error {
+	v, err := prefixTriesOf(ranger)
+	if err != nil {
+		return err
+	}
+	e := &mappedEncoder[V]{codec: codec}
+	header := make([]byte, mappedHeaderSize)
+	copy(header, mappedMagic)
+	binary.LittleEndian.PutUint32(header[4:], mappedVersion)
+	for i, ranger := range []TypedRanger[V]{v.ipV4Ranger, v.ipV6Ranger} {
+		trie := ranger.(*prefixTrie[V])
+		root, err := e.encode(trie)
+		if err != nil {
+			return err
+		}
+		binary.LittleEndian.PutUint32(header[20+8*i:], root)
+		binary.LittleEndian.PutUint32(header[24+8*i:], uint32(trie.size))
+	}
+	binary.LittleEndian.PutUint32(header[8:], uint32(len(e.nodes)/mappedNodeSize))
+	binary.LittleEndian.PutUint32(header[12:], uint32(len(e.slots)/4))
+	binary.LittleEndian.PutUint32(header[16:], uint32(len(e.values)))
+	for _, section := range [][]byte{header, e.nodes, e.slots, e.values} {
+		if _, err := w.Write(section); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mappedEncoder accumulates the sections of the mapped format.
+type mappedEncoder[V any] struct {
+	codec  Codec[V]
+	nodes  []byte
+	slots  []byte
+	values []byte
+}
+
+// encode appends the records of p and its subtree in pre-order, returning the
+// index of the record of p.
+func (e *mappedEncoder[V]) encode(p *prefixTrie[V]) (uint32, error) {
+	index := len(e.nodes) / mappedNodeSize
+	if index >= noMappedChild {
+		return 0, ErrRangerTooLarge
+	}
+	offset := len(e.nodes)
+	e.nodes = append(e.nodes, make([]byte, mappedNodeSize)...)
+
+	var flags byte
+	var firstSlot uint32
+	for i, child := range p.children {
+		if child == nil {
+			continue
+		}
+		if flags&flagHasChildren == 0 {
+			flags |= flagHasChildren
+			firstSlot = uint32(len(e.slots) / 4)
+			for range p.children {
+				e.slots = binary.LittleEndian.AppendUint32(e.slots, noMappedChild)
+			}
+		}
+		childIndex, err := e.encode(child)
+		if err != nil {
+			return 0, err
+		}
+		binary.LittleEndian.PutUint32(e.slots[4*(int(firstSlot)+i):], childIndex)
+	}
+	var valueOffset, valueLength int
+	if p.hasEntry() {
+		value, err := e.codec.MarshalValue(p.network.IPNet, p.value)
+		if err != nil {
+			return 0, err
+		}
+		flags |= flagHasValue
+		valueOffset, valueLength = len(e.values), len(value)
+		e.values = append(e.values, value...)
+	}
+	if len(e.slots)/4 > math.MaxUint32 || len(e.values) > math.MaxUint32 {
+		return 0, ErrRangerTooLarge
+	}
+
+	record := e.nodes[offset : offset+mappedNodeSize]
+	if p.totalNumberOfBits() == 8*net.IPv4len {
+		copy(record, p.network.IP.To4())
+	} else {
+		copy(record, p.network.IP.To16())
+	}
+	record[16] = byte(p.numBitsSkipped)
+	record[17] = byte(p.numBitsHandled)
+	record[18] = flags
+	binary.LittleEndian.PutUint32(record[20:], firstSlot)
+	binary.LittleEndian.PutUint32(record[24:], uint32(valueOffset))
+	binary.LittleEndian.PutUint32(record[28:], uint32(valueLength))
+	return uint32(index), nil
+}
+
+// NewMappedRanger returns a read-only Ranger querying data written by
+// WriteMappedRanger in place, using codec to decode the entries it returns.
+// Only the header is checked upfront, so the call takes constant time, each
+// lookup then checks the records it reads, failing with ErrInvalidBinaryInput
+// on corrupted data.  Walk, All and Backward stop on such errors.
+//
+// Entries do not reference data, which must not be modified while ranger is
+// in use.  Mutations fail with ErrReadOnlyRanger, except Clear which has no
+// effect: ranger keeps every entry of data.
+func NewMappedRanger(data []byte, codec Codec[RangerEntry]) (Ranger, error) {
+	typed, err := NewTypedMappedRanger(data, codec)
+	if err != nil {
+		return nil, err
+	}
+	return newEntryRanger(typed), nil
+}
+
+// NewTypedMappedRanger returns a read-only TypedRanger querying data written
+// by WriteTypedMappedRanger in place, see NewMappedRanger.
+func NewTypedMappedRanger[V any](data []byte, codec Codec[V]) (TypedRanger[V], error) {
+	if len(data) < mappedHeaderSize || string(data[:len(mappedMagic)]) != mappedMagic {
+		return nil, ErrInvalidBinaryInput
+	}
+	if binary.LittleEndian.Uint32(data[4:]) != mappedVersion {
+		return nil, ErrUnsupportedBinaryVersion
+	}
+	numNodes := uint64(binary.LittleEndian.Uint32(data[8:]))
+	numSlots := uint64(binary.LittleEndian.Uint32(data[12:]))
+	numValueBytes := uint64(binary.LittleEndian.Uint32(data[16:]))
+	if mappedHeaderSize+numNodes*mappedNodeSize+numSlots*4+numValueBytes != uint64(len(data)) {
+		return nil, ErrInvalidBinaryInput
+	}
+	nodes := data[mappedHeaderSize : mappedHeaderSize+numNodes*mappedNodeSize]
+	slots := data[mappedHeaderSize+numNodes*mappedNodeSize : uint64(len(data))-numValueBytes]
+	values := data[uint64(len(data))-numValueBytes:]
+
+	var tries [2]*mappedTrie[V]
+	for i, bits := range []uint{8 * net.IPv4len, 8 * net.IPv6len} {
+		tries[i] = &mappedTrie[V]{
+			nodes:  nodes,
+			slots:  slots,
+			values: values,
+			codec:  codec,
+			root:   binary.LittleEndian.Uint32(data[20+8*i:]),
+			size:   int(binary.LittleEndian.Uint32(data[24+8*i:])),
+			bits:   bits,
+		}
+	}
+	return &versionedRanger[V]{ipV4Ranger: tries[0], ipV6Ranger: tries[1]}, nil
+}
+
+// mappedTrie is a read-only TypedRanger over the records of a path compressed
+// trie in the mapped format.
+type mappedTrie[V any] struct {
+	nodes  []byte
+	slots  []byte
+	values []byte
+	codec  Codec[V]
+	root   uint32
+	size   int
+	bits   uint // 32 for IPv4, 128 for IPv6
+}
+
+// mappedNode is a decoded node record.
+type mappedNode struct {
+	network        rnet.Network
+	numBitsSkipped uint
+	numBitsHandled uint
+	flags          byte
+	firstSlot      uint32
+	valueOffset    uint32
+	valueLength    uint32
+}
+
+func (m *mappedTrie[V]) Insert(net.IPNet, V) error {
+	return ErrReadOnlyRanger
+}
+
+func (m *mappedTrie[V]) InsertPrefix(netip.Prefix, V) error {
+	return ErrReadOnlyRanger
+}
+
+func (m *mappedTrie[V]) Upsert(net.IPNet, func(V, bool) V) error {
+	return ErrReadOnlyRanger
+}
+
+func (m *mappedTrie[V]) Swap(net.IPNet, V) (V, bool, error) {
+	var zero V
+	return zero, false, ErrReadOnlyRanger
+}
+
+func (m *mappedTrie[V]) Remove(net.IPNet) (V, bool, error) {
+	var zero V
+	return zero, false, ErrReadOnlyRanger
+}
+
+func (m *mappedTrie[V]) RemovePrefix(netip.Prefix) (V, bool, error) {
+	var zero V
+	return zero, false, ErrReadOnlyRanger
+}
+
+func (m *mappedTrie[V]) RemoveIf(net.IPNet, func(V) bool) (V, bool, error) {
+	var zero V
+	return zero, false, ErrReadOnlyRanger
+}
+
+func (m *mappedTrie[V]) RemoveCovered(net.IPNet) ([]Entry[V], error) {
+	return nil, ErrReadOnlyRanger
+}
+
+// Clear has no effect, as it can not report ErrReadOnlyRanger.
+func (m *mappedTrie[V]) Clear() {}
+
+func (m *mappedTrie[V]) Get(network net.IPNet) (V, bool, error) {
+	return m.get(rnet.NewNetwork(network))
+}
+
+func (m *mappedTrie[V]) GetPrefix(prefix netip.Prefix) (V, bool, error) {
+	network := rnet.NewNetworkFromPrefix(prefix)
+	if network.Number == nil {
+		var zero V
+		return zero, false, ErrInvalidNetworkInput
+	}
+	return m.get(network)
+}
+
+func (m *mappedTrie[V]) Contains(ip net.IP) (bool, error) {
+	nn := rnet.NewNetworkNumber(ip)
+	if nn == nil {
+		return false, ErrInvalidNetworkNumberInput
+	}
+	return m.contains(nn)
+}
+
+func (m *mappedTrie[V]) ContainsAddr(addr netip.Addr) (bool, error) {
+	var buf [rnet.IPv6Uint32Count]uint32
+	nn := rnet.AppendNetworkNumber(buf[:0], addr)
+	if len(nn) == 0 {
+		return false, ErrInvalidNetworkNumberInput
+	}
+	return m.contains(nn)
+}
+
+func (m *mappedTrie[V]) ContainingNetworks(ip net.IP) ([]Entry[V], error) {
+	nn := rnet.NewNetworkNumber(ip)
+	if nn == nil {
+		return nil, ErrInvalidNetworkNumberInput
+	}
+	return m.containingNetworks(nn)
+}
+
+func (m *mappedTrie[V]) ContainingPrefixes(addr netip.Addr) ([]Entry[V], error) {
+	var buf [rnet.IPv6Uint32Count]uint32
+	nn := rnet.AppendNetworkNumber(buf[:0], addr)
+	if len(nn) == 0 {
+		return nil, ErrInvalidNetworkNumberInput
+	}
+	return m.containingNetworks(nn)
+}
+
+func (m *mappedTrie[V]) LongestMatch(ip net.IP) (Entry[V], bool, error) {
+	addr, _ := netip.AddrFromSlice(ip)
+	return m.LongestMatchAddr(addr)
+}
+
+func (m *mappedTrie[V]) LongestMatchAddr(addr netip.Addr) (Entry[V], bool, error) {
+	var buf [rnet.IPv6Uint32Count]uint32
+	nn := rnet.AppendNetworkNumber(buf[:0], addr)
+	if len(nn) == 0 {
+		return Entry[V]{}, false, ErrInvalidNetworkNumberInput
+	}
+	return m.match(nn, true)
+}
+
+func (m *mappedTrie[V]) ShortestMatch(ip net.IP) (Entry[V], bool, error) {
+	addr, _ := netip.AddrFromSlice(ip)
+	return m.ShortestMatchAddr(addr)
+}
+
+func (m *mappedTrie[V]) ShortestMatchAddr(addr netip.Addr) (Entry[V], bool, error) {
+	var buf [rnet.IPv6Uint32Count]uint32
+	nn := rnet.AppendNetworkNumber(buf[:0], addr)
+	if len(nn) == 0 {
+		return Entry[V]{}, false, ErrInvalidNetworkNumberInput
+	}
+	return m.match(nn, false)
+}
+
+func (m *mappedTrie[V]) CoveredNetworks(network net.IPNet) ([]Entry[V], error) {
+	return m.coveredNetworks(rnet.NewNetwork(network))
+}
+
+func (m *mappedTrie[V]) CoveredPrefixes(prefix netip.Prefix) ([]Entry[V], error) {
+	network := rnet.NewNetworkFromPrefix(prefix)
+	if network.Number == nil {
+		return nil, ErrInvalidNetworkInput
+	}
+	return m.coveredNetworks(network)
+}
+
+func (m *mappedTrie[V]) CoveringNetworks(network net.IPNet) ([]Entry[V], error) {
+	return m.coveringNetworks(rnet.NewNetwork(network))
+}
+
+func (m *mappedTrie[V]) CoveringPrefixes(prefix netip.Prefix) ([]Entry[V], error) {
+	network := rnet.NewNetworkFromPrefix(prefix)
+	if network.Number == nil {
+		return nil, ErrInvalidNetworkInput
+	}
+	return m.coveringNetworks(network)
+}
+
+func (m *mappedTrie[V]) OverlappingNetworks(network net.IPNet) ([]Entry[V], error) {
+	return m.overlappingNetworks(rnet.NewNetwork(network))
+}
+
+func (m *mappedTrie[V]) OverlappingPrefixes(prefix netip.Prefix) ([]Entry[V], error) {
+	network := rnet.NewNetworkFromPrefix(prefix)
+	if network.Number == nil {
+		return nil, ErrInvalidNetworkInput
+	}
+	return m.overlappingNetworks(network)
+}
+
+// Walk calls fn for every entry in address order until fn returns false, or
+// a record fails to decode.
+func (m *mappedTrie[V]) Walk(fn func(Entry[V]) bool) {
+	root, err := m.node(m.root)
+	if err == nil {
+		m.walk(root, false, fn)
+	}
+}
+
+func (m *mappedTrie[V]) All() iter.Seq[Entry[V]] {
+	return m.Walk
+}
+
+func (m *mappedTrie[V]) Backward() iter.Seq[Entry[V]] {
+	return func(yield func(Entry[V]) bool) {
+		root, err := m.node(m.root)
+		if err == nil {
+			m.walk(root, true, yield)
+		}
+	}
+}
+
+func (m *mappedTrie[V]) Next(network net.IPNet) (Entry[V], bool, error) {
+	root, err := m.node(m.root)
+	if err != nil {
+		return Entry[V]{}, false, err
+	}
+	node, err := m.next(root, rnet.NewNetwork(network))
+	if err != nil || node == nil {
+		return Entry[V]{}, false, err
+	}
+	entry, err := m.entry(node)
+	return entry, err == nil, err
+}
+
+func (m *mappedTrie[V]) Prev(network net.IPNet) (Entry[V], bool, error) {
+	root, err := m.node(m.root)
+	if err != nil {
+		return Entry[V]{}, false, err
+	}
+	node, err := m.prev(root, rnet.NewNetwork(network))
+	if err != nil || node == nil {
+		return Entry[V]{}, false, err
+	}
+	entry, err := m.entry(node)
+	return entry, err == nil, err
+}
+
+func (m *mappedTrie[V]) EntriesBetween(start, end net.IP) ([]Entry[V], error) {
+	startNumber, endNumber := rnet.NewNetworkNumber(start), rnet.NewNetworkNumber(end)
+	if startNumber == nil || endNumber == nil || len(startNumber) != len(endNumber) {
+		return nil, ErrInvalidNetworkNumberInput
+	}
+	root, err := m.node(m.root)
+	if err != nil {
+		return nil, err
+	}
+	var results []Entry[V]
+	err = m.entriesBetween(root, startNumber, endNumber, func(entry Entry[V]) {
+		results = append(results, entry)
+	})
+	return results, err
+}
+
+func (m *mappedTrie[V]) Len() int {
+	return m.size
+}
+
+// node decodes the record at given index, checking every offset it holds.
+func (m *mappedTrie[V]) node(index uint32) (*mappedNode, error) {
+	if uint64(index) >= uint64(len(m.nodes)/mappedNodeSize) {
+		return nil, ErrInvalidBinaryInput
+	}
+	record := m.nodes[int(index)*mappedNodeSize:][:mappedNodeSize]
+	n := &mappedNode{
+		numBitsSkipped: uint(record[16]),
+		numBitsHandled: uint(record[17]),
+		flags:          record[18],
+		firstSlot:      binary.LittleEndian.Uint32(record[20:]),
+		valueOffset:    binary.LittleEndian.Uint32(record[24:]),
+		valueLength:    binary.LittleEndian.Uint32(record[28:]),
+	}
+	if n.numBitsSkipped > m.bits || n.numBitsHandled < 1 || n.numBitsHandled > maxBitsHandled {
+		return nil, ErrInvalidBinaryInput
+	}
+	if n.flags&flagHasChildren != 0 && (n.numBitsSkipped+n.numBitsHandled > m.bits ||
+		uint64(n.firstSlot)+1<<n.numBitsHandled > uint64(len(m.slots)/4)) {
+		return nil, ErrInvalidBinaryInput
+	}
+	if n.flags&flagHasValue != 0 && uint64(n.valueOffset)+uint64(n.valueLength) > uint64(len(m.values)) {
+		return nil, ErrInvalidBinaryInput
+	}
+	ip := make(net.IP, m.bits/8)
+	copy(ip, record)
+	n.network = rnet.NewNetwork(net.IPNet{IP: ip, Mask: net.CIDRMask(int(n.numBitsSkipped), int(m.bits))})
+	return n, nil
+}
+
+// child returns the child of n at given index, or nil if there is none.
+func (m *mappedTrie[V]) child(n *mappedNode, index uint32) (*mappedNode, error) {
+	if n.flags&flagHasChildren == 0 {
+		return nil, nil
+	}
+	slot := binary.LittleEndian.Uint32(m.slots[4*(int(n.firstSlot)+int(index)):])
+	if slot == noMappedChild {
+		return nil, nil
+	}
+	child, err := m.node(slot)
+	if err != nil {
+		return nil, err
+	}
+	// Children must start past their parent, which also rules out cycles.
+	if child.numBitsSkipped < n.numBitsSkipped+n.numBitsHandled {
+		return nil, ErrInvalidBinaryInput
+	}
+	return child, nil
+}
+
+func (m *mappedTrie[V]) hasEntry(n *mappedNode) bool {
+	return n.flags&flagHasValue != 0
+}
+
+func (m *mappedTrie[V]) entry(n *mappedNode) (Entry[V], error) {
+	value, err := m.codec.UnmarshalValue(n.network.IPNet, m.values[n.valueOffset:n.valueOffset+n.valueLength])
+	if err != nil {
+		return Entry[V]{}, err
+	}
+	return Entry[V]{Network: n.network.IPNet, Value: value}, nil
+}
+
+func (m *mappedTrie[V]) targetBitPosition(n *mappedNode) int {
+	return int(m.bits-n.numBitsSkipped) - 1
+}
+
+func (m *mappedTrie[V]) childIndex(n *mappedNode, number rnet.NetworkNumber) (uint32, error) {
+	return number.Bits(uint(m.targetBitPosition(n)), n.numBitsHandled)
+}
+
+// childRange mirrors prefixTrie.childRange.
+func (m *mappedTrie[V]) childRange(n *mappedNode, network rnet.Network) (uint32, uint32, error) {
+	index, err := m.childIndex(n, network.Number)
+	if err != nil {
+		return 0, 0, err
+	}
+	ones, _ := network.IPNet.Mask.Size()
+	if end := n.numBitsSkipped + n.numBitsHandled; uint(ones) < end {
+		span := uint32(1)<<(end-uint(ones)) - 1
+		return index &^ span, index | span, nil
+	}
+	return index, index, nil
+}
+
+// path calls fn for every node on the path down to number, as long as the
+// node satisfies along, until fn returns false.
+func (m *mappedTrie[V]) path(number rnet.NetworkNumber, along func(*mappedNode) bool, fn func(*mappedNode) (bool, error)) error {
+	node, err := m.node(m.root)
+	for err == nil && node != nil && along(node) {
+		var ok bool
+		if ok, err = fn(node); !ok || err != nil || m.targetBitPosition(node) < 0 {
+			break
+		}
+		var index uint32
+		if index, err = m.childIndex(node, number); err == nil {
+			node, err = m.child(node, index)
+		}
+	}
+	return err
+}
+
+func (m *mappedTrie[V]) contains(number rnet.NetworkNumber) (bool, error) {
+	var found bool
+	err := m.path(number, func(n *mappedNode) bool {
+		return n.network.Contains(number)
+	}, func(n *mappedNode) (bool, error) {
+		found = m.hasEntry(n)
+		return !found, nil
+	})
+	return found, err
+}
+
+func (m *mappedTrie[V]) containingNetworks(number rnet.NetworkNumber) ([]Entry[V], error) {
+	results := []Entry[V]{}
+	err := m.path(number, func(n *mappedNode) bool {
+		return n.network.Contains(number)
+	}, func(n *mappedNode) (bool, error) {
+		if m.hasEntry(n) {
+			entry, err := m.entry(n)
+			if err != nil {
+				return false, err
+			}
+			results = append(results, entry)
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (m *mappedTrie[V]) match(number rnet.NetworkNumber, longest bool) (Entry[V], bool, error) {
+	var matched *mappedNode
+	err := m.path(number, func(n *mappedNode) bool {
+		return n.network.Contains(number)
+	}, func(n *mappedNode) (bool, error) {
+		if m.hasEntry(n) {
+			matched = n
+		}
+		return longest || matched == nil, nil
+	})
+	if err != nil || matched == nil {
+		return Entry[V]{}, false, err
+	}
+	entry, err := m.entry(matched)
+	return entry, err == nil, err
+}
+
+func (m *mappedTrie[V]) get(network rnet.Network) (V, bool, error) {
+	var found *mappedNode
+	err := m.path(network.Number, func(*mappedNode) bool {
+		return true
+	}, func(n *mappedNode) (bool, error) {
+		if m.hasEntry(n) && n.network.Equal(network) {
+			found = n
+		}
+		return found == nil, nil
+	})
+	if err != nil || found == nil {
+		var zero V
+		return zero, false, err
+	}
+	entry, err := m.entry(found)
+	return entry.Value, err == nil, err
+}
+
+func (m *mappedTrie[V]) coveringNetworks(network rnet.Network) ([]Entry[V], error) {
+	var results []Entry[V]
+	err := m.path(network.Number, func(n *mappedNode) bool {
+		return n.network.Covers(network)
+	}, func(n *mappedNode) (bool, error) {
+		if m.hasEntry(n) {
+			entry, err := m.entry(n)
+			if err != nil {
+				return false, err
+			}
+			results = append(results, entry)
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (m *mappedTrie[V]) coveredNetworks(network rnet.Network) ([]Entry[V], error) {
+	root, err := m.node(m.root)
+	if err != nil {
+		return nil, err
+	}
+	var results []Entry[V]
+	err = m.covered(root, network, func(entry Entry[V]) {
+		results = append(results, entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// covered calls fn for every entry in the subtree of n covered by network.
+func (m *mappedTrie[V]) covered(n *mappedNode, network rnet.Network, fn func(Entry[V])) error {
+	if network.Covers(n.network) {
+		return m.collect(n, fn)
+	}
+	if !n.network.Covers(network) || m.targetBitPosition(n) < 0 {
+		return nil
+	}
+	first, last, err := m.childRange(n, network)
+	if err != nil {
+		return err
+	}
+	for index := first; index <= last; index++ {
+		child, err := m.child(n, index)
+		if err != nil {
+			return err
+		}
+		if child != nil {
+			if err := m.covered(child, network, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// overlappingNetworks mirrors prefixTrie.overlappingNetworks.
+func (m *mappedTrie[V]) overlappingNetworks(network rnet.Network) ([]Entry[V], error) {
+	var results []Entry[V]
+	collect := func(entry Entry[V]) {
+		results = append(results, entry)
+	}
+	node, err := m.node(m.root)
+	for err == nil && node != nil {
+		if network.Covers(node.network) {
+			err = m.collect(node, collect)
+			break
+		}
+		if !node.network.Covers(network) {
+			break
+		}
+		if m.hasEntry(node) {
+			var entry Entry[V]
+			if entry, err = m.entry(node); err != nil {
+				break
+			}
+			collect(entry)
+		}
+		if m.targetBitPosition(node) < 0 {
+			break
+		}
+		var first, last uint32
+		if first, last, err = m.childRange(node, network); err != nil {
+			break
+		}
+		if first != last {
+			// Every child in range is covered by network.
+			err = m.covered(node, network, collect)
+			break
+		}
+		node, err = m.child(node, first)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// collect calls fn for every entry in the subtree of n in address order.
+func (m *mappedTrie[V]) collect(n *mappedNode, fn func(Entry[V])) error {
+	var err error
+	m.walkNodes(n, false, func(node *mappedNode) bool {
+		var entry Entry[V]
+		if entry, err = m.entry(node); err != nil {
+			return false
+		}
+		fn(entry)
+		return true
+	}, &err)
+	return err
+}
+
+// walk calls fn for every entry in the subtree of n, in address order or its
+// reverse, until fn returns false or a record fails to decode.
+func (m *mappedTrie[V]) walk(n *mappedNode, backward bool, fn func(Entry[V]) bool) {
+	var err error
+	m.walkNodes(n, backward, func(node *mappedNode) bool {
+		entry, decodeErr := m.entry(node)
+		return decodeErr == nil && fn(entry)
+	}, &err)
+}
+
+// walkNodes calls fn for every node with an entry in the subtree of n, in
+// address order or its reverse, until fn returns false or a record fails to
+// decode, in which case the error is stored in err.
+func (m *mappedTrie[V]) walkNodes(n *mappedNode, backward bool, fn func(*mappedNode) bool, err *error) bool {
+	if !backward && m.hasEntry(n) && !fn(n) {
+		return false
+	}
+	if n.flags&flagHasChildren != 0 {
+		count := uint32(1) << n.numBitsHandled
+		for i := uint32(0); i < count; i++ {
+			index := i
+			if backward {
+				index = count - 1 - i
+			}
+			child, childErr := m.child(n, index)
+			if childErr != nil {
+				*err = childErr
+				return false
+			}
+			if child != nil && !m.walkNodes(child, backward, fn, err) {
+				return false
+			}
+		}
+	}
+	return !backward || !m.hasEntry(n) || fn(n)
+}
+
+// first returns the first node with an entry in the subtree of n.
+func (m *mappedTrie[V]) first(n *mappedNode) (*mappedNode, error) {
+	var found *mappedNode
+	var err error
+	m.walkNodes(n, false, func(node *mappedNode) bool {
+		found = node
+		return false
+	}, &err)
+	return found, err
+}
+
+// last returns the last node with an entry in the subtree of n.
+func (m *mappedTrie[V]) last(n *mappedNode) (*mappedNode, error) {
+	var found *mappedNode
+	var err error
+	m.walkNodes(n, true, func(node *mappedNode) bool {
+		found = node
+		return false
+	}, &err)
+	return found, err
+}
+
+// next mirrors prefixTrie.next.
+func (m *mappedTrie[V]) next(n *mappedNode, network rnet.Network) (*mappedNode, error) {
+	if n.network.Compare(network) > 0 {
+		return m.first(n)
+	}
+	if !n.network.Contains(network.Number) || m.targetBitPosition(n) < 0 || n.flags&flagHasChildren == 0 {
+		return nil, nil
+	}
+	index, err := m.childIndex(n, network.Number)
+	if err != nil {
+		return nil, err
+	}
+	for i := index; i < uint32(1)<<n.numBitsHandled; i++ {
+		child, err := m.child(n, i)
+		if err != nil {
+			return nil, err
+		}
+		if child == nil {
+			continue
+		}
+		var node *mappedNode
+		if i == index {
+			node, err = m.next(child, network)
+		} else {
+			node, err = m.first(child)
+		}
+		if err != nil || node != nil {
+			return node, err
+		}
+	}
+	return nil, nil
+}
+
+// prev mirrors prefixTrie.prev.
+func (m *mappedTrie[V]) prev(n *mappedNode, network rnet.Network) (*mappedNode, error) {
+	if n.network.Compare(network) >= 0 {
+		return nil, nil
+	}
+	if !n.network.Contains(network.Number) {
+		return m.last(n)
+	}
+	if m.targetBitPosition(n) >= 0 && n.flags&flagHasChildren != 0 {
+		index, err := m.childIndex(n, network.Number)
+		if err != nil {
+			return nil, err
+		}
+		for i := int(index); i >= 0; i-- {
+			child, err := m.child(n, uint32(i))
+			if err != nil {
+				return nil, err
+			}
+			if child == nil {
+				continue
+			}
+			var node *mappedNode
+			if i == int(index) {
+				node, err = m.prev(child, network)
+			} else {
+				node, err = m.last(child)
+			}
+			if err != nil || node != nil {
+				return node, err
+			}
+		}
+	}
+	if m.hasEntry(n) {
+		return n, nil
+	}
+	return nil, nil
+}
+
+// entriesBetween mirrors prefixTrie.entriesBetween.
+func (m *mappedTrie[V]) entriesBetween(n *mappedNode, start, end rnet.NetworkNumber, fn func(Entry[V])) error {
+	number := n.network.Number
+	if number.Compare(end) > 0 || (number.Compare(start) < 0 && !n.network.Contains(start)) {
+		return nil
+	}
+	if m.hasEntry(n) {
+		entry, err := m.entry(n)
+		if err != nil {
+			return err
+		}
+		fn(entry)
+	}
+	if n.flags&flagHasChildren == 0 {
+		return nil
+	}
+	for i := uint32(0); i < uint32(1)<<n.numBitsHandled; i++ {
+		child, err := m.child(n, i)
+		if err != nil {
+			return err
+		}
+		if child != nil {
+			if err := m.entriesBetween(child, start, end, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package cidranger
+
+import (
+	"bytes"
+	"math/rand"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMappedRangerAgainstPCTrie(t *testing.T) {
+	ranger := NewPCTrieRanger()
+	configureRangerWithAWSRanges(t, ranger)
+	var buf bytes.Buffer
+	assert.NoError(t, WriteMappedRanger(&buf, ranger, BasicEntryCodec))
+	mapped, err := NewMappedRanger(buf.Bytes(), BasicEntryCodec)
+	assert.NoError(t, err)
+
+	assert.Equal(t, ranger.Len(), mapped.Len())
+	assert.Equal(t, networksOf(ranger), networksOf(mapped))
+	var expected, actual []RangerEntry
+	for entry := range ranger.Backward() {
+		expected = append(expected, entry)
+	}
+	for entry := range mapped.Backward() {
+		actual = append(actual, entry)
+	}
+	assert.Equal(t, networkStrings(expected), networkStrings(actual))
+
+	for _, netGen := range []networkGenerator{
+		resizedIPNetGenFactory(ipV4AWSRangesIPNets),
+		resizedIPNetGenFactory(ipV6AWSRangesIPNets),
+	} {
+		for i := 0; i < 2000; i++ {
+			network := netGen()
+			ip := network.IP
+
+			expectedContains, err := ranger.Contains(ip)
+			assert.NoError(t, err)
+			actualContains, err := mapped.Contains(ip)
+			assert.NoError(t, err)
+			assert.Equal(t, expectedContains, actualContains)
+
+			expectedEntries, err := ranger.ContainingNetworks(ip)
+			assert.NoError(t, err)
+			actualEntries, err := mapped.ContainingNetworks(ip)
+			assert.NoError(t, err)
+			assert.Equal(t, networkStrings(expectedEntries), networkStrings(actualEntries))
+
+			expectedMatch, expectedFound, err := ranger.LongestMatch(ip)
+			assert.NoError(t, err)
+			actualMatch, actualFound, err := mapped.LongestMatch(ip)
+			assert.NoError(t, err)
+			assert.Equal(t, expectedFound, actualFound)
+			if expectedFound {
+				assert.Equal(t, networkStrings([]RangerEntry{expectedMatch}), networkStrings([]RangerEntry{actualMatch}))
+			}
+
+			for _, query := range []func(Ranger, net.IPNet) ([]RangerEntry, error){
+				Ranger.CoveredNetworks,
+				Ranger.CoveringNetworks,
+				Ranger.OverlappingNetworks,
+			} {
+				expectedEntries, err := query(ranger, network.IPNet)
+				assert.NoError(t, err)
+				actualEntries, err := query(mapped, network.IPNet)
+				assert.NoError(t, err)
+				assert.Equal(t, networkStrings(expectedEntries), networkStrings(actualEntries))
+			}
+
+			for _, query := range []func(Ranger, net.IPNet) (RangerEntry, bool, error){
+				Ranger.Next,
+				Ranger.Prev,
+			} {
+				expectedEntry, expectedFound, err := query(ranger, network.IPNet)
+				assert.NoError(t, err)
+				actualEntry, actualFound, err := query(mapped, network.IPNet)
+				assert.NoError(t, err)
+				assert.Equal(t, expectedFound, actualFound)
+				if expectedFound {
+					assert.Equal(t, networkStrings([]RangerEntry{expectedEntry}), networkStrings([]RangerEntry{actualEntry}))
+				}
+			}
+
+			_, expectedFound, err = ranger.Get(network.IPNet)
+			assert.NoError(t, err)
+			_, actualFound, err = mapped.Get(network.IPNet)
+			assert.NoError(t, err)
+			assert.Equal(t, expectedFound, actualFound)
+		}
+	}
+
+	expectedEntries, err := ranger.EntriesBetween(net.ParseIP("52.0.0.0"), net.ParseIP("54.255.0.0"))
+	assert.NoError(t, err)
+	actualEntries, err := mapped.EntriesBetween(net.ParseIP("52.0.0.0"), net.ParseIP("54.255.0.0"))
+	assert.NoError(t, err)
+	assert.Equal(t, networkStrings(expectedEntries), networkStrings(actualEntries))
+}
+
+func TestMappedRangerFile(t *testing.T) {
+	ranger := NewTypedPCTrieRanger[string]()
+	assert.NoError(t, ranger.InsertPrefix(netip.MustParsePrefix("10.0.0.0/8"), "private"))
+	assert.NoError(t, ranger.InsertPrefix(netip.MustParsePrefix("10.1.0.0/16"), "office"))
+	assert.NoError(t, ranger.InsertPrefix(netip.MustParsePrefix("2001:db8::/32"), "documentation"))
+
+	path := filepath.Join(t.TempDir(), "ranger.cidm")
+	file, err := os.Create(path)
+	assert.NoError(t, err)
+	assert.NoError(t, WriteTypedMappedRanger(file, ranger, StringCodec))
+	assert.NoError(t, file.Close())
+
+	mappedFile, err := MapFile(path)
+	assert.NoError(t, err)
+	mapped, err := NewTypedMappedRanger(mappedFile.Bytes(), StringCodec)
+	assert.NoError(t, err)
+	entries, err := mapped.ContainingPrefixes(netip.MustParseAddr("10.1.2.3"))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "private", entries[0].Value)
+	assert.Equal(t, "office", entries[1].Value)
+	contains, err := mapped.ContainsAddr(netip.MustParseAddr("2001:db8::1"))
+	assert.NoError(t, err)
+	assert.True(t, contains)
+	assert.Equal(t, 3, mapped.Len())
+	assert.NoError(t, mappedFile.Close())
+
+	// Entries outlive the mapping.
+	assert.Equal(t, "10.1.0.0/16", entries[1].Network.String())
+}
+
+func TestMappedRangerIsReadOnly(t *testing.T) {
+	ranger := NewPCTrieRanger()
+	assert.NoError(t, ranger.InsertPrefix(netip.MustParsePrefix("10.0.0.0/8")))
+	var buf bytes.Buffer
+	assert.NoError(t, WriteMappedRanger(&buf, ranger, BasicEntryCodec))
+	mapped, err := NewMappedRanger(buf.Bytes(), BasicEntryCodec)
+	assert.NoError(t, err)
+
+	assert.Equal(t, ErrReadOnlyRanger, mapped.InsertPrefix(netip.MustParsePrefix("192.168.0.0/16")))
+	_, err = mapped.RemovePrefix(netip.MustParsePrefix("10.0.0.0/8"))
+	assert.Equal(t, ErrReadOnlyRanger, err)
+	_, err = mapped.RemoveCovered(*AllIPv4)
+	assert.Equal(t, ErrReadOnlyRanger, err)
+	// Clear can not report ErrReadOnlyRanger and leaves entries in place.
+	mapped.Clear()
+	assert.Equal(t, 1, mapped.Len())
+	contains, err := mapped.Contains(net.ParseIP("10.0.0.1"))
+	assert.NoError(t, err)
+	assert.True(t, contains)
+}
+
+func TestMappedRangerInvalidInput(t *testing.T) {
+	ranger := NewPCTrieRanger()
+	configureRangerWithAWSRanges(t, ranger)
+	var buf bytes.Buffer
+	assert.NoError(t, WriteMappedRanger(&buf, ranger, BasicEntryCodec))
+	data := buf.Bytes()
+
+	_, err := NewMappedRanger(data[:len(data)-1], BasicEntryCodec)
+	assert.Equal(t, ErrInvalidBinaryInput, err)
+	_, err = NewMappedRanger(nil, BasicEntryCodec)
+	assert.Equal(t, ErrInvalidBinaryInput, err)
+	future := append([]byte(nil), data...)
+	future[4]++
+	_, err = NewMappedRanger(future, BasicEntryCodec)
+	assert.Equal(t, ErrUnsupportedBinaryVersion, err)
+
+	// Corrupted records fail lookups instead of crashing them.
+	for i := 0; i < 200; i++ {
+		corrupted := append([]byte(nil), data...)
+		for j := 0; j < 8; j++ {
+			corrupted[mappedHeaderSize+rand.Intn(len(corrupted)-mappedHeaderSize)] = byte(rand.Intn(256))
+		}
+		mapped, err := NewMappedRanger(corrupted, BasicEntryCodec)
+		assert.NoError(t, err)
+		for _, network := range ipV4AWSRangesIPNets[:20] {
+			mapped.ContainingNetworks(network.IP)
+			mapped.CoveredNetworks(*network)
+			mapped.Next(*network)
+		}
+		for range mapped.All() {
+		}
+	}
+}
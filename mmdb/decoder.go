@@ -0,0 +1,222 @@
+package mmdb
+
+import (
+	"encoding/binary"
+	"math"
+	"math/big"
+)
+
+// Data section types.
+const (
+	typeExtended  = 0
+	typePointer   = 1
+	typeString    = 2
+	typeDouble    = 3
+	typeBytes     = 4
+	typeUint16    = 5
+	typeUint32    = 6
+	typeMap       = 7
+	typeInt32     = 8
+	typeUint64    = 9
+	typeUint128   = 10
+	typeArray     = 11
+	typeContainer = 12
+	typeEnd       = 13
+	typeBool      = 14
+	typeFloat     = 15
+)
+
+// maxDecodeDepth bounds the nesting of decoded maps, arrays and pointers,
+// which also stops pointer cycles.
+const maxDecodeDepth = 256
+
+// decoder decodes values of the data section format from buf, which is
+// either the data section or the metadata.
+type decoder struct {
+	buf []byte
+}
+
+// decode decodes the value at given offset, returning it along with the
+// offset following it.  Values decode to string, float64, []byte, uint16,
+// uint32, map[string]any, int32, uint64, *big.Int, []any, bool and float32.
+func (d *decoder) decode(offset uint) (any, uint, error) {
+	return d.decodeAt(offset, 0)
+}
+
+func (d *decoder) decodeAt(offset uint, depth int) (any, uint, error) {
+	if depth > maxDecodeDepth {
+		return nil, 0, ErrInvalidDatabase
+	}
+	kind, size, offset, err := d.control(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	if kind == typePointer {
+		pointer, next, err := d.pointer(size, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		value, _, err := d.decodeAt(pointer, depth+1)
+		return value, next, err
+	}
+	switch kind {
+	case typeMap:
+		m := make(map[string]any, min(size, 64))
+		for i := uint(0); i < size; i++ {
+			var key, value any
+			if key, offset, err = d.decodeAt(offset, depth+1); err != nil {
+				return nil, 0, err
+			}
+			name, ok := key.(string)
+			if !ok {
+				return nil, 0, ErrInvalidDatabase
+			}
+			if value, offset, err = d.decodeAt(offset, depth+1); err != nil {
+				return nil, 0, err
+			}
+			m[name] = value
+		}
+		return m, offset, nil
+	case typeArray:
+		a := make([]any, 0, min(size, 64))
+		for i := uint(0); i < size; i++ {
+			var value any
+			if value, offset, err = d.decodeAt(offset, depth+1); err != nil {
+				return nil, 0, err
+			}
+			a = append(a, value)
+		}
+		return a, offset, nil
+	case typeBool:
+		if size > 1 {
+			return nil, 0, ErrInvalidDatabase
+		}
+		return size == 1, offset, nil
+	}
+
+	payload, err := d.bytes(offset, size)
+	if err != nil {
+		return nil, 0, err
+	}
+	next := offset + size
+	switch kind {
+	case typeString:
+		return string(payload), next, nil
+	case typeBytes:
+		return append([]byte{}, payload...), next, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, ErrInvalidDatabase
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(payload)), next, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, ErrInvalidDatabase
+		}
+		return math.Float32frombits(binary.BigEndian.Uint32(payload)), next, nil
+	case typeUint16:
+		if size > 2 {
+			return nil, 0, ErrInvalidDatabase
+		}
+		return uint16(unsigned(payload)), next, nil
+	case typeUint32:
+		if size > 4 {
+			return nil, 0, ErrInvalidDatabase
+		}
+		return uint32(unsigned(payload)), next, nil
+	case typeInt32:
+		if size > 4 {
+			return nil, 0, ErrInvalidDatabase
+		}
+		return int32(uint32(unsigned(payload))), next, nil
+	case typeUint64:
+		if size > 8 {
+			return nil, 0, ErrInvalidDatabase
+		}
+		return unsigned(payload), next, nil
+	case typeUint128:
+		if size > 16 {
+			return nil, 0, ErrInvalidDatabase
+		}
+		return new(big.Int).SetBytes(payload), next, nil
+	}
+	// Data cache containers and end markers never hold values.
+	return nil, 0, ErrInvalidDatabase
+}
+
+// control decodes the control byte at offset, returning the type and size it
+// holds, along with the offset of the payload.  The size of pointers is left
+// as is, that is the 5 bits holding their own size and value.
+func (d *decoder) control(offset uint) (byte, uint, uint, error) {
+	header, err := d.bytes(offset, 1)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	offset++
+	kind := header[0] >> 5
+	if kind == typeExtended {
+		extended, err := d.bytes(offset, 1)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		offset++
+		if extended[0] == 0 || extended[0] > typeFloat-7 {
+			return 0, 0, 0, ErrInvalidDatabase
+		}
+		kind = extended[0] + 7
+	}
+	size := uint(header[0] & 0x1f)
+	if kind == typePointer || size < 29 {
+		return kind, size, offset, nil
+	}
+	n := size - 28
+	extra, err := d.bytes(offset, n)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	switch size {
+	case 29:
+		size = 29 + uint(unsigned(extra))
+	case 30:
+		size = 285 + uint(unsigned(extra))
+	default:
+		size = 65821 + uint(unsigned(extra))
+	}
+	return kind, size, offset + n, nil
+}
+
+// pointer decodes a pointer of given size bits whose value starts at offset,
+// returning the offset it points to and the offset following it.
+func (d *decoder) pointer(size, offset uint) (uint, uint, error) {
+	n := size>>3&0x3 + 1
+	payload, err := d.bytes(offset, n)
+	if err != nil {
+		return 0, 0, err
+	}
+	value := uint(unsigned(payload))
+	switch n {
+	case 1:
+		value |= (size & 0x7) << 8
+	case 2:
+		value = (size&0x7)<<16 | value + 2048
+	case 3:
+		value = (size&0x7)<<24 | value + 526336
+	}
+	return value, offset + n, nil
+}
+
+func (d *decoder) bytes(offset, n uint) ([]byte, error) {
+	if offset > uint(len(d.buf)) || n > uint(len(d.buf))-offset {
+		return nil, ErrInvalidDatabase
+	}
+	return d.buf[offset : offset+n], nil
+}
+
+// unsigned decodes a big endian unsigned integer of up to 8 bytes.
+func unsigned(b []byte) uint64 {
+	var value uint64
+	for _, c := range b {
+		value = value<<8 | uint64(c)
+	}
+	return value
+}
@@ -0,0 +1,180 @@
+package mmdb
+
+import (
+	"encoding/binary"
+	"math"
+	"math/big"
+	"sort"
+)
+
+// encoder encodes values in the data section format.
+type encoder struct {
+	buf []byte
+	// strings holds the offsets of the strings written, to point at them
+	// when repeated, and records those of the records written.  Both are nil
+	// when encoding a single value.
+	strings map[string]uint
+	records map[string]uint
+}
+
+func newEncoder() *encoder {
+	return &encoder{strings: make(map[string]uint), records: make(map[string]uint)}
+}
+
+// record writes value unless an equal value was written before, returning
+// its offset.
+func (e *encoder) record(value any) (uint, error) {
+	single := &encoder{}
+	if err := single.encode(value); err != nil {
+		return 0, err
+	}
+	if offset, ok := e.records[string(single.buf)]; ok {
+		return offset, nil
+	}
+	offset := uint(len(e.buf))
+	if err := e.encode(value); err != nil {
+		return 0, err
+	}
+	e.records[string(single.buf)] = offset
+	return offset, nil
+}
+
+// maxSize is the largest size of a value.
+const maxSize = 65821 + 1<<24 - 1
+
+// encode writes value, which must be of one of the types values decode to.
+func (e *encoder) encode(value any) error {
+	switch value := value.(type) {
+	case string, []byte, []any, map[string]any:
+		if sizeOf(value) > maxSize {
+			return ErrUnsupportedValue
+		}
+	}
+	switch value := value.(type) {
+	case string:
+		if offset, ok := e.strings[value]; ok {
+			e.pointer(offset)
+			return nil
+		}
+		// Pointers take up to 5 bytes.
+		if e.strings != nil && len(value) >= 4 {
+			e.strings[value] = uint(len(e.buf))
+		}
+		e.control(typeString, len(value))
+		e.buf = append(e.buf, value...)
+	case []byte:
+		e.control(typeBytes, len(value))
+		e.buf = append(e.buf, value...)
+	case float64:
+		e.control(typeDouble, 8)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, math.Float64bits(value))
+	case float32:
+		e.control(typeFloat, 4)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, math.Float32bits(value))
+	case bool:
+		size := 0
+		if value {
+			size = 1
+		}
+		e.control(typeBool, size)
+	case uint16:
+		e.unsigned(typeUint16, uint64(value))
+	case uint32:
+		e.unsigned(typeUint32, uint64(value))
+	case int32:
+		e.unsigned(typeInt32, uint64(uint32(value)))
+	case uint64:
+		e.unsigned(typeUint64, value)
+	case *big.Int:
+		if value.Sign() < 0 || value.BitLen() > 128 {
+			return ErrUnsupportedValue
+		}
+		e.control(typeUint128, len(value.Bytes()))
+		e.buf = append(e.buf, value.Bytes()...)
+	case []any:
+		e.control(typeArray, len(value))
+		for _, element := range value {
+			if err := e.encode(element); err != nil {
+				return err
+			}
+		}
+	case map[string]any:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		e.control(typeMap, len(keys))
+		for _, key := range keys {
+			e.encode(key)
+			if err := e.encode(value[key]); err != nil {
+				return err
+			}
+		}
+	default:
+		return ErrUnsupportedValue
+	}
+	return nil
+}
+
+func sizeOf(value any) int {
+	switch value := value.(type) {
+	case string:
+		return len(value)
+	case []byte:
+		return len(value)
+	case []any:
+		return len(value)
+	case map[string]any:
+		return len(value)
+	}
+	return 0
+}
+
+// unsigned writes value in as few bytes as it takes.
+func (e *encoder) unsigned(kind byte, value uint64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], value)
+	n := 0
+	for n < 8 && b[n] == 0 {
+		n++
+	}
+	e.control(kind, 8-n)
+	e.buf = append(e.buf, b[n:]...)
+}
+
+func (e *encoder) control(kind byte, size int) {
+	var extra []byte
+	switch {
+	case size < 29:
+	case size < 285:
+		extra, size = []byte{byte(size - 29)}, 29
+	case size < 65821:
+		extra, size = []byte{byte((size - 285) >> 8), byte(size - 285)}, 30
+	default:
+		size -= 65821
+		extra, size = []byte{byte(size >> 16), byte(size >> 8), byte(size)}, 31
+	}
+	if kind > 7 {
+		e.buf = append(e.buf, byte(size), kind-7)
+	} else {
+		e.buf = append(e.buf, kind<<5|byte(size))
+	}
+	e.buf = append(e.buf, extra...)
+}
+
+func (e *encoder) pointer(offset uint) {
+	switch {
+	case offset < 2048:
+		e.buf = append(e.buf, typePointer<<5|byte(offset>>8), byte(offset))
+	case offset < 526336:
+		offset -= 2048
+		e.buf = append(e.buf, typePointer<<5|1<<3|byte(offset>>16), byte(offset>>8), byte(offset))
+	case offset < 134744064:
+		offset -= 526336
+		e.buf = append(e.buf, typePointer<<5|2<<3|byte(offset>>24), byte(offset>>16), byte(offset>>8), byte(offset))
+	default:
+		e.buf = append(e.buf, typePointer<<5|3<<3)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(offset))
+	}
+}
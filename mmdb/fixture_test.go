@@ -0,0 +1,139 @@
+package mmdb
+
+import (
+	"encoding/binary"
+	"net/netip"
+)
+
+// fixture builds small databases for tests, with search trees Write would
+// not write.
+type fixture struct {
+	ipVersion  int
+	recordSize int
+	root       *fixtureNode
+}
+
+type fixtureNode struct {
+	children [2]*fixtureNode
+	record   any
+	leaf     bool
+}
+
+func newFixture(ipVersion, recordSize int) *fixture {
+	return &fixture{ipVersion: ipVersion, recordSize: recordSize, root: &fixtureNode{}}
+}
+
+// insert stores record for prefix, which must not overlap any prefix
+// inserted before.  IPv4 prefixes go below ::/96 in IPv6 databases.
+func (f *fixture) insert(prefix string, record any) *fixture {
+	node, bits := f.path(netip.MustParsePrefix(prefix))
+	for _, bit := range bits {
+		if node.children[bit] == nil {
+			node.children[bit] = &fixtureNode{}
+		}
+		node = node.children[bit]
+	}
+	node.record, node.leaf = record, true
+	return f
+}
+
+// alias points the IPv6 prefix, of up to 96 bits, at the IPv4 space the way
+// IPv6 databases alias ::ffff:0:0/96.
+func (f *fixture) alias(prefix string) *fixture {
+	ipv4, _ := f.path(netip.MustParsePrefix("0.0.0.0/0"))
+	node, bits := f.path(netip.MustParsePrefix(prefix))
+	for _, bit := range bits[:len(bits)-1] {
+		if node.children[bit] == nil {
+			node.children[bit] = &fixtureNode{}
+		}
+		node = node.children[bit]
+	}
+	node.children[bits[len(bits)-1]] = ipv4
+	return f
+}
+
+func (f *fixture) path(prefix netip.Prefix) (*fixtureNode, []byte) {
+	addr, node := prefix.Addr().AsSlice(), f.root
+	if prefix.Addr().Is4() && f.ipVersion == 6 {
+		for i := 0; i < 96; i++ {
+			if node.children[0] == nil {
+				node.children[0] = &fixtureNode{}
+			}
+			node = node.children[0]
+		}
+	}
+	var bits []byte
+	for i := 0; i < prefix.Bits(); i++ {
+		bits = append(bits, bitAt(addr, i))
+	}
+	return node, bits
+}
+
+// bytes returns the database.
+func (f *fixture) bytes() []byte {
+	// Number nodes breadth first, nodes shared by aliases once.
+	index := map[*fixtureNode]int{}
+	var nodes []*fixtureNode
+	for queue := []*fixtureNode{f.root}; len(queue) > 0; queue = queue[1:] {
+		node := queue[0]
+		if _, ok := index[node]; ok || node.leaf {
+			continue
+		}
+		index[node] = len(nodes)
+		nodes = append(nodes, node)
+		for _, child := range node.children {
+			if child != nil {
+				queue = append(queue, child)
+			}
+		}
+	}
+	data := newEncoder()
+	records := map[*fixtureNode]uint64{}
+	recordOf := func(node *fixtureNode) uint64 {
+		switch {
+		case node == nil:
+			return uint64(len(nodes))
+		case !node.leaf:
+			return uint64(index[node])
+		}
+		if _, ok := records[node]; !ok {
+			offset, err := data.record(node.record)
+			if err != nil {
+				panic(err)
+			}
+			records[node] = uint64(offset) + uint64(len(nodes)+dataSectionSeparator)
+		}
+		return records[node]
+	}
+
+	var tree []byte
+	for _, node := range nodes {
+		left, right := recordOf(node.children[0]), recordOf(node.children[1])
+		switch f.recordSize {
+		case 24:
+			tree = append(tree, byte(left>>16), byte(left>>8), byte(left), byte(right>>16), byte(right>>8), byte(right))
+		case 28:
+			tree = append(tree, byte(left>>16), byte(left>>8), byte(left),
+				byte(left>>20&0xf0|right>>24&0x0f), byte(right>>16), byte(right>>8), byte(right))
+		case 32:
+			tree = binary.BigEndian.AppendUint32(tree, uint32(left))
+			tree = binary.BigEndian.AppendUint32(tree, uint32(right))
+		}
+	}
+	buf := append(tree, make([]byte, dataSectionSeparator)...)
+	buf = append(buf, data.buf...)
+	buf = append(buf, metadataMarker...)
+	metadata := &encoder{}
+	metadata.encode(map[string]any{
+		"node_count":                  uint32(len(nodes)),
+		"record_size":                 uint16(f.recordSize),
+		"ip_version":                  uint16(f.ipVersion),
+		"database_type":               "Test",
+		"languages":                   []any{"en"},
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(1700000000),
+		"description":                 map[string]any{"en": "Test database"},
+	})
+	return append(buf, metadata.buf...)
+}
@@ -0,0 +1,440 @@
+/*
+Package mmdb reads and writes MaxMind DB files, the format of GeoIP2,
+GeoLite2 and many other IP databases, without depending on any MaxMind
+library.
+
+A Reader either imports every network of a database into a cidranger.Ranger,
+with the records decoded as Entry(s), or serves the search tree of the
+database itself as a read-only cidranger.Ranger, decoding records as they are
+looked up:
+
+	reader, err := mmdb.Open("GeoLite2-Country.mmdb")
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	entry, found, err := reader.Ranger().LongestMatch(net.ParseIP("1.1.1.1"))
+
+Records decode to string, float64, float32, []byte, bool, int32, uint16,
+uint32, uint64, *big.Int, []any and map[string]any values.
+
+Write exports a cidranger.Ranger as a database for other tools to read,
+with the records returned by an Encoder:
+
+	err := mmdb.Write(file, ranger, mmdb.Metadata{DatabaseType: "Sites"}, func(entry cidranger.RangerEntry) (any, error) {
+		return map[string]any{"site": entry.(*siteEntry).site}, nil
+	})
+
+Networks of a database never overlap.  IPv6 databases commonly alias the IPv4
+space at several places, such as ::ffff:0:0/96, those aliases are left out
+and IPv4 networks are only reported once, as IPv4 networks.
+*/
+package mmdb
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/netip"
+
+	"github.com/yl2chen/cidranger"
+)
+
+// ErrInvalidDatabase is returned when a database is malformed.
+var ErrInvalidDatabase = fmt.Errorf("Invalid MaxMind database")
+
+// ErrUnsupportedDatabase is returned for databases of an unsupported binary
+// format version or record size.
+var ErrUnsupportedDatabase = fmt.Errorf("Unsupported MaxMind database")
+
+// metadataMarker precedes the metadata, at the end of a database.
+var metadataMarker = []byte("\xab\xcd\xefMaxMind.com")
+
+// ipv4SpaceParent is the network whose left record is the IPv4 space,
+// ::/96, within IPv6 databases.
+var ipv4SpaceParent = netip.MustParsePrefix("::/95")
+
+// dataSectionSeparator is the number of zero bytes between the search tree
+// and the data section.
+const dataSectionSeparator = 16
+
+// Entry is a cidranger.RangerEntry for a network of a database, along with
+// its decoded record.  Networks sharing a record share its decoded value.
+type Entry struct {
+	ipNet  net.IPNet
+	Record any
+}
+
+// Network returns the network of entry.
+func (e *Entry) Network() net.IPNet {
+	return e.ipNet
+}
+
+// Metadata describes a database, and configures the databases Write writes.
+type Metadata struct {
+	NodeCount                uint
+	RecordSize               uint
+	IPVersion                uint
+	DatabaseType             string
+	Languages                []string
+	BinaryFormatMajorVersion uint
+	BinaryFormatMinorVersion uint
+	BuildEpoch               uint64
+	Description              map[string]string
+}
+
+// Reader reads a database held in memory.
+type Reader struct {
+	Metadata Metadata
+
+	file *cidranger.MappedFile
+	tree []byte
+	data decoder
+	// nodeCount is also the record of empty leaves, records above it point
+	// into the data section.
+	nodeCount uint
+	// ipv4Root is the record reached following the 96 first bits of IPv4
+	// addresses in an IPv6 database, and 0 in an IPv4 database.
+	ipv4Root uint
+}
+
+// Open maps the database at path into memory and returns a Reader for it,
+// which must be closed once done with.
+func Open(path string) (*Reader, error) {
+	file, err := cidranger.MapFile(path)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := FromBytes(file.Bytes())
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	reader.file = file
+	return reader, nil
+}
+
+// FromBytes returns a Reader for the database held in data, which must not
+// be modified while the reader is in use.
+func FromBytes(data []byte) (*Reader, error) {
+	start := bytes.LastIndex(data, metadataMarker)
+	if start < 0 {
+		return nil, ErrInvalidDatabase
+	}
+	metadata, err := parseMetadata(data[start+len(metadataMarker):])
+	if err != nil {
+		return nil, err
+	}
+	if metadata.BinaryFormatMajorVersion != 2 {
+		return nil, ErrUnsupportedDatabase
+	}
+	switch metadata.RecordSize {
+	case 24, 28, 32:
+	default:
+		return nil, ErrUnsupportedDatabase
+	}
+	if metadata.IPVersion != 4 && metadata.IPVersion != 6 {
+		return nil, ErrInvalidDatabase
+	}
+	if metadata.NodeCount > uint(start)/(metadata.RecordSize/4) {
+		return nil, ErrInvalidDatabase
+	}
+	treeSize := metadata.NodeCount * metadata.RecordSize / 4
+	if treeSize+dataSectionSeparator > uint(start) {
+		return nil, ErrInvalidDatabase
+	}
+	reader := &Reader{
+		Metadata:  metadata,
+		tree:      data[:treeSize],
+		data:      decoder{buf: data[treeSize+dataSectionSeparator : start]},
+		nodeCount: metadata.NodeCount,
+	}
+	if metadata.IPVersion == 6 {
+		record := uint(0)
+		for i := 0; i < 96 && record < reader.nodeCount; i++ {
+			record = reader.record(record, 0)
+		}
+		reader.ipv4Root = record
+	}
+	return reader, nil
+}
+
+// Close unmaps the database of a Reader returned by Open, the reader and
+// the rangers it returned must not be used afterwards.
+func (r *Reader) Close() error {
+	if r.file == nil {
+		return nil
+	}
+	file := r.file
+	r.file = nil
+	return file.Close()
+}
+
+// Lookup returns the network containing ip and its decoded record, or nil
+// if the database holds no record for ip.
+func (r *Reader) Lookup(ip net.IP) (*Entry, error) {
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return nil, cidranger.ErrInvalidNetworkNumberInput
+	}
+	return r.lookup(addr.Unmap())
+}
+
+// Import inserts every network of the database into ranger as an Entry, or
+// none of them if reading the database fails.
+func (r *Reader) Import(ranger cidranger.Ranger) error {
+	batch := cidranger.NewBatch()
+	records := make(map[uint]any)
+	for _, f := range r.families() {
+		_, err := r.walk(f, f.root, f.zero(), false, nil, func(record uint, prefix netip.Prefix) (bool, error) {
+			value, ok := records[record]
+			if !ok {
+				var err error
+				if value, err = r.resolve(record); err != nil {
+					return false, err
+				}
+				records[record] = value
+			}
+			batch.Insert(&Entry{ipNet: ipNetOf(prefix), Record: value})
+			return true, nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return batch.Commit(ranger)
+}
+
+// Ranger returns a read-only cidranger.Ranger over the search tree of the
+// database, returning Entry(s).  Mutations fail with
+// cidranger.ErrReadOnlyRanger, except Clear which has no effect: the ranger
+// keeps every network of the database.
+func (r *Reader) Ranger() cidranger.Ranger {
+	return &treeRanger{reader: r}
+}
+
+// family is the part of the search tree holding the networks of an IP
+// version.
+type family struct {
+	root uint
+	bits int
+}
+
+// zero returns the prefix of the root of f.
+func (f family) zero() netip.Prefix {
+	if f.bits == 32 {
+		return netip.PrefixFrom(netip.IPv4Unspecified(), 0)
+	}
+	return netip.PrefixFrom(netip.IPv6Unspecified(), 0)
+}
+
+// families returns the families of the database, IPv4 first.
+func (r *Reader) families() []family {
+	if r.Metadata.IPVersion == 4 {
+		return []family{{root: 0, bits: 32}}
+	}
+	return []family{{root: r.ipv4Root, bits: 32}, {root: 0, bits: 128}}
+}
+
+// familyOf returns the family holding addr, and whether the database has
+// one.
+func (r *Reader) familyOf(addr netip.Addr) (family, bool) {
+	if addr.Is4() {
+		return r.families()[0], true
+	}
+	if r.Metadata.IPVersion == 4 {
+		return family{}, false
+	}
+	return r.families()[1], true
+}
+
+// record returns the left or right record, per given bit, of node.
+func (r *Reader) record(node uint, bit byte) uint {
+	switch r.Metadata.RecordSize {
+	case 24:
+		b := r.tree[node*6+uint(bit)*3:]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		b := r.tree[node*7:]
+		if bit == 0 {
+			return uint(b[3]&0xf0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0f)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	}
+	b := r.tree[node*8+uint(bit)*4:]
+	return uint(b[0])<<24 | uint(b[1])<<16 | uint(b[2])<<8 | uint(b[3])
+}
+
+// child returns the record of node, found at prefix within f, for given
+// bit.  The IPv4 space at ::/96 and its aliases met within the IPv6 family
+// are reported empty.
+func (r *Reader) child(f family, node uint, prefix netip.Prefix, bit byte) uint {
+	record := r.record(node, bit)
+	if f.bits == 128 && (record == r.ipv4Root && r.ipv4Root < r.nodeCount ||
+		bit == 0 && prefix == ipv4SpaceParent) {
+		return r.nodeCount
+	}
+	return record
+}
+
+// descend follows the bits of prefix down from the root of f, until it
+// reaches a leaf or the length of prefix, returning the record found and
+// its prefix.
+func (r *Reader) descend(f family, prefix netip.Prefix) (uint, netip.Prefix, error) {
+	addr := prefix.Addr().AsSlice()
+	record, leaf := f.root, f.zero()
+	for leaf.Bits() < prefix.Bits() && record < r.nodeCount {
+		bit := bitAt(addr, leaf.Bits())
+		record = r.child(f, record, leaf, bit)
+		leaf = childPrefix(leaf, bit)
+	}
+	if record < r.nodeCount && leaf.Bits() == f.bits {
+		return 0, netip.Prefix{}, ErrInvalidDatabase
+	}
+	return record, leaf, nil
+}
+
+func (r *Reader) lookup(addr netip.Addr) (*Entry, error) {
+	f, ok := r.familyOf(addr)
+	if !ok {
+		return nil, nil
+	}
+	record, prefix, err := r.descend(f, netip.PrefixFrom(addr, addr.BitLen()))
+	if err != nil || record <= r.nodeCount {
+		return nil, err
+	}
+	return r.entry(record, prefix)
+}
+
+// walk calls fn for every leaf holding a record below record, found at
+// prefix, in address order or backward, until fn returns false.  Subtrees
+// for which skip returns true are left out.  It returns whether the walk
+// went through.
+func (r *Reader) walk(f family, record uint, prefix netip.Prefix, backward bool, skip func(netip.Prefix) bool, fn func(uint, netip.Prefix) (bool, error)) (bool, error) {
+	if record == r.nodeCount || (skip != nil && skip(prefix)) {
+		return true, nil
+	}
+	if record > r.nodeCount {
+		return fn(record, prefix)
+	}
+	if prefix.Bits() == f.bits {
+		return false, ErrInvalidDatabase
+	}
+	for i := byte(0); i < 2; i++ {
+		bit := i
+		if backward {
+			bit = 1 - i
+		}
+		child := r.child(f, record, prefix, bit)
+		ok, err := r.walk(f, child, childPrefix(prefix, bit), backward, skip, fn)
+		if !ok || err != nil {
+			return ok, err
+		}
+	}
+	return true, nil
+}
+
+// resolve decodes the record pointed to by a data record.
+func (r *Reader) resolve(record uint) (any, error) {
+	if record < r.nodeCount+dataSectionSeparator {
+		return nil, ErrInvalidDatabase
+	}
+	value, _, err := r.data.decode(record - r.nodeCount - dataSectionSeparator)
+	return value, err
+}
+
+func (r *Reader) entry(record uint, prefix netip.Prefix) (*Entry, error) {
+	value, err := r.resolve(record)
+	if err != nil {
+		return nil, err
+	}
+	return &Entry{ipNet: ipNetOf(prefix), Record: value}, nil
+}
+
+// bitAt returns the bit of addr at given position, counting from the most
+// significant bit.
+func bitAt(addr []byte, position int) byte {
+	return addr[position/8] >> (7 - position%8) & 1
+}
+
+// childPrefix returns the prefix one bit longer than prefix, with given bit
+// appended.
+func childPrefix(prefix netip.Prefix, bit byte) netip.Prefix {
+	addr := prefix.Addr().As16()
+	position := prefix.Bits()
+	if prefix.Addr().Is4() {
+		position += 96
+	}
+	addr[position/8] |= bit << (7 - position%8)
+	child := netip.AddrFrom16(addr)
+	if prefix.Addr().Is4() {
+		child = child.Unmap()
+	}
+	return netip.PrefixFrom(child, prefix.Bits()+1)
+}
+
+func ipNetOf(prefix netip.Prefix) net.IPNet {
+	return net.IPNet{
+		IP:   net.IP(prefix.Addr().AsSlice()),
+		Mask: net.CIDRMask(prefix.Bits(), prefix.Addr().BitLen()),
+	}
+}
+
+func parseMetadata(buf []byte) (Metadata, error) {
+	value, _, err := (&decoder{buf: buf}).decode(0)
+	if err != nil {
+		return Metadata{}, err
+	}
+	fields, ok := value.(map[string]any)
+	if !ok {
+		return Metadata{}, ErrInvalidDatabase
+	}
+	var metadata Metadata
+	for name, field := range map[string]*uint{
+		"node_count":                  &metadata.NodeCount,
+		"record_size":                 &metadata.RecordSize,
+		"ip_version":                  &metadata.IPVersion,
+		"binary_format_major_version": &metadata.BinaryFormatMajorVersion,
+		"binary_format_minor_version": &metadata.BinaryFormatMinorVersion,
+	} {
+		number, ok := unsignedOf(fields[name])
+		if !ok {
+			return Metadata{}, ErrInvalidDatabase
+		}
+		*field = uint(number)
+	}
+	if metadata.BuildEpoch, ok = unsignedOf(fields["build_epoch"]); !ok {
+		return Metadata{}, ErrInvalidDatabase
+	}
+	if metadata.DatabaseType, ok = fields["database_type"].(string); !ok {
+		return Metadata{}, ErrInvalidDatabase
+	}
+	if languages, ok := fields["languages"].([]any); ok {
+		for _, language := range languages {
+			if language, ok := language.(string); ok {
+				metadata.Languages = append(metadata.Languages, language)
+			}
+		}
+	}
+	if description, ok := fields["description"].(map[string]any); ok {
+		metadata.Description = make(map[string]string, len(description))
+		for language, text := range description {
+			if text, ok := text.(string); ok {
+				metadata.Description[language] = text
+			}
+		}
+	}
+	return metadata, nil
+}
+
+func unsignedOf(value any) (uint64, bool) {
+	switch value := value.(type) {
+	case uint16:
+		return uint64(value), true
+	case uint32:
+		return uint64(value), true
+	case uint64:
+		return value, true
+	}
+	return 0, false
+}
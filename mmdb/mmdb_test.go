@@ -0,0 +1,278 @@
+package mmdb
+
+import (
+	"math/big"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yl2chen/cidranger"
+)
+
+func cityRecord(name string) map[string]any {
+	return map[string]any{
+		"city":     map[string]any{"names": map[string]any{"en": name}},
+		"location": map[string]any{"latitude": 1.5, "longitude": -2.25},
+	}
+}
+
+func testFixture(ipVersion, recordSize int) *fixture {
+	f := newFixture(ipVersion, recordSize).
+		insert("1.0.0.0/24", cityRecord("Alpha")).
+		insert("1.0.1.0/24", cityRecord("Beta")).
+		insert("8.8.8.0/24", cityRecord("Gamma")).
+		insert("128.0.0.0/1", cityRecord("Delta"))
+	if ipVersion == 6 {
+		f.insert("2001:db8::/32", cityRecord("Epsilon")).
+			insert("2001:db9::/48", "Zeta").
+			alias("::ffff:0:0/96").
+			alias("2002::/16")
+	}
+	return f
+}
+
+func networkStrings(entries []cidranger.RangerEntry) []string {
+	var networks []string
+	for _, entry := range entries {
+		network := entry.Network()
+		networks = append(networks, network.String())
+	}
+	return networks
+}
+
+func cityOf(entry cidranger.RangerEntry) string {
+	record, _ := entry.(*Entry).Record.(map[string]any)
+	city, _ := record["city"].(map[string]any)
+	names, _ := city["names"].(map[string]any)
+	name, _ := names["en"].(string)
+	return name
+}
+
+func TestDecode(t *testing.T) {
+	values := []any{
+		"",
+		"short",
+		strings.Repeat("a", 100),
+		strings.Repeat("b", 300),
+		strings.Repeat("c", 70000),
+		[]byte{1, 2, 3},
+		float64(3.5),
+		float32(-1.25),
+		true,
+		false,
+		uint16(0),
+		uint16(65535),
+		uint32(1 << 31),
+		int32(-5),
+		int32(7),
+		uint64(1 << 60),
+		new(big.Int).Lsh(big.NewInt(1), 100),
+		[]any{"x", uint16(1), []any{}},
+		map[string]any{"nested": map[string]any{"key": "key", "list": []any{false}}},
+	}
+	for _, value := range values {
+		encoder := newEncoder()
+		assert.NoError(t, encoder.encode(value))
+		decoded, next, err := (&decoder{buf: encoder.buf}).decode(0)
+		assert.NoError(t, err)
+		assert.Equal(t, value, decoded)
+		assert.Equal(t, uint(len(encoder.buf)), next)
+	}
+}
+
+func TestDecodePointer(t *testing.T) {
+	cases := []struct {
+		encoded  []byte
+		expected uint
+	}{
+		{[]byte{0x20, 0x00}, 0},
+		{[]byte{0x27, 0xff}, 2047},
+		{[]byte{0x28, 0x00, 0x00}, 2048},
+		{[]byte{0x2f, 0xff, 0xff}, 526335},
+		{[]byte{0x30, 0x00, 0x00, 0x00}, 526336},
+		{[]byte{0x37, 0xff, 0xff, 0xff}, 134744063},
+		{[]byte{0x38, 0x12, 0x34, 0x56, 0x78}, 0x12345678},
+	}
+	for _, tc := range cases {
+		d := &decoder{buf: tc.encoded}
+		kind, size, offset, err := d.control(0)
+		assert.NoError(t, err)
+		assert.Equal(t, byte(typePointer), kind)
+		pointer, next, err := d.pointer(size, offset)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.expected, pointer)
+		assert.Equal(t, uint(len(tc.encoded)), next)
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	for _, encoded := range [][]byte{
+		{},
+		{0x45},                   // truncated string
+		{0x5d},                   // truncated size
+		{0x03, 0x03, 0x01},       // uint64 over 8 bytes, truncated
+		{0x0d, 0x02},             // data cache container
+		{0x00, 0x09},             // invalid extended type
+		{0x61, 0x20, 0x00},       // pointer as map key to the map itself
+		{0x20, 0x00},             // pointer to itself
+		{0x68, 0x00, 0x00, 0x00}, // double of wrong size
+	} {
+		_, _, err := (&decoder{buf: encoded}).decode(0)
+		assert.Equal(t, ErrInvalidDatabase, err, "%x", encoded)
+	}
+}
+
+func TestReaderLookup(t *testing.T) {
+	for _, ipVersion := range []int{4, 6} {
+		for _, recordSize := range []int{24, 28, 32} {
+			reader, err := FromBytes(testFixture(ipVersion, recordSize).bytes())
+			if !assert.NoError(t, err) {
+				continue
+			}
+			assert.Equal(t, uint(ipVersion), reader.Metadata.IPVersion)
+			assert.Equal(t, uint(recordSize), reader.Metadata.RecordSize)
+			assert.Equal(t, "Test", reader.Metadata.DatabaseType)
+			assert.Equal(t, []string{"en"}, reader.Metadata.Languages)
+			assert.Equal(t, map[string]string{"en": "Test database"}, reader.Metadata.Description)
+			assert.Equal(t, uint64(1700000000), reader.Metadata.BuildEpoch)
+
+			cases := []struct {
+				ip      string
+				network string
+				city    string
+			}{
+				{"1.0.0.1", "1.0.0.0/24", "Alpha"},
+				{"1.0.1.255", "1.0.1.0/24", "Beta"},
+				{"::ffff:8.8.8.8", "8.8.8.0/24", "Gamma"},
+				{"200.1.2.3", "128.0.0.0/1", "Delta"},
+				{"1.0.2.0", "", ""},
+				{"2001:db8::1", "2001:db8::/32", "Epsilon"},
+				{"2001:db9::1", "2001:db9::/48", ""},
+				{"2001:dba::1", "", ""},
+			}
+			for _, tc := range cases {
+				entry, err := reader.Lookup(net.ParseIP(tc.ip))
+				assert.NoError(t, err)
+				if tc.network == "" || ipVersion == 4 && strings.Contains(tc.network, ":") {
+					assert.Nil(t, entry, tc.ip)
+					continue
+				}
+				if assert.NotNil(t, entry, tc.ip) {
+					network := entry.Network()
+					assert.Equal(t, tc.network, network.String())
+					if tc.city != "" {
+						assert.Equal(t, tc.city, cityOf(entry))
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestReaderImport(t *testing.T) {
+	reader, err := FromBytes(testFixture(6, 28).bytes())
+	assert.NoError(t, err)
+	ranger := cidranger.NewPCTrieRanger()
+	assert.NoError(t, reader.Import(ranger))
+
+	// Aliases of the IPv4 space are not imported again as IPv6 networks.
+	var entries []cidranger.RangerEntry
+	ranger.Walk(func(entry cidranger.RangerEntry) bool {
+		entries = append(entries, entry)
+		return true
+	})
+	assert.Equal(t, []string{
+		"1.0.0.0/24", "1.0.1.0/24", "8.8.8.0/24", "128.0.0.0/1",
+		"2001:db8::/32", "2001:db9::/48",
+	}, networkStrings(entries))
+	assert.Equal(t, "Gamma", cityOf(entries[2]))
+	assert.Equal(t, "Zeta", entries[5].(*Entry).Record)
+}
+
+func TestReaderImportInvalidRecord(t *testing.T) {
+	db := testFixture(4, 24).bytes()
+	// Corrupt the first record of the data section into an unknown type.
+	reader, err := FromBytes(db)
+	assert.NoError(t, err)
+	treeSize := reader.nodeCount * 6
+	db[treeSize+dataSectionSeparator] = 0x0d
+
+	reader, err = FromBytes(db)
+	assert.NoError(t, err)
+	ranger := cidranger.NewPCTrieRanger()
+	assert.Equal(t, ErrInvalidDatabase, reader.Import(ranger))
+	assert.Equal(t, 0, ranger.Len())
+}
+
+func TestOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.mmdb")
+	assert.NoError(t, os.WriteFile(path, testFixture(6, 32).bytes(), 0o644))
+	reader, err := Open(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	entry, found, err := reader.Ranger().LongestMatch(net.ParseIP("8.8.8.8"))
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "Gamma", cityOf(entry))
+	assert.NoError(t, reader.Close())
+	assert.NoError(t, reader.Close())
+
+	_, err = Open(filepath.Join(t.TempDir(), "missing.mmdb"))
+	assert.Error(t, err)
+}
+
+func TestFromBytesInvalid(t *testing.T) {
+	db := testFixture(6, 24).bytes()
+	_, err := FromBytes(nil)
+	assert.Equal(t, ErrInvalidDatabase, err)
+	_, err = FromBytes(db[:20])
+	assert.Equal(t, ErrInvalidDatabase, err)
+
+	unsupported := newFixture(6, 24)
+	unsupported.recordSize = 20
+	_, err = FromBytes(unsupported.bytes())
+	assert.Equal(t, ErrUnsupportedDatabase, err)
+
+	// Corrupting any byte must never panic, only fail or misreport.
+	for i := range db {
+		corrupted := append([]byte{}, db...)
+		corrupted[i] ^= 0xff
+		reader, err := FromBytes(corrupted)
+		if err != nil {
+			continue
+		}
+		ranger := reader.Ranger()
+		reader.Import(cidranger.NewPCTrieRanger())
+		ranger.Len()
+		ranger.Walk(func(cidranger.RangerEntry) bool { return true })
+		ranger.CoveredNetworks(net.IPNet{IP: net.IPv6zero, Mask: net.CIDRMask(0, 128)})
+		ranger.LongestMatch(net.ParseIP("2001:db8::1"))
+		ranger.Next(net.IPNet{IP: net.IPv4zero.To4(), Mask: net.CIDRMask(0, 32)})
+	}
+}
+
+func TestRangerReadOnly(t *testing.T) {
+	reader, err := FromBytes(testFixture(6, 24).bytes())
+	assert.NoError(t, err)
+	ranger := reader.Ranger()
+	network := net.IPNet{IP: net.ParseIP("1.0.0.0").To4(), Mask: net.CIDRMask(24, 32)}
+
+	assert.Equal(t, cidranger.ErrReadOnlyRanger, ranger.Insert(cidranger.NewBasicRangerEntry(network)))
+	assert.Equal(t, cidranger.ErrReadOnlyRanger, ranger.InsertPrefix(netip.MustParsePrefix("9.0.0.0/8")))
+	_, err = ranger.Remove(network)
+	assert.Equal(t, cidranger.ErrReadOnlyRanger, err)
+	_, err = ranger.RemoveCovered(network)
+	assert.Equal(t, cidranger.ErrReadOnlyRanger, err)
+	// Clear can not report cidranger.ErrReadOnlyRanger and leaves networks in
+	// place.
+	ranger.Clear()
+	assert.Equal(t, 6, ranger.Len())
+	_, found, err := ranger.Get(network)
+	assert.NoError(t, err)
+	assert.True(t, found)
+}
@@ -0,0 +1,401 @@
+package mmdb
+
+import (
+	"iter"
+	"net"
+	"net/netip"
+	"slices"
+	"sync"
+
+	"github.com/yl2chen/cidranger"
+)
+
+// treeRanger is a read-only cidranger.Ranger over the search tree of a
+// database.  Networks of a database do not overlap, so every query is
+// answered from a single descent or a pruned walk of the tree.
+type treeRanger struct {
+	reader *Reader
+
+	sizeOnce sync.Once
+	size     int
+}
+
+func (t *treeRanger) Insert(cidranger.RangerEntry) error {
+	return cidranger.ErrReadOnlyRanger
+}
+
+func (t *treeRanger) Upsert(net.IPNet, func(cidranger.RangerEntry, bool) cidranger.RangerEntry) error {
+	return cidranger.ErrReadOnlyRanger
+}
+
+func (t *treeRanger) Swap(cidranger.RangerEntry) (cidranger.RangerEntry, bool, error) {
+	return nil, false, cidranger.ErrReadOnlyRanger
+}
+
+func (t *treeRanger) InsertPrefix(netip.Prefix) error {
+	return cidranger.ErrReadOnlyRanger
+}
+
+func (t *treeRanger) Remove(net.IPNet) (cidranger.RangerEntry, error) {
+	return nil, cidranger.ErrReadOnlyRanger
+}
+
+func (t *treeRanger) RemovePrefix(netip.Prefix) (cidranger.RangerEntry, error) {
+	return nil, cidranger.ErrReadOnlyRanger
+}
+
+func (t *treeRanger) CompareAndRemove(net.IPNet, cidranger.RangerEntry) (bool, error) {
+	return false, cidranger.ErrReadOnlyRanger
+}
+
+func (t *treeRanger) RemoveCovered(net.IPNet) ([]cidranger.RangerEntry, error) {
+	return nil, cidranger.ErrReadOnlyRanger
+}
+
+// Clear has no effect, as it can not report cidranger.ErrReadOnlyRanger.
+func (t *treeRanger) Clear() {}
+
+func (t *treeRanger) Get(network net.IPNet) (cidranger.RangerEntry, bool, error) {
+	prefix, err := prefixOf(network)
+	if err != nil {
+		return nil, false, err
+	}
+	return t.get(prefix)
+}
+
+func (t *treeRanger) GetPrefix(prefix netip.Prefix) (cidranger.RangerEntry, bool, error) {
+	prefix, err := normalize(prefix)
+	if err != nil {
+		return nil, false, err
+	}
+	return t.get(prefix)
+}
+
+func (t *treeRanger) Contains(ip net.IP) (bool, error) {
+	_, found, err := t.LongestMatch(ip)
+	return found, err
+}
+
+func (t *treeRanger) ContainsAddr(addr netip.Addr) (bool, error) {
+	_, found, err := t.LongestMatchAddr(addr)
+	return found, err
+}
+
+func (t *treeRanger) ContainingNetworks(ip net.IP) ([]cidranger.RangerEntry, error) {
+	entry, found, err := t.LongestMatch(ip)
+	if !found || err != nil {
+		return nil, err
+	}
+	return []cidranger.RangerEntry{entry}, nil
+}
+
+func (t *treeRanger) ContainingPrefixes(addr netip.Addr) ([]cidranger.RangerEntry, error) {
+	entry, found, err := t.LongestMatchAddr(addr)
+	if !found || err != nil {
+		return nil, err
+	}
+	return []cidranger.RangerEntry{entry}, nil
+}
+
+func (t *treeRanger) LongestMatch(ip net.IP) (cidranger.RangerEntry, bool, error) {
+	addr, _ := netip.AddrFromSlice(ip)
+	return t.LongestMatchAddr(addr)
+}
+
+func (t *treeRanger) LongestMatchAddr(addr netip.Addr) (cidranger.RangerEntry, bool, error) {
+	if !addr.IsValid() {
+		return nil, false, cidranger.ErrInvalidNetworkNumberInput
+	}
+	entry, err := t.reader.lookup(addr.Unmap())
+	if entry == nil || err != nil {
+		return nil, false, err
+	}
+	return entry, true, nil
+}
+
+// ShortestMatch is LongestMatch, as networks of a database do not overlap.
+func (t *treeRanger) ShortestMatch(ip net.IP) (cidranger.RangerEntry, bool, error) {
+	return t.LongestMatch(ip)
+}
+
+func (t *treeRanger) ShortestMatchAddr(addr netip.Addr) (cidranger.RangerEntry, bool, error) {
+	return t.LongestMatchAddr(addr)
+}
+
+func (t *treeRanger) CoveredNetworks(network net.IPNet) ([]cidranger.RangerEntry, error) {
+	prefix, err := prefixOf(network)
+	if err != nil {
+		return nil, err
+	}
+	return t.overlapping(prefix, false, true)
+}
+
+func (t *treeRanger) CoveredPrefixes(prefix netip.Prefix) ([]cidranger.RangerEntry, error) {
+	prefix, err := normalize(prefix)
+	if err != nil {
+		return nil, err
+	}
+	return t.overlapping(prefix, false, true)
+}
+
+func (t *treeRanger) CoveringNetworks(network net.IPNet) ([]cidranger.RangerEntry, error) {
+	prefix, err := prefixOf(network)
+	if err != nil {
+		return nil, err
+	}
+	return t.overlapping(prefix, true, false)
+}
+
+func (t *treeRanger) CoveringPrefixes(prefix netip.Prefix) ([]cidranger.RangerEntry, error) {
+	prefix, err := normalize(prefix)
+	if err != nil {
+		return nil, err
+	}
+	return t.overlapping(prefix, true, false)
+}
+
+func (t *treeRanger) OverlappingNetworks(network net.IPNet) ([]cidranger.RangerEntry, error) {
+	prefix, err := prefixOf(network)
+	if err != nil {
+		return nil, err
+	}
+	return t.overlapping(prefix, true, true)
+}
+
+func (t *treeRanger) OverlappingPrefixes(prefix netip.Prefix) ([]cidranger.RangerEntry, error) {
+	prefix, err := normalize(prefix)
+	if err != nil {
+		return nil, err
+	}
+	return t.overlapping(prefix, true, true)
+}
+
+// Walk calls fn for every entry in address order until fn returns false, or
+// a record fails to decode.
+func (t *treeRanger) Walk(fn func(cidranger.RangerEntry) bool) {
+	t.walk(false, fn)
+}
+
+func (t *treeRanger) All() iter.Seq[cidranger.RangerEntry] {
+	return t.Walk
+}
+
+func (t *treeRanger) Backward() iter.Seq[cidranger.RangerEntry] {
+	return func(yield func(cidranger.RangerEntry) bool) {
+		t.walk(true, yield)
+	}
+}
+
+// Next returns the first entry after given network in the order of Walk,
+// continuing onto IPv6 entries after the last IPv4 entry.
+func (t *treeRanger) Next(network net.IPNet) (cidranger.RangerEntry, bool, error) {
+	prefix, err := prefixOf(network)
+	if err != nil {
+		return nil, false, err
+	}
+	f, ok := t.reader.familyOf(prefix.Addr())
+	if !ok {
+		return nil, false, nil
+	}
+	entry, err := t.first(f, false, func(leaf netip.Prefix) bool {
+		return lastAddr(leaf).Less(prefix.Addr())
+	}, func(leaf netip.Prefix) bool {
+		return comparePrefixes(leaf, prefix) > 0
+	})
+	if entry != nil || err != nil || f.bits == 128 || t.reader.Metadata.IPVersion == 4 {
+		return entry, entry != nil, err
+	}
+	entry, err = t.first(t.reader.families()[1], false, nil, nil)
+	return entry, entry != nil, err
+}
+
+// Prev returns the last entry before given network in the order of Walk,
+// continuing onto IPv4 entries before the first IPv6 entry.
+func (t *treeRanger) Prev(network net.IPNet) (cidranger.RangerEntry, bool, error) {
+	prefix, err := prefixOf(network)
+	if err != nil {
+		return nil, false, err
+	}
+	f, ok := t.reader.familyOf(prefix.Addr())
+	if !ok {
+		return nil, false, nil
+	}
+	entry, err := t.first(f, true, func(leaf netip.Prefix) bool {
+		return prefix.Addr().Less(leaf.Addr())
+	}, func(leaf netip.Prefix) bool {
+		return comparePrefixes(leaf, prefix) < 0
+	})
+	if entry != nil || err != nil || f.bits == 32 {
+		return entry, entry != nil, err
+	}
+	entry, err = t.first(t.reader.families()[0], true, nil, nil)
+	return entry, entry != nil, err
+}
+
+func (t *treeRanger) EntriesBetween(start, end net.IP) ([]cidranger.RangerEntry, error) {
+	startAddr, startOk := netip.AddrFromSlice(start)
+	endAddr, endOk := netip.AddrFromSlice(end)
+	startAddr, endAddr = startAddr.Unmap(), endAddr.Unmap()
+	if !startOk || !endOk || startAddr.BitLen() != endAddr.BitLen() {
+		return nil, cidranger.ErrInvalidNetworkNumberInput
+	}
+	f, ok := t.reader.familyOf(startAddr)
+	if !ok {
+		return nil, nil
+	}
+	var results []cidranger.RangerEntry
+	_, err := t.reader.walk(f, f.root, f.zero(), false, func(subtree netip.Prefix) bool {
+		return lastAddr(subtree).Less(startAddr) || endAddr.Less(subtree.Addr())
+	}, t.collect(&results))
+	return results, err
+}
+
+// Len returns the number of networks in the database, counted on first
+// call.
+func (t *treeRanger) Len() int {
+	t.sizeOnce.Do(func() {
+		for _, f := range t.reader.families() {
+			t.reader.walk(f, f.root, f.zero(), false, nil, func(uint, netip.Prefix) (bool, error) {
+				t.size++
+				return true, nil
+			})
+		}
+	})
+	return t.size
+}
+
+func (t *treeRanger) get(prefix netip.Prefix) (cidranger.RangerEntry, bool, error) {
+	f, ok := t.reader.familyOf(prefix.Addr())
+	if !ok {
+		return nil, false, nil
+	}
+	record, leaf, err := t.reader.descend(f, prefix)
+	if err != nil || record <= t.reader.nodeCount || leaf != prefix {
+		return nil, false, err
+	}
+	entry, err := t.reader.entry(record, leaf)
+	return entry, err == nil, err
+}
+
+// overlapping returns the entry covering prefix if covering is set, and the
+// entries covered by prefix if covered is set.
+func (t *treeRanger) overlapping(prefix netip.Prefix, covering, covered bool) ([]cidranger.RangerEntry, error) {
+	f, ok := t.reader.familyOf(prefix.Addr())
+	if !ok {
+		return nil, nil
+	}
+	record, leaf, err := t.reader.descend(f, prefix)
+	if err != nil {
+		return nil, err
+	}
+	if record > t.reader.nodeCount {
+		// The leaf is prefix itself, both covering and covered, or covers it.
+		if leaf != prefix && !covering {
+			return nil, nil
+		}
+		entry, err := t.reader.entry(record, leaf)
+		if err != nil {
+			return nil, err
+		}
+		return []cidranger.RangerEntry{entry}, nil
+	}
+	if record == t.reader.nodeCount || !covered {
+		return nil, nil
+	}
+	var results []cidranger.RangerEntry
+	_, err = t.reader.walk(f, record, leaf, false, nil, t.collect(&results))
+	return results, err
+}
+
+// first returns the first entry of f, walked forward or backward, which
+// matches, leaving out the subtrees skip returns true for.
+func (t *treeRanger) first(f family, backward bool, skip, matches func(netip.Prefix) bool) (cidranger.RangerEntry, error) {
+	var result cidranger.RangerEntry
+	_, err := t.reader.walk(f, f.root, f.zero(), backward, skip, func(record uint, prefix netip.Prefix) (bool, error) {
+		if matches != nil && !matches(prefix) {
+			return true, nil
+		}
+		entry, err := t.reader.entry(record, prefix)
+		if err != nil {
+			return false, err
+		}
+		result = entry
+		return false, nil
+	})
+	return result, err
+}
+
+func (t *treeRanger) walk(backward bool, fn func(cidranger.RangerEntry) bool) {
+	families := t.reader.families()
+	if backward {
+		slices.Reverse(families)
+	}
+	for _, f := range families {
+		ok, err := t.reader.walk(f, f.root, f.zero(), backward, nil, func(record uint, prefix netip.Prefix) (bool, error) {
+			entry, err := t.reader.entry(record, prefix)
+			if err != nil {
+				return false, err
+			}
+			return fn(entry), nil
+		})
+		if !ok || err != nil {
+			return
+		}
+	}
+}
+
+func (t *treeRanger) collect(results *[]cidranger.RangerEntry) func(uint, netip.Prefix) (bool, error) {
+	return func(record uint, prefix netip.Prefix) (bool, error) {
+		entry, err := t.reader.entry(record, prefix)
+		if err != nil {
+			return false, err
+		}
+		*results = append(*results, entry)
+		return true, nil
+	}
+}
+
+// prefixOf returns network as a normalized netip.Prefix.
+func prefixOf(network net.IPNet) (netip.Prefix, error) {
+	addr, ok := netip.AddrFromSlice(network.IP)
+	ones, bits := network.Mask.Size()
+	if !ok || bits == 0 {
+		return netip.Prefix{}, cidranger.ErrInvalidNetworkInput
+	}
+	if bits == 32 {
+		addr = addr.Unmap()
+	}
+	return normalize(netip.PrefixFrom(addr, ones))
+}
+
+// normalize masks prefix, unmapping IPv4-mapped IPv6 prefixes of 96 bits
+// or longer to the equivalent IPv4 prefix.
+func normalize(prefix netip.Prefix) (netip.Prefix, error) {
+	if !prefix.IsValid() {
+		return netip.Prefix{}, cidranger.ErrInvalidNetworkInput
+	}
+	addr, bits := prefix.Addr(), prefix.Bits()
+	if addr.Is4In6() && bits >= 96 {
+		addr, bits = addr.Unmap(), bits-96
+	}
+	return netip.PrefixFrom(addr, bits).Masked(), nil
+}
+
+// lastAddr returns the last address of prefix.
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	b := prefix.Addr().AsSlice()
+	for i := prefix.Bits(); i < len(b)*8; i++ {
+		b[i/8] |= 1 << (7 - i%8)
+	}
+	addr, _ := netip.AddrFromSlice(b)
+	return addr
+}
+
+// comparePrefixes orders prefixes of the same IP version the way Walk does,
+// by address and then by length.
+func comparePrefixes(a, b netip.Prefix) int {
+	if c := a.Addr().Compare(b.Addr()); c != 0 {
+		return c
+	}
+	return a.Bits() - b.Bits()
+}
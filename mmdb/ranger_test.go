@@ -0,0 +1,157 @@
+package mmdb
+
+import (
+	"math/rand"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yl2chen/cidranger"
+)
+
+// randomNetwork returns a random IPv4 or IPv6 network.
+func randomNetwork(rng *rand.Rand) net.IPNet {
+	if rng.Intn(2) == 0 {
+		ip := make(net.IP, net.IPv4len)
+		rng.Read(ip)
+		ones := 1 + rng.Intn(24)
+		return net.IPNet{IP: ip.Mask(net.CIDRMask(ones, 32)), Mask: net.CIDRMask(ones, 32)}
+	}
+	ip := make(net.IP, net.IPv6len)
+	rng.Read(ip)
+	ip[0] = 0x20
+	ones := 8 + rng.Intn(56)
+	return net.IPNet{IP: ip.Mask(net.CIDRMask(ones, 128)), Mask: net.CIDRMask(ones, 128)}
+}
+
+func TestRangerAgainstPCTrie(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, recordSize := range []int{24, 28, 32} {
+		f := newFixture(6, recordSize).alias("::ffff:0:0/96")
+		expected := cidranger.NewPCTrieRanger()
+		for expected.Len() < 300 {
+			network := randomNetwork(rng)
+			if overlapping, _ := expected.OverlappingNetworks(network); len(overlapping) > 0 {
+				continue
+			}
+			expected.Insert(cidranger.NewBasicRangerEntry(network))
+			f.insert(network.String(), network.String())
+		}
+		reader, err := FromBytes(f.bytes())
+		if !assert.NoError(t, err) {
+			continue
+		}
+		ranger := reader.Ranger()
+		assert.Equal(t, expected.Len(), ranger.Len())
+
+		var walked, expectedWalked []cidranger.RangerEntry
+		for entry := range ranger.All() {
+			walked = append(walked, entry)
+			network := entry.Network()
+			assert.Equal(t, network.String(), entry.(*Entry).Record)
+		}
+		for entry := range expected.All() {
+			expectedWalked = append(expectedWalked, entry)
+		}
+		assert.Equal(t, networkStrings(expectedWalked), networkStrings(walked))
+		walked, expectedWalked = nil, nil
+		for entry := range ranger.Backward() {
+			walked = append(walked, entry)
+		}
+		for entry := range expected.Backward() {
+			expectedWalked = append(expectedWalked, entry)
+		}
+		assert.Equal(t, networkStrings(expectedWalked), networkStrings(walked))
+
+		queries := make([]net.IPNet, 0, 600)
+		for entry := range expected.All() {
+			queries = append(queries, entry.Network())
+		}
+		for i := 0; i < 300; i++ {
+			queries = append(queries, randomNetwork(rng))
+		}
+		for _, query := range queries {
+			ip := make(net.IP, len(query.IP))
+			rng.Read(ip)
+			for i := range ip {
+				ip[i] = query.IP[i] | ip[i]&^query.Mask[i]
+			}
+
+			entries, err := ranger.ContainingNetworks(ip)
+			assert.NoError(t, err)
+			expectedEntries, _ := expected.ContainingNetworks(ip)
+			assert.Equal(t, networkStrings(expectedEntries), networkStrings(entries))
+
+			entry, found, err := ranger.Get(query)
+			assert.NoError(t, err)
+			expectedEntry, expectedFound, _ := expected.Get(query)
+			assert.Equal(t, expectedFound, found)
+			if found {
+				assert.Equal(t, expectedEntry.Network(), entry.Network())
+			}
+
+			for _, method := range []struct {
+				actual, expected func(net.IPNet) ([]cidranger.RangerEntry, error)
+			}{
+				{ranger.CoveredNetworks, expected.CoveredNetworks},
+				{ranger.CoveringNetworks, expected.CoveringNetworks},
+				{ranger.OverlappingNetworks, expected.OverlappingNetworks},
+			} {
+				entries, err := method.actual(query)
+				assert.NoError(t, err)
+				expectedEntries, _ := method.expected(query)
+				assert.Equal(t, networkStrings(expectedEntries), networkStrings(entries), query.String())
+			}
+
+			for _, method := range []struct {
+				actual, expected func(net.IPNet) (cidranger.RangerEntry, bool, error)
+			}{
+				{ranger.Next, expected.Next},
+				{ranger.Prev, expected.Prev},
+			} {
+				entry, found, err := method.actual(query)
+				assert.NoError(t, err)
+				expectedEntry, expectedFound, _ := method.expected(query)
+				assert.Equal(t, expectedFound, found, query.String())
+				if found && expectedFound {
+					assert.Equal(t, expectedEntry.Network(), entry.Network(), query.String())
+				}
+			}
+
+			end := make(net.IP, len(ip))
+			copy(end, ip)
+			end[len(end)/2] ^= 0xff
+			start := ip
+			if string(end) < string(start) {
+				start, end = end, start
+			}
+			entries, err = ranger.EntriesBetween(start, end)
+			assert.NoError(t, err)
+			expectedEntries, _ = expected.EntriesBetween(start, end)
+			assert.Equal(t, networkStrings(expectedEntries), networkStrings(entries))
+		}
+	}
+}
+
+func TestRangerIPv4Database(t *testing.T) {
+	reader, err := FromBytes(testFixture(4, 24).bytes())
+	assert.NoError(t, err)
+	ranger := reader.Ranger()
+
+	found, err := ranger.Contains(net.ParseIP("2001:db8::1"))
+	assert.NoError(t, err)
+	assert.False(t, found)
+	entry, found, err := ranger.Next(net.IPNet{IP: net.ParseIP("128.0.0.0").To4(), Mask: net.CIDRMask(1, 32)})
+	assert.NoError(t, err)
+	assert.False(t, found)
+	assert.Nil(t, entry)
+	entry, found, err = ranger.Prev(net.IPNet{IP: net.ParseIP("1.0.1.0").To4(), Mask: net.CIDRMask(24, 32)})
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "Alpha", cityOf(entry))
+
+	_, err = ranger.Contains(nil)
+	assert.Equal(t, cidranger.ErrInvalidNetworkNumberInput, err)
+	_, _, err = ranger.Get(net.IPNet{IP: net.ParseIP("1.0.0.0").To4()})
+	assert.Equal(t, cidranger.ErrInvalidNetworkInput, err)
+}
@@ -0,0 +1,281 @@
+package mmdb
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/yl2chen/cidranger"
+)
+
+// ErrUnsupportedValue is returned when writing a record holding a value of
+// a type records do not decode to.
+var ErrUnsupportedValue = fmt.Errorf("Unsupported MaxMind record value")
+
+// ErrDatabaseTooLarge is returned when a database does not fit in the
+// record size asked for.
+var ErrDatabaseTooLarge = fmt.Errorf("Ranger too large for MaxMind record size")
+
+// Encoder returns the record written for entry, made of the types records
+// decode to.  Entries it returns a nil record for are left out.
+type Encoder func(entry cidranger.RangerEntry) (any, error)
+
+// EncodeRecord is the Encoder of the Entry(s) read from a database, writing
+// their decoded record back.  It returns cidranger.ErrUnsupportedEntry for
+// other entries.
+func EncodeRecord(entry cidranger.RangerEntry) (any, error) {
+	if entry, ok := entry.(*Entry); ok {
+		return entry.Record, nil
+	}
+	return nil, cidranger.ErrUnsupportedEntry
+}
+
+// Write writes every entry of ranger to w as a database, with the records
+// returned by encode.
+//
+// The RecordSize, IPVersion, DatabaseType, Languages, Description and
+// BuildEpoch of metadata are written out, the other fields are ignored.
+// A zero RecordSize picks the smallest record size fitting the database, a
+// zero IPVersion stands for 6 and a zero BuildEpoch for the current time.
+//
+// Networks of a database can not overlap, so networks covering others are
+// written as the pieces left uncovered, and read back as such.  IPv6
+// databases hold the IPv4 networks at ::/96, aliased at ::ffff:0:0/96,
+// which leaves out the IPv6 networks within those.  IPv4 databases leave
+// out every IPv6 network.
+func Write(w io.Writer, ranger cidranger.Ranger, metadata Metadata, encode Encoder) error {
+	builder, err := newTreeBuilder(metadata)
+	if err != nil {
+		return err
+	}
+	ranger.Walk(func(entry cidranger.RangerEntry) bool {
+		var record any
+		if record, err = encode(entry); err == nil {
+			err = builder.insert(entry.Network(), record)
+		}
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+	return builder.write(w)
+}
+
+// WriteTyped writes every entry of ranger to w as a database, with the
+// records returned by encode, the way Write does.
+func WriteTyped[V any](w io.Writer, ranger cidranger.TypedRanger[V], metadata Metadata, encode func(network net.IPNet, value V) (any, error)) error {
+	builder, err := newTreeBuilder(metadata)
+	if err != nil {
+		return err
+	}
+	ranger.Walk(func(entry cidranger.Entry[V]) bool {
+		var record any
+		if record, err = encode(entry.Network, entry.Value); err == nil {
+			err = builder.insert(entry.Network, record)
+		}
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+	return builder.write(w)
+}
+
+// treeBuilder builds the search tree of a database in memory, along with
+// its data section.
+type treeBuilder struct {
+	metadata   Metadata
+	ipv4, ipv6 *builderNode
+	data       *encoder
+}
+
+// builderNode is a node of the search tree, or a leaf holding the offset of
+// its record when leaf is set.  Nil children are empty leaves.
+type builderNode struct {
+	children [2]*builderNode
+	leaf     bool
+	offset   uint
+}
+
+func newTreeBuilder(metadata Metadata) (*treeBuilder, error) {
+	if metadata.IPVersion == 0 {
+		metadata.IPVersion = 6
+	}
+	if metadata.IPVersion != 4 && metadata.IPVersion != 6 {
+		return nil, ErrUnsupportedDatabase
+	}
+	switch metadata.RecordSize {
+	case 0, 24, 28, 32:
+	default:
+		return nil, ErrUnsupportedDatabase
+	}
+	if metadata.BuildEpoch == 0 {
+		metadata.BuildEpoch = uint64(time.Now().Unix())
+	}
+	return &treeBuilder{
+		metadata: metadata,
+		ipv4:     &builderNode{},
+		ipv6:     &builderNode{},
+		data:     newEncoder(),
+	}, nil
+}
+
+// insert stores record for network, over the part of any network inserted
+// before it covers.  Networks must be inserted in the order of Walk for more
+// specific networks to take precedence.
+func (b *treeBuilder) insert(network net.IPNet, record any) error {
+	if record == nil {
+		return nil
+	}
+	prefix, err := prefixOf(network)
+	if err != nil {
+		return err
+	}
+	if prefix.Addr().Is6() && b.metadata.IPVersion == 4 {
+		return nil
+	}
+	offset, err := b.data.record(record)
+	if err != nil {
+		return err
+	}
+	node := b.ipv4
+	if prefix.Addr().Is6() {
+		node = b.ipv6
+	}
+	addr := prefix.Addr().AsSlice()
+	leaf := &builderNode{leaf: true, offset: offset}
+	if prefix.Bits() == 0 {
+		node.children = [2]*builderNode{leaf, leaf}
+		return nil
+	}
+	for i := 0; i < prefix.Bits()-1; i++ {
+		node = node.child(bitAt(addr, i))
+	}
+	node.children[bitAt(addr, prefix.Bits()-1)] = leaf
+	return nil
+}
+
+// child returns the node at given bit of n, splitting leaves.
+func (n *builderNode) child(bit byte) *builderNode {
+	child := n.children[bit]
+	switch {
+	case child == nil:
+		child = &builderNode{}
+	case child.leaf:
+		child = &builderNode{children: [2]*builderNode{child, child}}
+	default:
+		return child
+	}
+	n.children[bit] = child
+	return child
+}
+
+// write writes the database to w.
+func (b *treeBuilder) write(w io.Writer) error {
+	metadata := b.metadata
+	root := b.ipv4
+	if metadata.IPVersion == 6 {
+		root = b.ipv6
+		node := root
+		for i := 0; i < 95; i++ {
+			node = node.child(0)
+		}
+		node.children[0] = b.ipv4
+		node = root
+		for i := 0; i < 95; i++ {
+			node = node.child(bitAt(ipv4Alias[:], i))
+		}
+		node.children[1] = b.ipv4
+	}
+
+	// Number nodes breadth first, the IPv4 node aliased once.
+	index := map[*builderNode]uint{}
+	var nodes []*builderNode
+	for queue := []*builderNode{root}; len(queue) > 0; queue = queue[1:] {
+		node := queue[0]
+		if _, ok := index[node]; ok || node.leaf {
+			continue
+		}
+		index[node] = uint(len(nodes))
+		nodes = append(nodes, node)
+		for _, child := range node.children {
+			if child != nil {
+				queue = append(queue, child)
+			}
+		}
+	}
+	nodeCount := uint(len(nodes))
+	largest := uint64(nodeCount) + dataSectionSeparator + uint64(len(b.data.buf))
+	if metadata.RecordSize == 0 {
+		metadata.RecordSize = 24
+		if largest >= 1<<24 {
+			metadata.RecordSize = 28
+		}
+		if largest >= 1<<28 {
+			metadata.RecordSize = 32
+		}
+	}
+	if largest >= 1<<metadata.RecordSize {
+		return ErrDatabaseTooLarge
+	}
+
+	recordOf := func(node *builderNode) uint {
+		switch {
+		case node == nil:
+			return nodeCount
+		case node.leaf:
+			return nodeCount + dataSectionSeparator + node.offset
+		}
+		return index[node]
+	}
+	tree := make([]byte, 0, nodeCount*metadata.RecordSize/4+dataSectionSeparator)
+	for _, node := range nodes {
+		left, right := recordOf(node.children[0]), recordOf(node.children[1])
+		switch metadata.RecordSize {
+		case 24:
+			tree = append(tree, byte(left>>16), byte(left>>8), byte(left),
+				byte(right>>16), byte(right>>8), byte(right))
+		case 28:
+			tree = append(tree, byte(left>>16), byte(left>>8), byte(left),
+				byte(left>>20&0xf0|right>>24&0x0f), byte(right>>16), byte(right>>8), byte(right))
+		case 32:
+			tree = append(tree, byte(left>>24), byte(left>>16), byte(left>>8), byte(left),
+				byte(right>>24), byte(right>>16), byte(right>>8), byte(right))
+		}
+	}
+	tree = append(tree, make([]byte, dataSectionSeparator)...)
+
+	languages := make([]any, 0, len(metadata.Languages))
+	for _, language := range metadata.Languages {
+		languages = append(languages, language)
+	}
+	description := make(map[string]any, len(metadata.Description))
+	for language, text := range metadata.Description {
+		description[language] = text
+	}
+	encodedMetadata := &encoder{}
+	err := encodedMetadata.encode(map[string]any{
+		"node_count":                  uint32(nodeCount),
+		"record_size":                 uint16(metadata.RecordSize),
+		"ip_version":                  uint16(metadata.IPVersion),
+		"database_type":               metadata.DatabaseType,
+		"languages":                   languages,
+		"description":                 description,
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 metadata.BuildEpoch,
+	})
+	if err != nil {
+		return err
+	}
+	for _, section := range [][]byte{tree, b.data.buf, metadataMarker, encodedMetadata.buf} {
+		if _, err := w.Write(section); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ipv4Alias is the network of IPv4-mapped IPv6 addresses, ::ffff:0:0/96.
+var ipv4Alias = [16]byte{10: 0xff, 11: 0xff}
@@ -0,0 +1,224 @@
+package mmdb
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yl2chen/cidranger"
+)
+
+type siteEntry struct {
+	ipNet net.IPNet
+	site  string
+}
+
+func (e *siteEntry) Network() net.IPNet {
+	return e.ipNet
+}
+
+func encodeSite(entry cidranger.RangerEntry) (any, error) {
+	if entry, ok := entry.(*siteEntry); ok {
+		return map[string]any{"site": entry.site, "team": "network"}, nil
+	}
+	return nil, nil
+}
+
+func siteOf(entry cidranger.RangerEntry) string {
+	if entry == nil {
+		return ""
+	}
+	record, _ := entry.(*Entry).Record.(map[string]any)
+	site, _ := record["site"].(string)
+	return site
+}
+
+func TestWriteRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	ranger := cidranger.NewPCTrieRanger()
+	ranger.Insert(&siteEntry{ipNet: net.IPNet{IP: net.IPv4zero.To4(), Mask: net.CIDRMask(0, 32)}, site: "default"})
+	for i := 0; i < 500; i++ {
+		network := randomNetwork(rng)
+		ranger.Insert(&siteEntry{ipNet: network, site: fmt.Sprintf("site-%d", i%40)})
+	}
+	// Left out, falling back onto the networks covering it.
+	ranger.Insert(cidranger.NewBasicRangerEntry(net.IPNet{IP: net.ParseIP("10.1.0.0").To4(), Mask: net.CIDRMask(16, 32)}))
+
+	for _, recordSize := range []uint{0, 24, 28, 32} {
+		var buf bytes.Buffer
+		metadata := Metadata{
+			RecordSize:   recordSize,
+			DatabaseType: "Sites",
+			Languages:    []string{"en"},
+			Description:  map[string]string{"en": "Sites of networks"},
+			BuildEpoch:   1700000000,
+		}
+		if !assert.NoError(t, Write(&buf, ranger, metadata, encodeSite)) {
+			continue
+		}
+		reader, err := FromBytes(buf.Bytes())
+		if !assert.NoError(t, err) {
+			continue
+		}
+		if recordSize == 0 {
+			recordSize = 24
+		}
+		assert.Equal(t, recordSize, reader.Metadata.RecordSize)
+		assert.Equal(t, uint(6), reader.Metadata.IPVersion)
+		assert.Equal(t, "Sites", reader.Metadata.DatabaseType)
+		assert.Equal(t, []string{"en"}, reader.Metadata.Languages)
+		assert.Equal(t, map[string]string{"en": "Sites of networks"}, reader.Metadata.Description)
+		assert.Equal(t, uint64(1700000000), reader.Metadata.BuildEpoch)
+
+		// The most specific network with a record decides.
+		for entry := range ranger.All() {
+			network := entry.Network()
+			for i := 0; i < 4; i++ {
+				ip := make(net.IP, len(network.IP))
+				rng.Read(ip)
+				for j := range ip {
+					ip[j] = network.IP[j] | ip[j]&^network.Mask[j]
+				}
+				containing, _ := ranger.ContainingNetworks(ip)
+				expected := ""
+				for _, entry := range containing {
+					if entry, ok := entry.(*siteEntry); ok {
+						expected = entry.site
+					}
+				}
+				actual, err := reader.Lookup(ip)
+				assert.NoError(t, err)
+				assert.Equal(t, expected, siteOf(actual), ip.String())
+			}
+		}
+		entry, err := reader.Lookup(net.ParseIP("::ffff:10.1.2.3"))
+		assert.NoError(t, err)
+		expected, _ := reader.Lookup(net.ParseIP("10.1.2.3"))
+		assert.Equal(t, expected, entry)
+		entry, err = reader.Lookup(net.ParseIP("::a01:203"))
+		assert.NoError(t, err)
+		assert.Nil(t, entry)
+	}
+}
+
+func TestWriteImported(t *testing.T) {
+	reader, err := FromBytes(testFixture(6, 28).bytes())
+	assert.NoError(t, err)
+	imported := cidranger.NewPCTrieRanger()
+	assert.NoError(t, reader.Import(imported))
+
+	var buf bytes.Buffer
+	assert.NoError(t, Write(&buf, imported, reader.Metadata, EncodeRecord))
+	written, err := FromBytes(buf.Bytes())
+	assert.NoError(t, err)
+	assert.Equal(t, reader.Metadata.BuildEpoch, written.Metadata.BuildEpoch)
+
+	var expected, actual []cidranger.RangerEntry
+	for entry := range imported.All() {
+		expected = append(expected, entry)
+	}
+	for entry := range written.Ranger().All() {
+		actual = append(actual, entry)
+	}
+	assert.Equal(t, networkStrings(expected), networkStrings(actual))
+	for i := range expected {
+		assert.Equal(t, expected[i].(*Entry).Record, actual[i].(*Entry).Record)
+	}
+	// Tools looking up IPv4-mapped addresses in the IPv6 tree find IPv4
+	// networks through the alias.
+	addr := netip.MustParseAddr("::ffff:8.8.8.8").AsSlice()
+	record := uint(0)
+	for i := 0; record < written.nodeCount; i++ {
+		record = written.record(record, bitAt(addr, i))
+	}
+	value, err := written.resolve(record)
+	assert.NoError(t, err)
+	assert.Equal(t, "Gamma", cityOf(&Entry{Record: value}))
+
+	assert.Equal(t, cidranger.ErrUnsupportedEntry, Write(&buf, ranger(t, "1.0.0.0/8"), Metadata{}, EncodeRecord))
+}
+
+func TestWriteIPv4Database(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, Write(&buf, ranger(t, "1.0.0.0/8", "2001:db8::/32", "1.2.0.0/16"), Metadata{IPVersion: 4}, encodeSite))
+	reader, err := FromBytes(buf.Bytes())
+	assert.NoError(t, err)
+	assert.Equal(t, uint(4), reader.Metadata.IPVersion)
+	assert.Equal(t, 9, reader.Ranger().Len())
+	entry, err := reader.Lookup(net.ParseIP("1.2.3.4"))
+	assert.NoError(t, err)
+	assert.Equal(t, "1.2.0.0/16", siteOf(entry))
+	entry, err = reader.Lookup(net.ParseIP("1.3.3.4"))
+	assert.NoError(t, err)
+	assert.Equal(t, "1.0.0.0/8", siteOf(entry))
+	network := entry.Network()
+	assert.Equal(t, "1.3.0.0/16", network.String())
+}
+
+func TestWriteTyped(t *testing.T) {
+	ranger := cidranger.NewTypedPCTrieRanger[string]()
+	ranger.InsertPrefix(netip.MustParsePrefix("192.0.2.0/24"), "docs")
+	ranger.InsertPrefix(netip.MustParsePrefix("2001:db8::/32"), "docs6")
+	var buf bytes.Buffer
+	err := WriteTyped(&buf, ranger, Metadata{RecordSize: 32}, func(network net.IPNet, value string) (any, error) {
+		return value, nil
+	})
+	assert.NoError(t, err)
+	reader, err := FromBytes(buf.Bytes())
+	assert.NoError(t, err)
+	entry, err := reader.Lookup(net.ParseIP("2001:db8::1"))
+	assert.NoError(t, err)
+	assert.Equal(t, "docs6", entry.Record)
+	entry, err = reader.Lookup(net.ParseIP("192.0.2.1"))
+	assert.NoError(t, err)
+	assert.Equal(t, "docs", entry.Record)
+}
+
+func TestWriteInvalid(t *testing.T) {
+	var buf bytes.Buffer
+	networks := ranger(t, "1.0.0.0/8")
+	assert.Equal(t, ErrUnsupportedDatabase, Write(&buf, networks, Metadata{RecordSize: 20}, encodeSite))
+	assert.Equal(t, ErrUnsupportedDatabase, Write(&buf, networks, Metadata{IPVersion: 5}, encodeSite))
+	err := Write(&buf, networks, Metadata{}, func(cidranger.RangerEntry) (any, error) {
+		return map[string]any{"count": 1}, nil
+	})
+	assert.Equal(t, ErrUnsupportedValue, err)
+	failure := fmt.Errorf("failure")
+	err = Write(&buf, networks, Metadata{}, func(cidranger.RangerEntry) (any, error) {
+		return nil, failure
+	})
+	assert.Equal(t, failure, err)
+	err = Write(&buf, networks, Metadata{RecordSize: 24}, func(cidranger.RangerEntry) (any, error) {
+		return make([]byte, 1<<24), nil
+	})
+	assert.Equal(t, ErrDatabaseTooLarge, err)
+	assert.Equal(t, 0, buf.Len())
+}
+
+func TestEncodePointer(t *testing.T) {
+	for _, offset := range []uint{0, 2047, 2048, 526335, 526336, 134744063, 134744064, 1 << 31} {
+		e := &encoder{}
+		e.pointer(offset)
+		d := &decoder{buf: e.buf}
+		_, size, next, err := d.control(0)
+		assert.NoError(t, err)
+		pointer, _, err := d.pointer(size, next)
+		assert.NoError(t, err)
+		assert.Equal(t, offset, pointer)
+	}
+}
+
+// ranger returns a ranger of siteEntry(s) named after their network.
+func ranger(t *testing.T, networks ...string) cidranger.Ranger {
+	ranger := cidranger.NewPCTrieRanger()
+	for _, network := range networks {
+		_, ipNet, err := net.ParseCIDR(network)
+		assert.NoError(t, err)
+		ranger.Insert(&siteEntry{ipNet: *ipNet, site: network})
+	}
+	return ranger
+}
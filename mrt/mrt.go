@@ -0,0 +1,422 @@
+/*
+Package mrt loads BGP routing tables from MRT TABLE_DUMP_V2 RIB dumps, such
+as the bview and rib files of RIPE RIS and RouteViews, into a
+cidranger.Ranger, to find out the AS path, origin AS and next hops of the
+route to an address.
+
+Dumps list every route collected for a prefix, one for each peer.  The route
+inserted for a prefix is selected by a Policy, BestPath by default.
+
+Dumps are usually compressed, Load expects them decompressed:
+
+	file, err := os.Open("bview.20231115.0000.gz")
+	reader, err := gzip.NewReader(file)
+	err = mrt.Load(reader, ranger, mrt.BestPath)
+
+PEER_INDEX_TABLE, RIB_IPV4_UNICAST and RIB_IPV6_UNICAST records are read,
+along with their RFC 8050 ADDPATH variants, other records are skipped.
+*/
+package mrt
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"time"
+
+	"github.com/yl2chen/cidranger"
+)
+
+// ErrInvalidRecord is returned when an MRT record is malformed.
+var ErrInvalidRecord = fmt.Errorf("Invalid MRT record")
+
+// MRT types and TABLE_DUMP_V2 subtypes.
+const (
+	typeTableDumpV2 = 13
+
+	subtypePeerIndexTable        = 1
+	subtypeRIBIPv4Unicast        = 2
+	subtypeRIBIPv6Unicast        = 4
+	subtypeRIBIPv4UnicastAddPath = 8
+	subtypeRIBIPv6UnicastAddPath = 10
+)
+
+// BGP path attribute types.
+const (
+	attributeOrigin             = 1
+	attributeASPath             = 2
+	attributeNextHop            = 3
+	attributeMED                = 4
+	attributeLocalPref          = 5
+	attributeMPReachNLRI        = 14
+	attributeFlagExtendedLength = 0x10
+)
+
+// headerSize is the size of the common header of MRT records.
+const headerSize = 12
+
+// Peer is a BGP peer of the collector that dumped a table.
+type Peer struct {
+	BGPID netip.Addr
+	Addr  netip.Addr
+	AS    uint32
+}
+
+// Origin is the ORIGIN attribute of a route.
+type Origin uint8
+
+// Origins of routes, in order of preference.
+const (
+	OriginIGP        Origin = 0
+	OriginEGP        Origin = 1
+	OriginIncomplete Origin = 2
+)
+
+// SegmentType is the type of a segment of an AS path.
+type SegmentType uint8
+
+// AS path segment types.
+const (
+	ASSet            SegmentType = 1
+	ASSequence       SegmentType = 2
+	ASConfedSequence SegmentType = 3
+	ASConfedSet      SegmentType = 4
+)
+
+// Segment is a segment of an AS path, the ASNs of sets being unordered.
+type Segment struct {
+	Type SegmentType
+	ASNs []uint32
+}
+
+// Route is a route to a prefix, as received from a peer.
+type Route struct {
+	Peer       Peer
+	Originated time.Time
+	// PathID is the path identifier of routes read from ADDPATH records.
+	PathID uint32
+	Origin Origin
+	ASPath []Segment
+	// OriginAS is the last AS of the AS path, or 0 when the path ends with
+	// a set of several ASNs or is empty.
+	OriginAS  uint32
+	NextHops  []netip.Addr
+	MED       uint32
+	LocalPref uint32
+	HasMED    bool
+	// HasLocalPref is only set by collectors dumping iBGP routes.
+	HasLocalPref bool
+}
+
+// ASPathLength returns the length of the AS path as counted by BGP best
+// path selection, sets counting as one AS and confederation segments as
+// none.
+func (r *Route) ASPathLength() int {
+	length := 0
+	for _, segment := range r.ASPath {
+		switch segment.Type {
+		case ASSequence:
+			length += len(segment.ASNs)
+		case ASSet:
+			length++
+		}
+	}
+	return length
+}
+
+// Entry is a cidranger.RangerEntry for a prefix of a routing table, along
+// with the route selected for it.
+type Entry struct {
+	ipNet net.IPNet
+	Route
+}
+
+// Network returns the network of entry.
+func (e *Entry) Network() net.IPNet {
+	return e.ipNet
+}
+
+// Lookup returns the Entry of the longest prefix matching given ip, holding
+// the route traffic to ip would follow, or nil if no prefix matches.
+func Lookup(ranger cidranger.Ranger, ip net.IP) (*Entry, error) {
+	return cidranger.LookupEntry[*Entry](ranger, ip)
+}
+
+// Policy returns the index of the route to insert for prefix among routes,
+// or -1 to leave prefix out.  Routes are in the order of the dump.
+type Policy func(prefix netip.Prefix, routes []Route) int
+
+// BestPath is the Policy preferring routes the way BGP best path selection
+// does, by highest local preference, shortest AS path, lowest origin and
+// lowest MED, the first route listed winning ties.
+func BestPath(prefix netip.Prefix, routes []Route) int {
+	best := -1
+	for i := range routes {
+		if best < 0 || better(&routes[i], &routes[best]) {
+			best = i
+		}
+	}
+	return best
+}
+
+func better(route, than *Route) bool {
+	if route.LocalPref != than.LocalPref {
+		return route.LocalPref > than.LocalPref
+	}
+	if route.ASPathLength() != than.ASPathLength() {
+		return route.ASPathLength() < than.ASPathLength()
+	}
+	if route.Origin != than.Origin {
+		return route.Origin < than.Origin
+	}
+	return route.MED < than.MED
+}
+
+// Load inserts the prefixes of the TABLE_DUMP_V2 dump read from r into
+// ranger, along with the route policy selects for each, BestPath when nil.
+// A malformed record fails the whole load, with an error carrying its number,
+// counting from 1.
+func Load(r io.Reader, ranger cidranger.Ranger, policy Policy) error {
+	if policy == nil {
+		policy = BestPath
+	}
+	batch := cidranger.NewBatch()
+	var peers []Peer
+	header := make([]byte, headerSize)
+	var body []byte
+	for number := 1; ; number++ {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return fmt.Errorf("record %d: %w", number, recordError(err))
+		}
+		kind := binary.BigEndian.Uint16(header[4:])
+		subtype := binary.BigEndian.Uint16(header[6:])
+		length := binary.BigEndian.Uint32(header[8:])
+		if kind != typeTableDumpV2 {
+			if _, err := io.CopyN(io.Discard, r, int64(length)); err != nil {
+				return fmt.Errorf("record %d: %w", number, recordError(err))
+			}
+			continue
+		}
+		// Grow body as records are read rather than trusting length.
+		body = body[:0]
+		if _, err := io.CopyN((*appendWriter)(&body), r, int64(length)); err != nil {
+			return fmt.Errorf("record %d: %w", number, recordError(err))
+		}
+
+		var err error
+		switch subtype {
+		case subtypePeerIndexTable:
+			peers, err = parsePeerIndexTable(body)
+		case subtypeRIBIPv4Unicast, subtypeRIBIPv6Unicast,
+			subtypeRIBIPv4UnicastAddPath, subtypeRIBIPv6UnicastAddPath:
+			var entry *Entry
+			entry, err = parseRIB(body, subtype, peers, policy)
+			if entry != nil {
+				batch.Insert(entry)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("record %d: %w", number, err)
+		}
+	}
+	return batch.Commit(ranger)
+}
+
+// appendWriter is an io.Writer appending to a byte slice.
+type appendWriter []byte
+
+func (w *appendWriter) Write(p []byte) (int, error) {
+	*w = append(*w, p...)
+	return len(p), nil
+}
+
+// recordError returns the error of reading a record, ErrInvalidRecord for
+// truncated ones.
+func recordError(err error) error {
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return ErrInvalidRecord
+	}
+	return err
+}
+
+// buffer reads the fields of a record, failing with ErrInvalidRecord past
+// its end.
+type buffer struct {
+	data []byte
+	err  error
+}
+
+func (b *buffer) bytes(n int) []byte {
+	if b.err != nil || n > len(b.data) {
+		b.err = ErrInvalidRecord
+		return make([]byte, n)
+	}
+	field := b.data[:n]
+	b.data = b.data[n:]
+	return field
+}
+
+func (b *buffer) uint8() uint8 {
+	return b.bytes(1)[0]
+}
+
+func (b *buffer) uint16() uint16 {
+	return binary.BigEndian.Uint16(b.bytes(2))
+}
+
+func (b *buffer) uint32() uint32 {
+	return binary.BigEndian.Uint32(b.bytes(4))
+}
+
+func (b *buffer) addr(n int) netip.Addr {
+	addr, _ := netip.AddrFromSlice(b.bytes(n))
+	return addr
+}
+
+func parsePeerIndexTable(body []byte) ([]Peer, error) {
+	b := &buffer{data: body}
+	b.bytes(4) // collector BGP ID
+	b.bytes(int(b.uint16()))
+	count := int(b.uint16())
+	peers := make([]Peer, 0, min(count, len(body)/7))
+	for i := 0; i < count && b.err == nil; i++ {
+		peerType := b.uint8()
+		var peer Peer
+		peer.BGPID = b.addr(4)
+		if peerType&0x1 != 0 {
+			peer.Addr = b.addr(16)
+		} else {
+			peer.Addr = b.addr(4)
+		}
+		if peerType&0x2 != 0 {
+			peer.AS = b.uint32()
+		} else {
+			peer.AS = uint32(b.uint16())
+		}
+		peers = append(peers, peer)
+	}
+	return peers, b.err
+}
+
+// parseRIB returns the entry for the prefix of a RIB record, nil when
+// policy leaves it out.
+func parseRIB(body []byte, subtype uint16, peers []Peer, policy Policy) (*Entry, error) {
+	b := &buffer{data: body}
+	b.uint32() // sequence number
+	bits := int(b.uint8())
+	size := net.IPv4len
+	if subtype == subtypeRIBIPv6Unicast || subtype == subtypeRIBIPv6UnicastAddPath {
+		size = net.IPv6len
+	}
+	if bits > size*8 {
+		return nil, ErrInvalidRecord
+	}
+	ip := make(net.IP, size)
+	copy(ip, b.bytes((bits+7)/8))
+	network := net.IPNet{IP: ip, Mask: net.CIDRMask(bits, size*8)}
+	network.IP = ip.Mask(network.Mask)
+	addr, _ := netip.AddrFromSlice(network.IP)
+	prefix := netip.PrefixFrom(addr, bits)
+
+	count := int(b.uint16())
+	routes := make([]Route, 0, min(count, len(body)/8))
+	for i := 0; i < count && b.err == nil; i++ {
+		index := int(b.uint16())
+		if index >= len(peers) {
+			return nil, ErrInvalidRecord
+		}
+		route := Route{
+			Peer:       peers[index],
+			Originated: time.Unix(int64(b.uint32()), 0).UTC(),
+		}
+		if subtype == subtypeRIBIPv4UnicastAddPath || subtype == subtypeRIBIPv6UnicastAddPath {
+			route.PathID = b.uint32()
+		}
+		attributes := b.bytes(int(b.uint16()))
+		if b.err != nil {
+			break
+		}
+		if err := parseAttributes(attributes, &route); err != nil {
+			return nil, err
+		}
+		routes = append(routes, route)
+	}
+	if b.err != nil {
+		return nil, b.err
+	}
+	selected := policy(prefix, routes)
+	if selected < 0 || selected >= len(routes) {
+		return nil, nil
+	}
+	return &Entry{ipNet: network, Route: routes[selected]}, nil
+}
+
+func parseAttributes(attributes []byte, route *Route) error {
+	b := &buffer{data: attributes}
+	for len(b.data) > 0 && b.err == nil {
+		flags := b.uint8()
+		kind := b.uint8()
+		length := 0
+		if flags&attributeFlagExtendedLength != 0 {
+			length = int(b.uint16())
+		} else {
+			length = int(b.uint8())
+		}
+		value := &buffer{data: b.bytes(length)}
+		if b.err != nil {
+			break
+		}
+		switch kind {
+		case attributeOrigin:
+			route.Origin = Origin(value.uint8())
+		case attributeASPath:
+			// TABLE_DUMP_V2 encodes every AS path with 4 byte ASNs.
+			for len(value.data) > 0 && value.err == nil {
+				segment := Segment{Type: SegmentType(value.uint8())}
+				n := int(value.uint8())
+				for i := 0; i < n; i++ {
+					segment.ASNs = append(segment.ASNs, value.uint32())
+				}
+				route.ASPath = append(route.ASPath, segment)
+			}
+		case attributeNextHop:
+			route.NextHops = append(route.NextHops, value.addr(4))
+		case attributeMED:
+			route.MED, route.HasMED = value.uint32(), true
+		case attributeLocalPref:
+			route.LocalPref, route.HasLocalPref = value.uint32(), true
+		case attributeMPReachNLRI:
+			// TABLE_DUMP_V2 abbreviates the attribute to the next hops, some
+			// dumps hold it whole though.
+			if length == 0 || int(value.data[0]) != length-1 {
+				value.bytes(3) // AFI and SAFI
+			}
+			nextHops := value.bytes(int(value.uint8()))
+			if len(nextHops) == net.IPv4len || len(nextHops)%net.IPv6len == 0 {
+				for len(nextHops) > 0 {
+					n := min(len(nextHops), net.IPv6len)
+					addr, _ := netip.AddrFromSlice(nextHops[:n])
+					route.NextHops = append(route.NextHops, addr)
+					nextHops = nextHops[n:]
+				}
+			}
+		}
+		if value.err != nil {
+			return value.err
+		}
+	}
+	if last := len(route.ASPath) - 1; last >= 0 {
+		segment := route.ASPath[last]
+		if segment.Type == ASSequence && len(segment.ASNs) > 0 ||
+			segment.Type == ASSet && len(segment.ASNs) == 1 {
+			route.OriginAS = segment.ASNs[len(segment.ASNs)-1]
+		}
+	}
+	return b.err
+}
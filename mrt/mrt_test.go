@@ -0,0 +1,257 @@
+package mrt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yl2chen/cidranger"
+)
+
+// dump writes MRT records for tests.
+type dump struct {
+	bytes.Buffer
+}
+
+func (d *dump) record(kind, subtype uint16, body []byte) *dump {
+	header := binary.BigEndian.AppendUint32(nil, 1700000000)
+	header = binary.BigEndian.AppendUint16(header, kind)
+	header = binary.BigEndian.AppendUint16(header, subtype)
+	header = binary.BigEndian.AppendUint32(header, uint32(len(body)))
+	d.Write(header)
+	d.Write(body)
+	return d
+}
+
+func (d *dump) peers(peers ...Peer) *dump {
+	body := []byte{192, 0, 2, 1}
+	body = binary.BigEndian.AppendUint16(body, 4)
+	body = append(body, "test"...)
+	body = binary.BigEndian.AppendUint16(body, uint16(len(peers)))
+	for _, peer := range peers {
+		peerType := byte(0x2)
+		if peer.Addr.Is6() {
+			peerType |= 0x1
+		}
+		body = append(body, peerType)
+		body = append(body, peer.BGPID.AsSlice()...)
+		body = append(body, peer.Addr.AsSlice()...)
+		body = binary.BigEndian.AppendUint32(body, peer.AS)
+	}
+	return d.record(typeTableDumpV2, subtypePeerIndexTable, body)
+}
+
+type ribRoute struct {
+	peer       uint16
+	attributes []byte
+}
+
+func (d *dump) rib(subtype uint16, prefix string, routes ...ribRoute) *dump {
+	p := netip.MustParsePrefix(prefix)
+	body := binary.BigEndian.AppendUint32(nil, 0)
+	body = append(body, byte(p.Bits()))
+	body = append(body, p.Addr().AsSlice()[:(p.Bits()+7)/8]...)
+	body = binary.BigEndian.AppendUint16(body, uint16(len(routes)))
+	for _, route := range routes {
+		body = binary.BigEndian.AppendUint16(body, route.peer)
+		body = binary.BigEndian.AppendUint32(body, 1690000000)
+		if subtype == subtypeRIBIPv4UnicastAddPath || subtype == subtypeRIBIPv6UnicastAddPath {
+			body = binary.BigEndian.AppendUint32(body, 7)
+		}
+		body = binary.BigEndian.AppendUint16(body, uint16(len(route.attributes)))
+		body = append(body, route.attributes...)
+	}
+	return d.record(typeTableDumpV2, subtype, body)
+}
+
+func attribute(kind byte, value []byte) []byte {
+	if len(value) > 255 {
+		return append(binary.BigEndian.AppendUint16([]byte{0x50, kind}, uint16(len(value))), value...)
+	}
+	return append([]byte{0x40, kind, byte(len(value))}, value...)
+}
+
+func asPath(segments ...Segment) []byte {
+	var value []byte
+	for _, segment := range segments {
+		value = append(value, byte(segment.Type), byte(len(segment.ASNs)))
+		for _, asn := range segment.ASNs {
+			value = binary.BigEndian.AppendUint32(value, asn)
+		}
+	}
+	return attribute(attributeASPath, value)
+}
+
+func attributes(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+var testPeers = []Peer{
+	{BGPID: netip.MustParseAddr("10.0.0.1"), Addr: netip.MustParseAddr("192.0.2.10"), AS: 64500},
+	{BGPID: netip.MustParseAddr("10.0.0.2"), Addr: netip.MustParseAddr("2001:db8::10"), AS: 4200000000},
+}
+
+func testDump() *dump {
+	d := &dump{}
+	// Records of other types are skipped.
+	d.record(16, 4, []byte{1, 2, 3})
+	d.peers(testPeers...)
+	d.rib(subtypeRIBIPv4Unicast, "203.0.113.0/24",
+		ribRoute{0, attributes(
+			attribute(attributeOrigin, []byte{0}),
+			asPath(Segment{ASSequence, []uint32{64500, 64501, 64502}}),
+			attribute(attributeNextHop, []byte{192, 0, 2, 10}),
+		)},
+		ribRoute{1, attributes(
+			attribute(attributeOrigin, []byte{0}),
+			asPath(Segment{ASSequence, []uint32{4200000000, 64502}}),
+			attribute(attributeMPReachNLRI, append([]byte{4}, 192, 0, 2, 20)),
+			attribute(attributeMED, []byte{0, 0, 0, 5}),
+		)},
+	)
+	d.rib(subtypeRIBIPv4Unicast, "198.51.100.128/25",
+		ribRoute{0, attributes(
+			attribute(attributeOrigin, []byte{2}),
+			asPath(Segment{ASSequence, []uint32{64500}}, Segment{ASSet, []uint32{64510, 64511}}),
+			attribute(attributeNextHop, []byte{192, 0, 2, 10}),
+		)},
+		ribRoute{1, attributes(
+			attribute(attributeOrigin, []byte{0}),
+			asPath(Segment{ASSequence, []uint32{4200000000}}, Segment{ASSet, []uint32{64510, 64511}}),
+		)},
+	)
+	// Full MP_REACH_NLRI with a global and a link-local next hop.
+	nextHops := append(netip.MustParseAddr("2001:db8::10").AsSlice(), netip.MustParseAddr("fe80::10").AsSlice()...)
+	d.rib(subtypeRIBIPv6Unicast, "2001:db8:1000::/36",
+		ribRoute{1, attributes(
+			asPath(Segment{ASSequence, []uint32{4200000000, 64520}}),
+			attribute(attributeMPReachNLRI, append([]byte{0, 2, 1, 32}, append(nextHops, 0)...)),
+		)},
+	)
+	d.rib(subtypeRIBIPv6UnicastAddPath, "2001:db8:2000::/35",
+		ribRoute{1, attributes(
+			asPath(Segment{ASSequence, []uint32{4200000000, 64530}}),
+			attribute(attributeLocalPref, []byte{0, 0, 0, 200}),
+		)},
+	)
+	return d
+}
+
+func TestLoad(t *testing.T) {
+	ranger := cidranger.NewPCTrieRanger()
+	assert.NoError(t, Load(bytes.NewReader(testDump().Bytes()), ranger, nil))
+	assert.Equal(t, 4, ranger.Len())
+
+	entry, err := Lookup(ranger, net.ParseIP("203.0.113.7"))
+	assert.NoError(t, err)
+	if assert.NotNil(t, entry) {
+		network := entry.Network()
+		assert.Equal(t, "203.0.113.0/24", network.String())
+		// The shorter AS path of the second peer wins.
+		assert.Equal(t, testPeers[1], entry.Peer)
+		assert.Equal(t, []Segment{{ASSequence, []uint32{4200000000, 64502}}}, entry.ASPath)
+		assert.Equal(t, uint32(64502), entry.OriginAS)
+		assert.Equal(t, []netip.Addr{netip.MustParseAddr("192.0.2.20")}, entry.NextHops)
+		assert.Equal(t, OriginIGP, entry.Origin)
+		assert.True(t, entry.HasMED)
+		assert.Equal(t, uint32(5), entry.MED)
+		assert.Equal(t, time.Unix(1690000000, 0).UTC(), entry.Originated)
+	}
+
+	entry, err = Lookup(ranger, net.ParseIP("198.51.100.200"))
+	assert.NoError(t, err)
+	if assert.NotNil(t, entry) {
+		// Equal AS path lengths, the IGP origin wins.
+		assert.Equal(t, testPeers[1], entry.Peer)
+		assert.Equal(t, 2, entry.ASPathLength())
+		assert.Equal(t, uint32(0), entry.OriginAS)
+		assert.Empty(t, entry.NextHops)
+	}
+
+	entry, err = Lookup(ranger, net.ParseIP("2001:db8:1000::1"))
+	assert.NoError(t, err)
+	if assert.NotNil(t, entry) {
+		assert.Equal(t, uint32(64520), entry.OriginAS)
+		assert.Equal(t, []netip.Addr{netip.MustParseAddr("2001:db8::10"), netip.MustParseAddr("fe80::10")}, entry.NextHops)
+	}
+
+	entry, err = Lookup(ranger, net.ParseIP("2001:db8:2000::1"))
+	assert.NoError(t, err)
+	if assert.NotNil(t, entry) {
+		network := entry.Network()
+		assert.Equal(t, "2001:db8:2000::/35", network.String())
+		assert.Equal(t, uint32(7), entry.PathID)
+		assert.True(t, entry.HasLocalPref)
+		assert.Equal(t, uint32(200), entry.LocalPref)
+	}
+}
+
+func TestLoadPolicy(t *testing.T) {
+	var prefixes []string
+	fromPeer := func(prefix netip.Prefix, routes []Route) int {
+		prefixes = append(prefixes, prefix.String())
+		for i, route := range routes {
+			if route.Peer.AS == 64500 {
+				return i
+			}
+		}
+		return -1
+	}
+	ranger := cidranger.NewPCTrieRanger()
+	assert.NoError(t, Load(bytes.NewReader(testDump().Bytes()), ranger, fromPeer))
+	assert.Equal(t, []string{"203.0.113.0/24", "198.51.100.128/25", "2001:db8:1000::/36", "2001:db8:2000::/35"}, prefixes)
+	assert.Equal(t, 2, ranger.Len())
+
+	entry, err := Lookup(ranger, net.ParseIP("203.0.113.7"))
+	assert.NoError(t, err)
+	if assert.NotNil(t, entry) {
+		assert.Equal(t, []netip.Addr{netip.MustParseAddr("192.0.2.10")}, entry.NextHops)
+		assert.Equal(t, uint32(64502), entry.OriginAS)
+	}
+}
+
+func TestBestPath(t *testing.T) {
+	routes := []Route{
+		{ASPath: []Segment{{ASSequence, []uint32{1, 2, 3}}}},
+		{ASPath: []Segment{{ASSequence, []uint32{1, 2}}}, MED: 10},
+		{ASPath: []Segment{{ASSequence, []uint32{1}}, {ASConfedSequence, []uint32{5, 6}}}, MED: 5},
+		{ASPath: []Segment{{ASSequence, []uint32{1, 2, 3, 4}}}, LocalPref: 100},
+	}
+	prefix := netip.MustParsePrefix("10.0.0.0/8")
+	assert.Equal(t, 3, BestPath(prefix, routes))
+	assert.Equal(t, 2, BestPath(prefix, routes[:3]))
+	assert.Equal(t, 1, BestPath(prefix, routes[:2]))
+	assert.Equal(t, -1, BestPath(prefix, nil))
+}
+
+func TestLoadInvalid(t *testing.T) {
+	valid := testDump().Bytes()
+	cases := map[string][]byte{
+		"truncated header": valid[:5],
+		"truncated record": valid[:len(valid)-1],
+		"RIB before peers": (&dump{}).rib(subtypeRIBIPv4Unicast, "10.0.0.0/8", ribRoute{0, nil}).Bytes(),
+		"prefix too long": (&dump{}).peers(testPeers...).record(typeTableDumpV2, subtypeRIBIPv4Unicast,
+			[]byte{0, 0, 0, 0, 33, 10, 0, 0, 0, 0, 0, 0}).Bytes(),
+		"truncated attribute": (&dump{}).peers(testPeers...).rib(subtypeRIBIPv4Unicast, "10.0.0.0/8",
+			ribRoute{0, []byte{0x40, attributeNextHop, 4, 10}}).Bytes(),
+	}
+	for name, data := range cases {
+		ranger := cidranger.NewPCTrieRanger()
+		ranger.InsertPrefix(netip.MustParsePrefix("10.0.0.0/8"))
+		err := Load(bytes.NewReader(data), ranger, nil)
+		assert.True(t, errors.Is(err, ErrInvalidRecord), name)
+		assert.Equal(t, 1, ranger.Len(), name)
+	}
+
+	// Corrupting any byte must never panic.
+	for i := range valid {
+		corrupted := append([]byte{}, valid...)
+		corrupted[i] ^= 0xff
+		Load(bytes.NewReader(corrupted), cidranger.NewPCTrieRanger(), nil)
+	}
+}
@@ -1,5 +1,6 @@
 /*
-Package net provides utility functions for working with IPs (net.IP).
+Package net provides utility functions for working with IPs (net.IP and
+netip.Addr).
 */
 package net
 
@@ -9,6 +10,7 @@ import (
 	"fmt"
 	"math"
 	"net"
+	"net/netip"
 )
 
 // IPVersion is version of IP address.
@@ -63,6 +65,36 @@ func NewNetworkNumber(ip net.IP) NetworkNumber {
 	return nn
 }
 
+// NewNetworkNumberFromAddr returns a equivalent NetworkNumber to given
+// netip.Addr, return nil if addr is not valid.  IPv4-mapped IPv6 addresses
+// (::ffff:a.b.c.d) are unmapped to IPv4, mirroring net.IP.To4, and zones are
+// ignored.
+func NewNetworkNumberFromAddr(addr netip.Addr) NetworkNumber {
+	return AppendNetworkNumber(nil, addr)
+}
+
+// AppendNetworkNumber appends the NetworkNumber equivalent to given
+// netip.Addr to dst and returns the extended slice, dst is returned unchanged
+// if addr is not valid.  Passing a dst with enough capacity, e.g. a slice of a
+// [IPv6Uint32Count]uint32 array, performs the conversion without allocating.
+func AppendNetworkNumber(dst NetworkNumber, addr netip.Addr) NetworkNumber {
+	addr = addr.Unmap()
+	if addr.Is4() {
+		b := addr.As4()
+		return append(dst, binary.BigEndian.Uint32(b[:]))
+	}
+	if addr.Is6() {
+		b := addr.As16()
+		return append(dst,
+			binary.BigEndian.Uint32(b[0:4]),
+			binary.BigEndian.Uint32(b[4:8]),
+			binary.BigEndian.Uint32(b[8:12]),
+			binary.BigEndian.Uint32(b[12:16]),
+		)
+	}
+	return dst
+}
+
 // ToV4 returns ip address if ip is IPv4, returns nil otherwise.
 func (n NetworkNumber) ToV4() NetworkNumber {
 	if len(n) != IPv4Uint32Count {
@@ -79,6 +111,12 @@ func (n NetworkNumber) ToV6() NetworkNumber {
 	return n
 }
 
+// ToAddr returns equivalent netip.Addr.
+func (n NetworkNumber) ToAddr() netip.Addr {
+	addr, _ := netip.AddrFromSlice(n.ToIP())
+	return addr.Unmap()
+}
+
 // ToIP returns equivalent net.IP.
 func (n NetworkNumber) ToIP() net.IP {
 	ip := make(net.IP, len(n)*BytePerUint32)
@@ -106,6 +144,27 @@ func (n NetworkNumber) Equal(n1 NetworkNumber) bool {
 	return true
 }
 
+// Compare returns an integer comparing two network numbers numerically, the
+// result is 0 if n == n1, -1 if n < n1 and +1 if n > n1.  IPv4 network
+// numbers sort before IPv6 network numbers.
+func (n NetworkNumber) Compare(n1 NetworkNumber) int {
+	if len(n) != len(n1) {
+		if len(n) < len(n1) {
+			return -1
+		}
+		return 1
+	}
+	for i := range n {
+		if n[i] != n1[i] {
+			if n[i] < n1[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
 // Next returns the next logical network number.
 func (n NetworkNumber) Next() NetworkNumber {
 	newIP := make(NetworkNumber, len(n))
@@ -144,6 +203,26 @@ func (n NetworkNumber) Bit(position uint) (uint32, error) {
 	return (n[idx] >> rShift) & 1, nil
 }
 
+// Bits returns uint32 representing count bits ending at given position, e.g.,
+// "192.0.0.0" has bits value of 3 for 2 bits at position 31.
+func (n NetworkNumber) Bits(position uint, count uint) (uint32, error) {
+	if count == 0 || count > BitsPerUint32 || count > position+1 || int(position) > len(n)*BitsPerUint32-1 {
+		return 0, ErrInvalidBitPosition
+	}
+	low := position + 1 - count
+	idx := len(n) - 1 - int(low/BitsPerUint32)
+	rShift := low & (BitsPerUint32 - 1)
+	bits := n[idx] >> rShift
+	if rShift+count > BitsPerUint32 {
+		// Remaining bits are in the preceding uint32.
+		bits |= n[idx-1] << (BitsPerUint32 - rShift)
+	}
+	if count < BitsPerUint32 {
+		bits &= 1<<count - 1
+	}
+	return bits, nil
+}
+
 // LeastCommonBitPosition returns the smallest position of the preceding common
 // bits of the 2 network numbers, and returns an error ErrNoGreatestCommonBit
 // if the two network number diverges from the first bit.
@@ -185,6 +264,25 @@ func NewNetwork(ipNet net.IPNet) Network {
 	}
 }
 
+// NewNetworkFromPrefix returns Network built using given netip.Prefix.  The
+// prefix is masked, and IPv4-mapped IPv6 prefixes of 96 bits or longer are
+// unmapped to the equivalent IPv4 prefix.  An invalid prefix returns a Network
+// with a nil Number.
+func NewNetworkFromPrefix(prefix netip.Prefix) Network {
+	if !prefix.IsValid() {
+		return Network{}
+	}
+	prefix = prefix.Masked()
+	addr, bits := prefix.Addr(), prefix.Bits()
+	if addr.Is4In6() {
+		addr, bits = addr.Unmap(), bits-96
+	}
+	return NewNetwork(net.IPNet{
+		IP:   addr.AsSlice(),
+		Mask: net.CIDRMask(bits, addr.BitLen()),
+	})
+}
+
 // Masked returns a new network conforming to new mask.
 func (n Network) Masked(ones int) Network {
 	mask := net.CIDRMask(ones, len(n.Number)*BitsPerUint32)
@@ -235,11 +333,36 @@ func (n Network) LeastCommonBitPosition(n1 Network) (uint, error) {
 	return uint(math.Max(float64(maskPosition), float64(lcb))), nil
 }
 
+// Compare returns an integer comparing two networks by network number, then
+// by prefix length, so that a network sorts immediately before the networks
+// it covers.  The result is 0 if n == n1, -1 if n < n1 and +1 if n > n1.
+func (n Network) Compare(n1 Network) int {
+	if c := n.Number.Compare(n1.Number); c != 0 {
+		return c
+	}
+	ones, _ := n.IPNet.Mask.Size()
+	ones1, _ := n1.IPNet.Mask.Size()
+	if ones != ones1 {
+		if ones < ones1 {
+			return -1
+		}
+		return 1
+	}
+	return 0
+}
+
 // Equal is the equality test for 2 networks.
 func (n Network) Equal(n1 Network) bool {
 	return bytes.Equal(n.IPNet.IP, n1.IPNet.IP) && bytes.Equal(n.IPNet.Mask, n1.IPNet.Mask)
 }
 
+// Prefix returns equivalent netip.Prefix.
+func (n Network) Prefix() netip.Prefix {
+	addr, _ := netip.AddrFromSlice(n.IP)
+	ones, _ := n.IPNet.Mask.Size()
+	return netip.PrefixFrom(addr.Unmap(), ones)
+}
+
 func (n Network) String() string {
 	return n.IPNet.String()
 }
@@ -271,3 +394,40 @@ func NextIP(ip net.IP) net.IP {
 func PreviousIP(ip net.IP) net.IP {
 	return NewNetworkNumber(ip).Previous().ToIP()
 }
+
+// RangePrefixes returns the fewest prefixes covering exactly the addresses
+// from start to end inclusive, in address order.  It returns nil when start
+// and end are of different versions, or end is before start.
+func RangePrefixes(start, end netip.Addr) []netip.Prefix {
+	if !start.IsValid() || start.BitLen() != end.BitLen() || end.Less(start) {
+		return nil
+	}
+	var prefixes []netip.Prefix
+	for {
+		// Widen the prefix while it stays aligned on start and ends by end.
+		prefix := netip.PrefixFrom(start, start.BitLen())
+		for prefix.Bits() > 0 {
+			wider, _ := start.Prefix(prefix.Bits() - 1)
+			if wider.Addr() != start || end.Less(lastAddr(wider)) {
+				break
+			}
+			prefix = wider
+		}
+		prefixes = append(prefixes, prefix)
+		last := lastAddr(prefix)
+		if last == end {
+			return prefixes
+		}
+		start = last.Next()
+	}
+}
+
+// lastAddr returns the last address of prefix.
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	b := prefix.Addr().AsSlice()
+	for i := prefix.Bits(); i < len(b)*8; i++ {
+		b[i/8] |= 1 << (7 - i%8)
+	}
+	addr, _ := netip.AddrFromSlice(b)
+	return addr
+}
@@ -3,6 +3,7 @@ package net
 import (
 	"math"
 	"net"
+	"net/netip"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -30,6 +31,46 @@ func TestNewNetworkNumber(t *testing.T) {
 	}
 }
 
+func TestNewNetworkNumberFromAddr(t *testing.T) {
+	cases := []struct {
+		addr netip.Addr
+		nn   NetworkNumber
+		name string
+	}{
+		{netip.Addr{}, nil, "invalid input"},
+		{netip.MustParseAddr("128.0.0.0"), NetworkNumber([]uint32{2147483648}), "IPv4"},
+		{netip.MustParseAddr("::ffff:128.0.0.0"), NetworkNumber([]uint32{2147483648}), "IPv4-mapped IPv6"},
+		{
+			netip.MustParseAddr("2001:0db8::ff00:0042:8329"),
+			NetworkNumber([]uint32{536939960, 0, 65280, 4358953}),
+			"IPv6",
+		},
+		{
+			netip.MustParseAddr("2001:0db8::ff00:0042:8329%eth0"),
+			NetworkNumber([]uint32{536939960, 0, 65280, 4358953}),
+			"IPv6 with zone",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.nn, NewNetworkNumberFromAddr(tc.addr))
+			if tc.nn != nil {
+				assert.Equal(t, NewNetworkNumber(net.IP(tc.addr.AsSlice())), NewNetworkNumberFromAddr(tc.addr))
+				assert.Equal(t, tc.addr.Unmap().WithZone(""), tc.nn.ToAddr())
+			}
+		})
+	}
+}
+
+func TestAppendNetworkNumberAllocs(t *testing.T) {
+	addr := netip.MustParseAddr("2001:0db8::ff00:0042:8329")
+	var buf [IPv6Uint32Count]uint32
+	allocs := testing.AllocsPerRun(100, func() {
+		AppendNetworkNumber(buf[:0], addr)
+	})
+	assert.Equal(t, float64(0), allocs)
+}
+
 func TestNetworkNumberAssertion(t *testing.T) {
 	cases := []struct {
 		ip   NetworkNumber
@@ -97,6 +138,33 @@ func TestNetworkNumberBitError(t *testing.T) {
 	}
 }
 
+func TestNetworkNumberBits(t *testing.T) {
+	cases := []struct {
+		ip       NetworkNumber
+		position uint
+		count    uint
+		bits     uint32
+		err      error
+		name     string
+	}{
+		{NewNetworkNumber(net.ParseIP("192.0.0.0")), 31, 2, 3, nil, "IPv4 leading bits"},
+		{NewNetworkNumber(net.ParseIP("1.2.3.4")), 15, 8, 3, nil, "IPv4 middle byte"},
+		{NewNetworkNumber(net.ParseIP("1.2.3.4")), 31, 32, 0x01020304, nil, "IPv4 all bits"},
+		{NewNetworkNumber(net.ParseIP("0:0:0:1:8000::")), 65, 4, 6, nil, "IPv6 across uint32s"},
+		{NewNetworkNumber(net.ParseIP("8000::")), 127, 1, 1, nil, "IPv6 single bit"},
+		{NewNetworkNumber(net.ParseIP("1.2.3.4")), 1, 3, 0, ErrInvalidBitPosition, "count past last bit"},
+		{NewNetworkNumber(net.ParseIP("1.2.3.4")), 32, 1, 0, ErrInvalidBitPosition, "position out of bounds"},
+		{NewNetworkNumber(net.ParseIP("1.2.3.4")), 31, 0, 0, ErrInvalidBitPosition, "no bits"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			bits, err := tc.ip.Bits(tc.position, tc.count)
+			assert.Equal(t, tc.err, err)
+			assert.Equal(t, tc.bits, bits)
+		})
+	}
+}
+
 func TestNetworkNumberEqual(t *testing.T) {
 	cases := []struct {
 		n1     NetworkNumber
@@ -117,6 +185,30 @@ func TestNetworkNumberEqual(t *testing.T) {
 	}
 }
 
+func TestNetworkNumberCompare(t *testing.T) {
+	cases := []struct {
+		n1      string
+		n2      string
+		compare int
+		name    string
+	}{
+		{"192.128.0.0", "192.128.0.0", 0, "IPv4 equals"},
+		{"192.128.0.0", "192.128.0.1", -1, "IPv4 less"},
+		{"192.128.0.1", "192.128.0.0", 1, "IPv4 greater"},
+		{"8000::1", "8000::1", 0, "IPv6 equals"},
+		{"8000::1", "8000:1::", -1, "IPv6 less"},
+		{"8000:1::", "8000::1", 1, "IPv6 greater"},
+		{"255.255.255.255", "::", -1, "IPv4 before IPv6"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			n1 := NewNetworkNumber(net.ParseIP(tc.n1))
+			n2 := NewNetworkNumber(net.ParseIP(tc.n2))
+			assert.Equal(t, tc.compare, n1.Compare(n2))
+		})
+	}
+}
+
 func TestNetworkNumberNext(t *testing.T) {
 	cases := []struct {
 		ip   string
@@ -225,6 +317,30 @@ func TestNewNetwork(t *testing.T) {
 	assert.Equal(t, NetworkNumberMask{math.MaxUint32 - uint32(math.MaxUint8)}, n.Mask)
 }
 
+func TestNewNetworkFromPrefix(t *testing.T) {
+	cases := []struct {
+		prefix   netip.Prefix
+		expected string
+		name     string
+	}{
+		{netip.MustParsePrefix("192.128.0.0/24"), "192.128.0.0/24", "IPv4"},
+		{netip.MustParsePrefix("192.128.0.1/24"), "192.128.0.0/24", "IPv4 unmasked"},
+		{netip.MustParsePrefix("8000::/96"), "8000::/96", "IPv6"},
+		{netip.MustParsePrefix("::ffff:192.128.0.0/120"), "192.128.0.0/24", "IPv4-mapped IPv6"},
+		{netip.MustParsePrefix("::ffff:192.128.0.0/80"), "::/80", "IPv4-mapped IPv6 masked to IPv6"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, ipNet, _ := net.ParseCIDR(tc.expected)
+			n := NewNetworkFromPrefix(tc.prefix)
+			assert.Equal(t, NewNetwork(*ipNet), n)
+			assert.Equal(t, netip.MustParsePrefix(tc.expected), n.Prefix())
+		})
+	}
+
+	assert.Nil(t, NewNetworkFromPrefix(netip.Prefix{}).Number)
+}
+
 func TestNetworkMasked(t *testing.T) {
 	cases := []struct {
 		network       string
@@ -270,6 +386,29 @@ func TestNetworkEqual(t *testing.T) {
 	}
 }
 
+func TestNetworkCompare(t *testing.T) {
+	cases := []struct {
+		n1      string
+		n2      string
+		compare int
+		name    string
+	}{
+		{"192.128.0.0/24", "192.128.0.0/24", 0, "equals"},
+		{"192.128.0.0/24", "192.128.0.0/25", -1, "covering network first"},
+		{"192.128.0.0/25", "192.128.0.0/24", 1, "covered network last"},
+		{"192.128.0.128/25", "192.128.1.0/24", -1, "lower network number first"},
+		{"192.128.1.0/24", "192.128.0.128/25", 1, "higher network number last"},
+		{"8000::/96", "8000::/16", 1, "IPv6"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, ipNet1, _ := net.ParseCIDR(tc.n1)
+			_, ipNet2, _ := net.ParseCIDR(tc.n2)
+			assert.Equal(t, tc.compare, NewNetwork(*ipNet1).Compare(NewNetwork(*ipNet2)))
+		})
+	}
+}
+
 func TestNetworkContains(t *testing.T) {
 	cases := []struct {
 		network string
@@ -508,3 +647,33 @@ func benchmarkNetworkEqual(b *testing.B, net1 string, net2 string) {
 		n1.Equal(n2)
 	}
 }
+
+func TestRangePrefixes(t *testing.T) {
+	cases := []struct {
+		start    string
+		end      string
+		prefixes []string
+		name     string
+	}{
+		{"10.0.0.0", "10.0.0.255", []string{"10.0.0.0/24"}, "IPv4 aligned"},
+		{"1.0.0.5", "1.0.0.5", []string{"1.0.0.5/32"}, "IPv4 single address"},
+		{"192.168.0.0", "192.168.2.255", []string{"192.168.0.0/23", "192.168.2.0/24"}, "IPv4 not power of two"},
+		{"1.0.0.1", "1.0.0.10", []string{"1.0.0.1/32", "1.0.0.2/31", "1.0.0.4/30", "1.0.0.8/31", "1.0.0.10/32"}, "IPv4 unaligned"},
+		{"0.0.0.0", "255.255.255.255", []string{"0.0.0.0/0"}, "IPv4 whole space"},
+		{"255.255.255.254", "255.255.255.255", []string{"255.255.255.254/31"}, "IPv4 end of space"},
+		{"2001:db8::", "2001:db8:ffff:ffff:ffff:ffff:ffff:ffff", []string{"2001:db8::/32"}, "IPv6 aligned"},
+		{"2001:db8::1", "2001:db8::3", []string{"2001:db8::1/128", "2001:db8::2/127"}, "IPv6 unaligned"},
+		{"1.0.0.1", "1.0.0.0", nil, "end before start"},
+		{"1.0.0.1", "::1", nil, "version mismatch"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var prefixes []string
+			for _, prefix := range RangePrefixes(netip.MustParseAddr(tc.start), netip.MustParseAddr(tc.end)) {
+				prefixes = append(prefixes, prefix.String())
+			}
+			assert.Equal(t, tc.prefixes, prefixes)
+		})
+	}
+}
@@ -0,0 +1,169 @@
+/*
+Package rir loads the delegated statistics files published by the Regional
+Internet Registries, such as delegated-ripencc-extended-latest, into a
+cidranger.Ranger, to find out which registry and country an address was
+delegated to.
+
+Files are made of pipe separated records, after a version line and summary
+lines:
+
+	registry|cc|type|start|value|date|status[|opaque-id[|extensions...]]
+
+IPv4 records give the number of addresses delegated from start, which needs
+not be a power of two nor aligned, and get an Entry for each network of the
+fewest networks covering them.  IPv6 records give a prefix length.  ASN
+records are skipped.
+*/
+package rir
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yl2chen/cidranger"
+	rnet "github.com/yl2chen/cidranger/net"
+)
+
+// ErrInvalidRecord is returned when a record of a delegated statistics file
+// is malformed.
+var ErrInvalidRecord = fmt.Errorf("Invalid delegated statistics record")
+
+// Status is the state of a delegation.
+type Status string
+
+// Statuses found in delegated statistics files, the available and reserved
+// ones only in extended files.
+const (
+	Allocated Status = "allocated"
+	Assigned  Status = "assigned"
+	Available Status = "available"
+	Reserved  Status = "reserved"
+)
+
+// Entry is a cidranger.RangerEntry for a network delegated by a registry.
+type Entry struct {
+	ipNet net.IPNet
+	// Registry is the registry the network was delegated by, one of afrinic,
+	// apnic, arin, iana, lacnic and ripencc.
+	Registry string
+	// CountryCode is the ISO 3166 code of the country of the holder, empty
+	// or ZZ when there is none.
+	CountryCode string
+	// Date is the date of the delegation, zero when not known.
+	Date   time.Time
+	Status Status
+	// OpaqueID identifies the holder across the records of extended files,
+	// and is empty otherwise.
+	OpaqueID string
+}
+
+// Network returns the network of entry.
+func (e *Entry) Network() net.IPNet {
+	return e.ipNet
+}
+
+// Lookup returns the Entry of the delegation containing given ip, or nil if
+// ip was not delegated.
+func Lookup(ranger cidranger.Ranger, ip net.IP) (*Entry, error) {
+	return cidranger.LookupEntry[*Entry](ranger, ip)
+}
+
+// Load inserts the IPv4 and IPv6 records of a delegated statistics file into
+// ranger.  A malformed record fails the whole load, with an error carrying its
+// line number.
+func Load(r io.Reader, ranger cidranger.Ranger) error {
+	batch := cidranger.NewBatch()
+	scanner := bufio.NewScanner(r)
+	header := true
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "|")
+		// The version line comes first, starting with the format version.
+		if header {
+			header = false
+			if _, err := strconv.ParseFloat(fields[0], 64); err == nil {
+				continue
+			}
+		}
+		if len(fields) == 6 && fields[5] == "summary" {
+			continue
+		}
+		entries, err := parseRecord(fields)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", number, err)
+		}
+		for _, entry := range entries {
+			batch.Insert(entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return batch.Commit(ranger)
+}
+
+// parseRecord returns the entries of a record, none for ASN records.
+func parseRecord(fields []string) ([]*Entry, error) {
+	if len(fields) < 7 {
+		return nil, ErrInvalidRecord
+	}
+	kind, start, value := fields[2], fields[3], fields[4]
+	if kind == "asn" {
+		return nil, nil
+	}
+	addr, err := netip.ParseAddr(start)
+	if err != nil {
+		return nil, ErrInvalidRecord
+	}
+	var prefixes []netip.Prefix
+	switch {
+	case kind == "ipv4" && addr.Is4():
+		count, err := strconv.ParseUint(value, 10, 32)
+		first := binary.BigEndian.Uint32(addr.AsSlice())
+		if err != nil || count == 0 || uint64(first)+count-1 > 1<<32-1 {
+			return nil, ErrInvalidRecord
+		}
+		last := netip.AddrFrom4([4]byte(binary.BigEndian.AppendUint32(nil, first+uint32(count-1))))
+		prefixes = rnet.RangePrefixes(addr, last)
+	case kind == "ipv6" && addr.Is6():
+		bits, err := strconv.Atoi(value)
+		prefix := netip.PrefixFrom(addr, bits)
+		if err != nil || !prefix.IsValid() {
+			return nil, ErrInvalidRecord
+		}
+		prefixes = []netip.Prefix{prefix.Masked()}
+	default:
+		return nil, ErrInvalidRecord
+	}
+
+	entry := Entry{
+		Registry:    fields[0],
+		CountryCode: fields[1],
+		Status:      Status(strings.ToLower(fields[6])),
+	}
+	if date := fields[5]; date != "" && date != "00000000" {
+		if entry.Date, err = time.Parse("20060102", date); err != nil {
+			return nil, ErrInvalidRecord
+		}
+	}
+	if len(fields) > 7 {
+		entry.OpaqueID = fields[7]
+	}
+	entries := make([]*Entry, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		delegated := entry
+		delegated.ipNet = rnet.NewNetworkFromPrefix(prefix).IPNet
+		entries = append(entries, &delegated)
+	}
+	return entries, nil
+}
@@ -0,0 +1,107 @@
+package rir
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yl2chen/cidranger"
+)
+
+const delegated = `# Example extended delegated statistics
+2.3|ripencc|1700000000|6|19830705|20231115|+0100
+ripencc|*|asn|*|1|summary
+ripencc|*|ipv4|*|4|summary
+ripencc|*|ipv6|*|1|summary
+ripencc|NL|asn|1101|1|19920301|allocated|c8f8c3a2
+ripencc|FR|ipv4|2.0.0.0|1048576|20100712|ALLOCATED|6ab11cf4
+ripencc|DE|ipv4|5.10.64.0|768|20120203|assigned|0a6d5f40
+ripencc||ipv4|5.10.80.0|256||available|
+ripencc|ZZ|ipv4|5.10.96.0|2048|00000000|reserved|
+ripencc|DE|ipv6|2001:db8::|32|19990826|allocated|0a6d5f40|e-stats
+`
+
+func TestLoad(t *testing.T) {
+	ranger := cidranger.NewPCTrieRanger()
+	assert.NoError(t, Load(strings.NewReader(delegated), ranger))
+	// 768 addresses from 5.10.64.0 are a /23 and a /24.
+	assert.Equal(t, 6, ranger.Len())
+
+	entry, err := Lookup(ranger, net.ParseIP("2.15.1.1"))
+	assert.NoError(t, err)
+	if assert.NotNil(t, entry) {
+		network := entry.Network()
+		assert.Equal(t, "2.0.0.0/12", network.String())
+		assert.Equal(t, "ripencc", entry.Registry)
+		assert.Equal(t, "FR", entry.CountryCode)
+		assert.Equal(t, time.Date(2010, 7, 12, 0, 0, 0, 0, time.UTC), entry.Date)
+		assert.Equal(t, Allocated, entry.Status)
+		assert.Equal(t, "6ab11cf4", entry.OpaqueID)
+	}
+
+	for ip, expected := range map[string]string{
+		"5.10.64.1": "5.10.64.0/23",
+		"5.10.66.1": "5.10.66.0/24",
+	} {
+		entry, err := Lookup(ranger, net.ParseIP(ip))
+		assert.NoError(t, err)
+		if assert.NotNil(t, entry) {
+			network := entry.Network()
+			assert.Equal(t, expected, network.String())
+			assert.Equal(t, Assigned, entry.Status)
+		}
+	}
+
+	entry, err = Lookup(ranger, net.ParseIP("5.10.80.7"))
+	assert.NoError(t, err)
+	if assert.NotNil(t, entry) {
+		assert.Equal(t, "", entry.CountryCode)
+		assert.True(t, entry.Date.IsZero())
+		assert.Equal(t, Available, entry.Status)
+	}
+
+	entry, err = Lookup(ranger, net.ParseIP("5.10.100.1"))
+	assert.NoError(t, err)
+	if assert.NotNil(t, entry) {
+		assert.Equal(t, "ZZ", entry.CountryCode)
+		assert.True(t, entry.Date.IsZero())
+		assert.Equal(t, Reserved, entry.Status)
+	}
+
+	entry, err = Lookup(ranger, net.ParseIP("2001:db8::1"))
+	assert.NoError(t, err)
+	if assert.NotNil(t, entry) {
+		network := entry.Network()
+		assert.Equal(t, "2001:db8::/32", network.String())
+		assert.Equal(t, "DE", entry.CountryCode)
+		assert.Equal(t, "0a6d5f40", entry.OpaqueID)
+	}
+
+	entry, err = Lookup(ranger, net.ParseIP("5.10.67.1"))
+	assert.NoError(t, err)
+	assert.Nil(t, entry)
+}
+
+func TestLoadInvalid(t *testing.T) {
+	for _, record := range []string{
+		"ripencc|FR|ipv4|2.0.0.0",
+		"ripencc|FR|ipv4|2.0.0|256|20100712|allocated",
+		"ripencc|FR|ipv4|2.0.0.0|0|20100712|allocated",
+		"ripencc|FR|ipv4|255.255.255.0|257|20100712|allocated",
+		"ripencc|FR|ipv4|2001:db8::|256|20100712|allocated",
+		"ripencc|FR|ipv6|2001:db8::|129|20100712|allocated",
+		"ripencc|FR|ipv6|2001:db8::|32|2010-07-12|allocated",
+		"ripencc|FR|ipv5|2.0.0.0|256|20100712|allocated",
+	} {
+		ranger := cidranger.NewPCTrieRanger()
+		ranger.InsertPrefix(netip.MustParsePrefix("10.0.0.0/8"))
+		err := Load(strings.NewReader(delegated+record+"\n"), ranger)
+		assert.True(t, errors.Is(err, ErrInvalidRecord), record)
+		assert.Contains(t, err.Error(), "line 12:")
+		assert.Equal(t, 1, ranger.Len())
+	}
+}
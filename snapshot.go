@@ -0,0 +1,516 @@
+package cidranger
+
+import (
+	"iter"
+	"net"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+
+	rnet "github.com/yl2chen/cidranger/net"
+)
+
+// SnapshotRanger is a Ranger safe for concurrent use whose lookups never
+// block, see NewSnapshotPCTrieRanger.
+type SnapshotRanger interface {
+	Ranger
+	// Snapshot returns a read-only Ranger of the current entries, it is
+	// never affected by later mutations.  Its mutations fail with
+	// ErrReadOnlyRanger, except Clear which has no effect.
+	Snapshot() Ranger
+}
+
+// TypedSnapshotRanger is the generic counterpart of SnapshotRanger.
+type TypedSnapshotRanger[V any] interface {
+	TypedRanger[V]
+	// Snapshot returns a read-only TypedRanger of the current entries, it is
+	// never affected by later mutations.  Its mutations fail with
+	// ErrReadOnlyRanger, except Clear which has no effect.
+	Snapshot() TypedRanger[V]
+}
+
+// NewSnapshotPCTrieRanger returns a SnapshotRanger using persistent path
+// compressed tries, every mutation copies the path to the mutated network and
+// atomically publishes the new tries, sharing all unchanged nodes with the
+// previous ones.  Lookups, iteration and snapshots only load the current
+// tries and never block, while mutations are serialized.
+func NewSnapshotPCTrieRanger() SnapshotRanger {
+	trie := newSnapshotTrie[RangerEntry]()
+	return &snapshotEntryRanger{Ranger: newEntryRanger(trie), trie: trie}
+}
+
+// NewTypedSnapshotPCTrieRanger returns a TypedSnapshotRanger storing values of
+// type V, see NewSnapshotPCTrieRanger.
+func NewTypedSnapshotPCTrieRanger[V any]() TypedSnapshotRanger[V] {
+	return newSnapshotTrie[V]()
+}
+
+type snapshotEntryRanger struct {
+	Ranger
+	trie *snapshotTrie[RangerEntry]
+}
+
+// Snapshot returns a read-only Ranger of the current entries.
+func (s *snapshotEntryRanger) Snapshot() Ranger {
+	return newEntryRanger(s.trie.Snapshot())
+}
+
+// commitBatch commits given batch to a private copy of the tries, published
+// only once the whole batch is applied.
+func (s *snapshotEntryRanger) commitBatch(b *Batch) error {
+	return s.trie.transaction(func(txn *snapshotTrie[RangerEntry]) error {
+		return b.apply(newEntryRanger(txn))
+	})
+}
+
+// snapshotTrie is a TypedRanger holding an IPv4 and an IPv6 persistent prefix
+// trie, published together through an atomic pointer.  The published tries
+// are never mutated, so they can be read without locking.
+type snapshotTrie[V any] struct {
+	mu      sync.Mutex // Serializes mutations.
+	current atomic.Pointer[versionedRanger[V]]
+}
+
+func newSnapshotTrie[V any]() *snapshotTrie[V] {
+	s := &snapshotTrie[V]{}
+	s.current.Store(newVersionedRanger(newPrefixTree[V]).(*versionedRanger[V]))
+	return s
+}
+
+// Snapshot returns a read-only TypedRanger of the current entries.
+func (s *snapshotTrie[V]) Snapshot() TypedRanger[V] {
+	return &readOnlyRanger[V]{TypedRanger: s.current.Load()}
+}
+
+func (s *snapshotTrie[V]) Insert(network net.IPNet, value V) error {
+	return s.Upsert(network, func(V, bool) V {
+		return value
+	})
+}
+
+func (s *snapshotTrie[V]) InsertPrefix(prefix netip.Prefix, value V) error {
+	network := rnet.NewNetworkFromPrefix(prefix)
+	if network.Number == nil {
+		return ErrInvalidNetworkInput
+	}
+	return s.Insert(network.IPNet, value)
+}
+
+func (s *snapshotTrie[V]) Upsert(network net.IPNet, fn func(old V, exists bool) V) error {
+	return s.update(network.IP, func(root *prefixTrie[V]) (*prefixTrie[V], error) {
+		return root.upsertCopy(newCanonicalNetwork(network), fn)
+	})
+}
+
+func (s *snapshotTrie[V]) Swap(network net.IPNet, value V) (V, bool, error) {
+	var previous V
+	var replaced bool
+	err := s.Upsert(network, func(old V, exists bool) V {
+		previous, replaced = old, exists
+		return value
+	})
+	return previous, replaced, err
+}
+
+func (s *snapshotTrie[V]) Remove(network net.IPNet) (V, bool, error) {
+	return s.RemoveIf(network, nil)
+}
+
+func (s *snapshotTrie[V]) RemovePrefix(prefix netip.Prefix) (V, bool, error) {
+	network := rnet.NewNetworkFromPrefix(prefix)
+	if network.Number == nil {
+		var zero V
+		return zero, false, ErrInvalidNetworkInput
+	}
+	return s.Remove(network.IPNet)
+}
+
+func (s *snapshotTrie[V]) RemoveIf(network net.IPNet, fn func(value V) bool) (V, bool, error) {
+	var value V
+	var removed bool
+	err := s.update(network.IP, func(root *prefixTrie[V]) (*prefixTrie[V], error) {
+		var next *prefixTrie[V]
+		var err error
+		next, value, removed, err = root.removeCopy(newCanonicalNetwork(network), fn)
+		return next, err
+	})
+	return value, removed, err
+}
+
+func (s *snapshotTrie[V]) RemoveCovered(network net.IPNet) ([]Entry[V], error) {
+	var results []Entry[V]
+	err := s.update(network.IP, func(root *prefixTrie[V]) (*prefixTrie[V], error) {
+		var next *prefixTrie[V]
+		var err error
+		next, results, err = root.removeCoveredCopy(newCanonicalNetwork(network))
+		return next, err
+	})
+	return results, err
+}
+
+// Clear removes every entry by publishing empty tries.
+func (s *snapshotTrie[V]) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.current.Store(newVersionedRanger(newPrefixTree[V]).(*versionedRanger[V]))
+}
+
+// transaction calls fn with a snapshotTrie holding the current tries, whose
+// mutations are published all at once if fn succeeds and discarded otherwise.
+func (s *snapshotTrie[V]) transaction(fn func(txn *snapshotTrie[V]) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	txn := &snapshotTrie[V]{}
+	txn.current.Store(s.current.Load())
+	if err := fn(txn); err != nil {
+		return err
+	}
+	s.current.Store(txn.current.Load())
+	return nil
+}
+
+// update replaces the trie of the version of given ip with the one returned by
+// fn, fn must not mutate the current trie.
+func (s *snapshotTrie[V]) update(ip net.IP, fn func(root *prefixTrie[V]) (*prefixTrie[V], error)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current := s.current.Load()
+	ranger, err := current.getRangerForIP(ip)
+	if err != nil {
+		return err
+	}
+	root, err := fn(ranger.(*prefixTrie[V]))
+	if err != nil || root == ranger {
+		return err
+	}
+	next := *current
+	if ranger == current.ipV4Ranger {
+		next.ipV4Ranger = root
+	} else {
+		next.ipV6Ranger = root
+	}
+	s.current.Store(&next)
+	return nil
+}
+
+func (s *snapshotTrie[V]) Get(network net.IPNet) (V, bool, error) {
+	return s.current.Load().Get(network)
+}
+
+func (s *snapshotTrie[V]) GetPrefix(prefix netip.Prefix) (V, bool, error) {
+	return s.current.Load().GetPrefix(prefix)
+}
+
+func (s *snapshotTrie[V]) Contains(ip net.IP) (bool, error) {
+	return s.current.Load().Contains(ip)
+}
+
+func (s *snapshotTrie[V]) ContainsAddr(addr netip.Addr) (bool, error) {
+	return s.current.Load().ContainsAddr(addr)
+}
+
+func (s *snapshotTrie[V]) ContainingNetworks(ip net.IP) ([]Entry[V], error) {
+	return s.current.Load().ContainingNetworks(ip)
+}
+
+func (s *snapshotTrie[V]) ContainingPrefixes(addr netip.Addr) ([]Entry[V], error) {
+	return s.current.Load().ContainingPrefixes(addr)
+}
+
+func (s *snapshotTrie[V]) LongestMatch(ip net.IP) (Entry[V], bool, error) {
+	return s.current.Load().LongestMatch(ip)
+}
+
+func (s *snapshotTrie[V]) LongestMatchAddr(addr netip.Addr) (Entry[V], bool, error) {
+	return s.current.Load().LongestMatchAddr(addr)
+}
+
+func (s *snapshotTrie[V]) ShortestMatch(ip net.IP) (Entry[V], bool, error) {
+	return s.current.Load().ShortestMatch(ip)
+}
+
+func (s *snapshotTrie[V]) ShortestMatchAddr(addr netip.Addr) (Entry[V], bool, error) {
+	return s.current.Load().ShortestMatchAddr(addr)
+}
+
+func (s *snapshotTrie[V]) CoveredNetworks(network net.IPNet) ([]Entry[V], error) {
+	return s.current.Load().CoveredNetworks(network)
+}
+
+func (s *snapshotTrie[V]) CoveredPrefixes(prefix netip.Prefix) ([]Entry[V], error) {
+	return s.current.Load().CoveredPrefixes(prefix)
+}
+
+func (s *snapshotTrie[V]) CoveringNetworks(network net.IPNet) ([]Entry[V], error) {
+	return s.current.Load().CoveringNetworks(network)
+}
+
+func (s *snapshotTrie[V]) CoveringPrefixes(prefix netip.Prefix) ([]Entry[V], error) {
+	return s.current.Load().CoveringPrefixes(prefix)
+}
+
+func (s *snapshotTrie[V]) OverlappingNetworks(network net.IPNet) ([]Entry[V], error) {
+	return s.current.Load().OverlappingNetworks(network)
+}
+
+func (s *snapshotTrie[V]) OverlappingPrefixes(prefix netip.Prefix) ([]Entry[V], error) {
+	return s.current.Load().OverlappingPrefixes(prefix)
+}
+
+// Walk calls fn for every entry as of when Walk is called, until fn returns
+// false.
+func (s *snapshotTrie[V]) Walk(fn func(Entry[V]) bool) {
+	s.current.Load().Walk(fn)
+}
+
+// All returns an iterator over every entry as of when iteration starts.
+func (s *snapshotTrie[V]) All() iter.Seq[Entry[V]] {
+	return s.Walk
+}
+
+// Backward returns an iterator over every entry in reverse order, as of when
+// iteration starts.
+func (s *snapshotTrie[V]) Backward() iter.Seq[Entry[V]] {
+	return func(yield func(Entry[V]) bool) {
+		s.current.Load().Backward()(yield)
+	}
+}
+
+func (s *snapshotTrie[V]) Next(network net.IPNet) (Entry[V], bool, error) {
+	return s.current.Load().Next(network)
+}
+
+func (s *snapshotTrie[V]) Prev(network net.IPNet) (Entry[V], bool, error) {
+	return s.current.Load().Prev(network)
+}
+
+func (s *snapshotTrie[V]) EntriesBetween(start, end net.IP) ([]Entry[V], error) {
+	return s.current.Load().EntriesBetween(start, end)
+}
+
+func (s *snapshotTrie[V]) Len() int {
+	return s.current.Load().Len()
+}
+
+// readOnlyRanger is a TypedRanger rejecting every mutation with
+// ErrReadOnlyRanger.
+type readOnlyRanger[V any] struct {
+	TypedRanger[V]
+}
+
+func (r *readOnlyRanger[V]) Insert(net.IPNet, V) error {
+	return ErrReadOnlyRanger
+}
+
+func (r *readOnlyRanger[V]) InsertPrefix(netip.Prefix, V) error {
+	return ErrReadOnlyRanger
+}
+
+func (r *readOnlyRanger[V]) Upsert(net.IPNet, func(V, bool) V) error {
+	return ErrReadOnlyRanger
+}
+
+func (r *readOnlyRanger[V]) Swap(net.IPNet, V) (V, bool, error) {
+	var zero V
+	return zero, false, ErrReadOnlyRanger
+}
+
+func (r *readOnlyRanger[V]) Remove(net.IPNet) (V, bool, error) {
+	var zero V
+	return zero, false, ErrReadOnlyRanger
+}
+
+func (r *readOnlyRanger[V]) RemovePrefix(netip.Prefix) (V, bool, error) {
+	var zero V
+	return zero, false, ErrReadOnlyRanger
+}
+
+func (r *readOnlyRanger[V]) RemoveIf(net.IPNet, func(V) bool) (V, bool, error) {
+	var zero V
+	return zero, false, ErrReadOnlyRanger
+}
+
+func (r *readOnlyRanger[V]) RemoveCovered(net.IPNet) ([]Entry[V], error) {
+	return nil, ErrReadOnlyRanger
+}
+
+// Clear has no effect, as it can not report ErrReadOnlyRanger.
+func (r *readOnlyRanger[V]) Clear() {}
+
+// upsertCopy returns a copy of root trie p with the value returned by fn
+// stored for network, sharing every node off the path to network with p.
+func (p *prefixTrie[V]) upsertCopy(network rnet.Network, fn func(old V, exists bool) V) (*prefixTrie[V], error) {
+	root, sizeIncreased, err := p.insertCopy(network, fn)
+	if err != nil {
+		return nil, err
+	}
+	if sizeIncreased {
+		root.size++
+	}
+	return root, nil
+}
+
+// removeCopy returns a copy of root trie p without the value stored for
+// network, or p itself if nothing was removed.
+func (p *prefixTrie[V]) removeCopy(network rnet.Network, fn func(V) bool) (*prefixTrie[V], V, bool, error) {
+	root, value, removed, err := p.removeNodeCopy(network, fn)
+	if err != nil || !removed {
+		return p, value, false, err
+	}
+	root.size--
+	return root, value, true, nil
+}
+
+// removeCoveredCopy returns a copy of root trie p without the values covered
+// by network, or p itself if nothing was removed.
+func (p *prefixTrie[V]) removeCoveredCopy(network rnet.Network) (*prefixTrie[V], []Entry[V], error) {
+	var results []Entry[V]
+	if network.Covers(p.network) {
+		p.walk(func(entry Entry[V]) bool {
+			results = append(results, entry)
+			return true
+		})
+		root := newPathprefixTrie[V](p.network, 0)
+		return root, results, nil
+	}
+	root, results, err := p.removeCoveredNodeCopy(network)
+	if err != nil || len(results) == 0 {
+		return p, nil, err
+	}
+	root.size -= len(results)
+	return root, results, nil
+}
+
+// clone returns a shallow copy of p with its own children.  Copies are not
+// linked to their parent, and only ever handle a single bit as level
+// compression is not applied to persistent tries.
+func (p *prefixTrie[V]) clone() *prefixTrie[V] {
+	c := *p
+	c.parent = nil
+	c.children = append([]*prefixTrie[V](nil), p.children...)
+	return &c
+}
+
+func (p *prefixTrie[V]) insertCopy(network rnet.Network, fn func(old V, exists bool) V) (*prefixTrie[V], bool, error) {
+	c := p.clone()
+	if c.network.Equal(network) {
+		sizeIncreased := !c.hasValue
+		c.value = fn(c.value, c.hasValue)
+		c.hasValue = true
+		return c, sizeIncreased, nil
+	}
+
+	bit, err := c.childIndex(network.Number)
+	if err != nil {
+		return nil, false, err
+	}
+	child := c.children[bit]
+	if child == nil {
+		var zero V
+		c.children[bit] = newEntryTrie(network, fn(zero, false))
+		return c, true, nil
+	}
+
+	// Insert a new path prefix above the existing child if network diverges
+	// on its path to it, as insert does.
+	lcb, err := network.LeastCommonBitPosition(child.network)
+	if err != nil {
+		return nil, false, err
+	}
+	if int(lcb)-1 > child.targetBitPosition() {
+		pathPrefix := newPathprefixTrie[V](network, c.totalNumberOfBits()-lcb)
+		pathPrefixBit, err := pathPrefix.childIndex(child.network.Number)
+		if err != nil {
+			return nil, false, err
+		}
+		pathPrefix.children[pathPrefixBit] = child
+		child = pathPrefix
+	}
+	newChild, sizeIncreased, err := child.insertCopy(network, fn)
+	if err != nil {
+		return nil, false, err
+	}
+	c.children[bit] = newChild
+	return c, sizeIncreased, nil
+}
+
+func (p *prefixTrie[V]) removeNodeCopy(network rnet.Network, fn func(V) bool) (*prefixTrie[V], V, bool, error) {
+	var zero V
+	if p.hasEntry() && p.network.Equal(network) {
+		if fn != nil && !fn(p.value) {
+			return p, p.value, false, nil
+		}
+		c := p.clone()
+		c.value = zero
+		c.hasValue = false
+		return c, p.value, true, nil
+	}
+	if p.targetBitPosition() < 0 {
+		return p, zero, false, nil
+	}
+	bit, err := p.childIndex(network.Number)
+	if err != nil {
+		return p, zero, false, err
+	}
+	child := p.children[bit]
+	if child == nil {
+		return p, zero, false, nil
+	}
+	newChild, value, removed, err := child.removeNodeCopy(network, fn)
+	if err != nil || !removed {
+		return p, value, false, err
+	}
+	c := p.clone()
+	c.children[bit] = newChild.compressedCopy()
+	return c, value, true, nil
+}
+
+func (p *prefixTrie[V]) removeCoveredNodeCopy(network rnet.Network) (*prefixTrie[V], []Entry[V], error) {
+	if !p.network.Covers(network) || p.targetBitPosition() < 0 {
+		return p, nil, nil
+	}
+	bit, err := p.childIndex(network.Number)
+	if err != nil {
+		return p, nil, err
+	}
+	child := p.children[bit]
+	if child == nil {
+		return p, nil, nil
+	}
+	var results []Entry[V]
+	var newChild *prefixTrie[V]
+	if network.Covers(child.network) {
+		child.walk(func(entry Entry[V]) bool {
+			results = append(results, entry)
+			return true
+		})
+	} else {
+		newChild, results, err = child.removeCoveredNodeCopy(network)
+		if err != nil || len(results) == 0 {
+			return p, nil, err
+		}
+		newChild = newChild.compressedCopy()
+	}
+	c := p.clone()
+	c.children[bit] = newChild
+	return c, results, nil
+}
+
+// compressedCopy returns the lone child of p, or nil if it has none, if p
+// qualifies for path compression, p itself otherwise.  It must not be called
+// on a root trie.
+func (p *prefixTrie[V]) compressedCopy() *prefixTrie[V] {
+	if p.hasEntry() {
+		return p
+	}
+	var loneChild *prefixTrie[V]
+	for _, child := range p.children {
+		if child != nil {
+			if loneChild != nil {
+				return p
+			}
+			loneChild = child
+		}
+	}
+	return loneChild
+}
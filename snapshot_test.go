@@ -0,0 +1,159 @@
+package cidranger
+
+import (
+	"math/rand"
+	"net"
+	"net/netip"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	rnet "github.com/yl2chen/cidranger/net"
+)
+
+func TestSnapshotIsolation(t *testing.T) {
+	ranger := NewSnapshotPCTrieRanger()
+	for _, network := range []string{"10.0.0.0/8", "10.1.0.0/16", "192.168.0.0/24", "8000::/16"} {
+		assert.NoError(t, ranger.InsertPrefix(netip.MustParsePrefix(network)))
+	}
+	snapshot := ranger.Snapshot()
+
+	assert.NoError(t, ranger.InsertPrefix(netip.MustParsePrefix("10.1.2.0/24")))
+	_, err := ranger.RemovePrefix(netip.MustParsePrefix("10.0.0.0/8"))
+	assert.NoError(t, err)
+	_, err = ranger.RemoveCovered(*parseCIDRUnsafe("192.168.0.0/16"))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, ranger.Len())
+
+	// The snapshot still holds the entries as of when it was taken.
+	assert.Equal(t, 4, snapshot.Len())
+	entries, err := snapshot.ContainingNetworks(net.ParseIP("10.1.2.3"))
+	assert.NoError(t, err)
+	assert.Equal(t, []RangerEntry{
+		NewBasicRangerEntry(*parseCIDRUnsafe("10.0.0.0/8")),
+		NewBasicRangerEntry(*parseCIDRUnsafe("10.1.0.0/16")),
+	}, entries)
+	contains, err := snapshot.Contains(net.ParseIP("192.168.0.1"))
+	assert.NoError(t, err)
+	assert.True(t, contains)
+
+	ranger.Clear()
+	assert.Equal(t, 0, ranger.Len())
+	assert.Equal(t, 4, snapshot.Len())
+}
+
+func TestSnapshotSharesUnchangedNodes(t *testing.T) {
+	ranger := newSnapshotTrie[int]()
+	assert.NoError(t, ranger.InsertPrefix(netip.MustParsePrefix("10.0.0.0/8"), 1))
+	assert.NoError(t, ranger.InsertPrefix(netip.MustParsePrefix("192.168.0.0/16"), 2))
+	assert.NoError(t, ranger.InsertPrefix(netip.MustParsePrefix("8000::/16"), 3))
+	before := ranger.current.Load()
+
+	assert.NoError(t, ranger.InsertPrefix(netip.MustParsePrefix("10.1.0.0/16"), 4))
+	after := ranger.current.Load()
+
+	// Only the path to the inserted network is copied.
+	assert.Same(t, before.ipV6Ranger, after.ipV6Ranger)
+	oldRoot := before.ipV4Ranger.(*prefixTrie[int])
+	newRoot := after.ipV4Ranger.(*prefixTrie[int])
+	assert.NotSame(t, oldRoot, newRoot)
+	assert.NotSame(t, oldRoot.children[0], newRoot.children[0])
+	assert.Same(t, oldRoot.children[1], newRoot.children[1])
+	assert.Equal(t, 3, newRoot.Len())
+	assert.Equal(t, 2, oldRoot.Len())
+
+	// Removing a missing network publishes nothing.
+	_, removed, err := ranger.RemovePrefix(netip.MustParsePrefix("172.16.0.0/12"))
+	assert.NoError(t, err)
+	assert.False(t, removed)
+	assert.Same(t, after, ranger.current.Load())
+}
+
+func TestSnapshotIsReadOnly(t *testing.T) {
+	ranger := NewSnapshotPCTrieRanger()
+	network := *parseCIDRUnsafe("10.0.0.0/8")
+	assert.NoError(t, ranger.Insert(NewBasicRangerEntry(network)))
+	snapshot := ranger.Snapshot()
+
+	assert.Equal(t, ErrReadOnlyRanger, snapshot.Insert(NewBasicRangerEntry(network)))
+	assert.Equal(t, ErrReadOnlyRanger, snapshot.InsertPrefix(netip.MustParsePrefix("10.0.0.0/16")))
+	_, err := snapshot.Remove(network)
+	assert.Equal(t, ErrReadOnlyRanger, err)
+	_, err = snapshot.RemoveCovered(network)
+	assert.Equal(t, ErrReadOnlyRanger, err)
+	_, _, err = snapshot.Swap(NewBasicRangerEntry(network))
+	assert.Equal(t, ErrReadOnlyRanger, err)
+	snapshot.Clear()
+	assert.Equal(t, 1, snapshot.Len())
+}
+
+func TestSnapshotAgainstBase(t *testing.T) {
+	ranger := NewSnapshotPCTrieRanger()
+	baseRanger := newBruteRanger()
+	ipGen := resizedIPNetGenFactory(ipV4AWSRangesIPNets)
+	for i := 0; i < 5000; i++ {
+		network := ipGen().IPNet
+		switch rand.Intn(4) {
+		case 0, 1:
+			assert.NoError(t, ranger.Insert(NewBasicRangerEntry(network)))
+			assert.NoError(t, baseRanger.Insert(NewBasicRangerEntry(network)))
+		case 2:
+			actual, err := ranger.Remove(network)
+			assert.NoError(t, err)
+			expected, err := baseRanger.Remove(network)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, actual)
+		case 3:
+			network = rnet.NewNetwork(network).Masked(8 + rand.Intn(16)).IPNet
+			actual, err := ranger.RemoveCovered(network)
+			assert.NoError(t, err)
+			expected, err := baseRanger.RemoveCovered(network)
+			assert.NoError(t, err)
+			assert.ElementsMatch(t, expected, actual)
+		}
+		assert.Equal(t, baseRanger.Len(), ranger.Len())
+	}
+
+	var expected, actual []RangerEntry
+	for entry := range baseRanger.All() {
+		expected = append(expected, entry)
+	}
+	for entry := range ranger.All() {
+		actual = append(actual, entry)
+	}
+	assert.ElementsMatch(t, expected, actual)
+}
+
+func TestSnapshotConcurrentReaders(t *testing.T) {
+	ranger := NewSnapshotPCTrieRanger()
+	configureRangerWithAWSRanges(t, ranger)
+	base := ranger.Len()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := 0; j < 256; j++ {
+			prefix := netip.PrefixFrom(netip.AddrFrom4([4]byte{10, 0, byte(j), 0}), 24)
+			assert.NoError(t, ranger.InsertPrefix(prefix))
+		}
+	}()
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 256; j++ {
+				snapshot := ranger.Snapshot()
+				count := 0
+				for range snapshot.All() {
+					count++
+				}
+				assert.Equal(t, snapshot.Len(), count)
+				_, err := ranger.ContainingNetworks(net.IPv4(10, 0, byte(j), 1))
+				assert.NoError(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, base+256, ranger.Len())
+}
@@ -0,0 +1,68 @@
+package special
+
+// registry is the IANA IPv4 and IPv6 Special-Purpose Address Registries,
+// with the class of each block.  Flags are, in order, source, destination,
+// forwardable, globally reachable and reserved-by-protocol.  Globally
+// reachable is "N/A" for blocks the registry does not tell, such as those
+// embedding IPv4 addresses that decide it.
+//
+// Rangers look IPv4-mapped addresses up as the IPv4 addresses they map, so
+// the IPv4-mapped Address block, ::ffff:0:0/96, is left out.
+var registry = []struct {
+	block     string
+	name      string
+	rfcs      []string
+	allocated string
+	flags     [5]string
+	class     Class
+}{
+	{"0.0.0.0/8", "This network", []string{"RFC791"}, "1981-09", [5]string{"True", "False", "False", "False", "True"}, Reserved},
+	{"0.0.0.0/32", "This host on this network", []string{"RFC1122"}, "1981-09", [5]string{"True", "False", "False", "False", "True"}, Unspecified},
+	{"10.0.0.0/8", "Private-Use", []string{"RFC1918"}, "1996-02", [5]string{"True", "True", "True", "False", "False"}, Private},
+	{"100.64.0.0/10", "Shared Address Space", []string{"RFC6598"}, "2012-04", [5]string{"True", "True", "True", "False", "False"}, Shared},
+	{"127.0.0.0/8", "Loopback", []string{"RFC1122"}, "1981-09", [5]string{"False", "False", "False", "False", "True"}, Loopback},
+	{"169.254.0.0/16", "Link Local", []string{"RFC3927"}, "2005-05", [5]string{"True", "True", "False", "False", "True"}, LinkLocal},
+	{"172.16.0.0/12", "Private-Use", []string{"RFC1918"}, "1996-02", [5]string{"True", "True", "True", "False", "False"}, Private},
+	{"192.0.0.0/24", "IETF Protocol Assignments", []string{"RFC6890"}, "2010-01", [5]string{"False", "False", "False", "False", "False"}, Reserved},
+	{"192.0.0.0/29", "IPv4 Service Continuity Prefix", []string{"RFC7335"}, "2011-06", [5]string{"True", "True", "True", "False", "False"}, Translation},
+	{"192.0.0.8/32", "IPv4 dummy address", []string{"RFC7600"}, "2015-03", [5]string{"True", "False", "False", "False", "False"}, Reserved},
+	{"192.0.0.9/32", "Port Control Protocol Anycast", []string{"RFC7723"}, "2015-10", [5]string{"True", "True", "True", "True", "False"}, Public},
+	{"192.0.0.10/32", "Traversal Using Relays around NAT Anycast", []string{"RFC8155"}, "2017-02", [5]string{"True", "True", "True", "True", "False"}, Public},
+	{"192.0.0.170/32", "NAT64/DNS64 Discovery", []string{"RFC8880", "RFC7050"}, "2013-02", [5]string{"False", "False", "False", "False", "True"}, Reserved},
+	{"192.0.0.171/32", "NAT64/DNS64 Discovery", []string{"RFC8880", "RFC7050"}, "2013-02", [5]string{"False", "False", "False", "False", "True"}, Reserved},
+	{"192.0.2.0/24", "Documentation (TEST-NET-1)", []string{"RFC5737"}, "2010-01", [5]string{"False", "False", "False", "False", "False"}, Documentation},
+	{"192.31.196.0/24", "AS112-v4", []string{"RFC7535"}, "2014-12", [5]string{"True", "True", "True", "True", "False"}, Public},
+	{"192.52.193.0/24", "AMT", []string{"RFC7450"}, "2014-12", [5]string{"True", "True", "True", "True", "False"}, Public},
+	{"192.88.99.0/24", "Deprecated (6to4 Relay Anycast)", []string{"RFC7526"}, "2001-06", [5]string{"False", "False", "False", "N/A", "False"}, Reserved},
+	{"192.88.99.2/32", "6a44-relay anycast address", []string{"RFC6751"}, "2012-10", [5]string{"True", "True", "True", "False", "False"}, Translation},
+	{"192.168.0.0/16", "Private-Use", []string{"RFC1918"}, "1996-02", [5]string{"True", "True", "True", "False", "False"}, Private},
+	{"192.175.48.0/24", "Direct Delegation AS112 Service", []string{"RFC7534"}, "1996-01", [5]string{"True", "True", "True", "True", "False"}, Public},
+	{"198.18.0.0/15", "Benchmarking", []string{"RFC2544"}, "1999-03", [5]string{"True", "True", "True", "False", "False"}, Benchmarking},
+	{"198.51.100.0/24", "Documentation (TEST-NET-2)", []string{"RFC5737"}, "2010-01", [5]string{"False", "False", "False", "False", "False"}, Documentation},
+	{"203.0.113.0/24", "Documentation (TEST-NET-3)", []string{"RFC5737"}, "2010-01", [5]string{"False", "False", "False", "False", "False"}, Documentation},
+	{"240.0.0.0/4", "Reserved", []string{"RFC1112"}, "1989-08", [5]string{"False", "False", "False", "False", "True"}, Reserved},
+	{"255.255.255.255/32", "Limited Broadcast", []string{"RFC8190", "RFC919"}, "1984-10", [5]string{"False", "True", "False", "False", "True"}, Broadcast},
+
+	{"::/128", "Unspecified Address", []string{"RFC4291"}, "2006-02", [5]string{"True", "False", "False", "False", "True"}, Unspecified},
+	{"::1/128", "Loopback Address", []string{"RFC4291"}, "2006-02", [5]string{"False", "False", "False", "False", "True"}, Loopback},
+	{"64:ff9b::/96", "IPv4-IPv6 Translat.", []string{"RFC6052"}, "2010-10", [5]string{"True", "True", "True", "True", "False"}, Translation},
+	{"64:ff9b:1::/48", "IPv4-IPv6 Translat.", []string{"RFC8215"}, "2017-06", [5]string{"True", "True", "True", "False", "False"}, Translation},
+	{"100::/64", "Discard-Only Address Block", []string{"RFC6666"}, "2012-06", [5]string{"True", "True", "True", "False", "False"}, Reserved},
+	{"2001::/23", "IETF Protocol Assignments", []string{"RFC2928"}, "2000-09", [5]string{"False", "False", "False", "False", "False"}, Reserved},
+	{"2001::/32", "TEREDO", []string{"RFC4380", "RFC8190"}, "2006-01", [5]string{"True", "True", "True", "N/A", "False"}, Translation},
+	{"2001:1::1/128", "Port Control Protocol Anycast", []string{"RFC7723"}, "2015-10", [5]string{"True", "True", "True", "True", "False"}, Public},
+	{"2001:1::2/128", "Traversal Using Relays around NAT Anycast", []string{"RFC8155"}, "2017-02", [5]string{"True", "True", "True", "True", "False"}, Public},
+	{"2001:2::/48", "Benchmarking", []string{"RFC5180"}, "2008-04", [5]string{"True", "True", "True", "False", "False"}, Benchmarking},
+	{"2001:3::/32", "AMT", []string{"RFC7450"}, "2014-12", [5]string{"True", "True", "True", "True", "False"}, Public},
+	{"2001:4:112::/48", "AS112-v6", []string{"RFC7535"}, "2014-12", [5]string{"True", "True", "True", "True", "False"}, Public},
+	{"2001:10::/28", "Deprecated (previously ORCHID)", []string{"RFC4843"}, "2007-03", [5]string{"False", "False", "False", "N/A", "False"}, Reserved},
+	{"2001:20::/28", "ORCHIDv2", []string{"RFC7343"}, "2014-07", [5]string{"True", "True", "True", "True", "False"}, Public},
+	{"2001:30::/28", "Drone Remote ID Protocol Entity Tags (DETs) Prefix", []string{"RFC9374"}, "2022-12", [5]string{"True", "True", "True", "True", "False"}, Public},
+	{"2001:db8::/32", "Documentation", []string{"RFC3849"}, "2004-07", [5]string{"False", "False", "False", "False", "False"}, Documentation},
+	{"2002::/16", "6to4", []string{"RFC3056"}, "2001-02", [5]string{"True", "True", "True", "N/A", "False"}, Translation},
+	{"2620:4f:8000::/48", "Direct Delegation AS112 Service", []string{"RFC7534"}, "2011-05", [5]string{"True", "True", "True", "True", "False"}, Public},
+	{"3fff::/20", "Documentation", []string{"RFC9637"}, "2024-07", [5]string{"False", "False", "False", "False", "False"}, Documentation},
+	{"5f00::/16", "Segment Routing (SRv6) SIDs", []string{"RFC9602"}, "2024-04", [5]string{"True", "True", "True", "False", "False"}, Reserved},
+	{"fc00::/7", "Unique-Local", []string{"RFC4193", "RFC8190"}, "2005-10", [5]string{"True", "True", "True", "False", "False"}, Private},
+	{"fe80::/10", "Link-Local Unicast", []string{"RFC4291"}, "2006-02", [5]string{"True", "True", "False", "False", "True"}, LinkLocal},
+}
@@ -0,0 +1,152 @@
+/*
+Package special provides the IANA IPv4 and IPv6 Special-Purpose Address
+Registries (RFC 6890 and its updates) as a pre-built, read-only
+cidranger.Ranger, to tell private, loopback, link-local, documentation,
+shared (CGNAT), reserved and other special addresses apart from public ones:
+
+	special.Classify(net.ParseIP("100.64.1.1")) // shared
+	special.IsBogon(net.ParseIP("192.168.1.1")) // true
+
+Multicast blocks are not part of the registries, Classify and IsBogon tell
+them by address.
+*/
+package special
+
+import (
+	"net"
+	"net/netip"
+	"sync"
+
+	"github.com/yl2chen/cidranger"
+	rnet "github.com/yl2chen/cidranger/net"
+)
+
+// Class is the purpose of an address.
+type Class string
+
+// Classes of addresses.
+const (
+	Public        Class = "public"
+	Unspecified   Class = "unspecified"
+	Loopback      Class = "loopback"
+	Private       Class = "private"
+	Shared        Class = "shared"
+	LinkLocal     Class = "link-local"
+	Multicast     Class = "multicast"
+	Documentation Class = "documentation"
+	Benchmarking  Class = "benchmarking"
+	Broadcast     Class = "broadcast"
+	Translation   Class = "translation"
+	Reserved      Class = "reserved"
+)
+
+// Entry is a cidranger.RangerEntry for a block of a special-purpose address
+// registry.
+type Entry struct {
+	ipNet net.IPNet
+	Name  string
+	// RFCs are the documents defining the block, such as RFC1918.
+	RFCs []string
+	// Allocated is the year and month of allocation of the block, as
+	// 2006-01.
+	Allocated string
+	// Class is the purpose of the block, Public for blocks reachable like
+	// any public address, such as anycast services.
+	Class Class
+
+	Source             bool
+	Destination        bool
+	Forwardable        bool
+	GloballyReachable  bool
+	ReservedByProtocol bool
+	// reachabilityUnknown is set for blocks whose global reachability the
+	// registry does not tell.
+	reachabilityUnknown bool
+}
+
+// Network returns the network of entry.
+func (e *Entry) Network() net.IPNet {
+	return e.ipNet
+}
+
+var (
+	rangerOnce sync.Once
+	ranger     cidranger.Ranger
+)
+
+// Ranger returns the read-only ranger of the registry blocks, shared by
+// every caller.
+func Ranger() cidranger.Ranger {
+	rangerOnce.Do(func() {
+		snapshots := cidranger.NewSnapshotPCTrieRanger()
+		batch := cidranger.NewBatch()
+		for _, block := range registry {
+			prefix := netip.MustParsePrefix(block.block)
+			batch.Insert(&Entry{
+				ipNet:               rnet.NewNetworkFromPrefix(prefix).IPNet,
+				Name:                block.name,
+				RFCs:                block.rfcs,
+				Allocated:           block.allocated,
+				Class:               block.class,
+				Source:              block.flags[0] == "True",
+				Destination:         block.flags[1] == "True",
+				Forwardable:         block.flags[2] == "True",
+				GloballyReachable:   block.flags[3] == "True",
+				ReservedByProtocol:  block.flags[4] == "True",
+				reachabilityUnknown: block.flags[3] == "N/A",
+			})
+		}
+		if err := batch.Commit(snapshots); err != nil {
+			panic(err)
+		}
+		ranger = snapshots.Snapshot()
+	})
+	return ranger
+}
+
+// Lookup returns the registry blocks containing ip, from the least to the
+// most specific.
+func Lookup(ip net.IP) ([]*Entry, error) {
+	entries, err := Ranger().ContainingNetworks(ip)
+	if err != nil {
+		return nil, err
+	}
+	blocks := make([]*Entry, 0, len(entries))
+	for _, entry := range entries {
+		blocks = append(blocks, entry.(*Entry))
+	}
+	return blocks, nil
+}
+
+// Classify returns the class of ip, that of the most specific block holding
+// it, Multicast for multicast addresses and Public for any other address.
+// It returns an empty Class for invalid IPs.
+func Classify(ip net.IP) Class {
+	blocks, err := Lookup(ip)
+	if err != nil {
+		return ""
+	}
+	if len(blocks) > 0 {
+		return blocks[len(blocks)-1].Class
+	}
+	if ip.IsMulticast() {
+		return Multicast
+	}
+	return Public
+}
+
+// IsBogon returns whether ip is not expected on the public Internet: it is
+// invalid, multicast, or the most specific block holding it is not globally
+// reachable.  Addresses of blocks whose reachability depends on the IPv4
+// address they embed, such as 6to4 and Teredo ones, are not bogons.
+func IsBogon(ip net.IP) bool {
+	blocks, err := Lookup(ip)
+	if err != nil {
+		return true
+	}
+	if len(blocks) > 0 {
+		block := blocks[len(blocks)-1]
+		return !block.GloballyReachable && !block.reachabilityUnknown
+	}
+	return ip.IsMulticast()
+}
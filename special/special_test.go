@@ -0,0 +1,115 @@
+package special
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yl2chen/cidranger"
+)
+
+func TestRanger(t *testing.T) {
+	ranger := Ranger()
+	assert.Equal(t, len(registry), ranger.Len())
+	assert.Same(t, ranger, Ranger())
+	assert.Equal(t, cidranger.ErrReadOnlyRanger, ranger.InsertPrefix(netip.MustParsePrefix("1.0.0.0/8")))
+
+	entry, found, err := ranger.GetPrefix(netip.MustParsePrefix("10.0.0.0/8"))
+	assert.NoError(t, err)
+	if assert.True(t, found) {
+		block := entry.(*Entry)
+		assert.Equal(t, "Private-Use", block.Name)
+		assert.Equal(t, []string{"RFC1918"}, block.RFCs)
+		assert.Equal(t, "1996-02", block.Allocated)
+		assert.True(t, block.Source)
+		assert.True(t, block.Destination)
+		assert.True(t, block.Forwardable)
+		assert.False(t, block.GloballyReachable)
+		assert.False(t, block.ReservedByProtocol)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	blocks, err := Lookup(net.ParseIP("192.0.0.9"))
+	assert.NoError(t, err)
+	if assert.Len(t, blocks, 2) {
+		assert.Equal(t, "IETF Protocol Assignments", blocks[0].Name)
+		assert.Equal(t, "Port Control Protocol Anycast", blocks[1].Name)
+		assert.True(t, blocks[1].GloballyReachable)
+	}
+	blocks, err = Lookup(net.ParseIP("8.8.8.8"))
+	assert.NoError(t, err)
+	assert.Empty(t, blocks)
+	_, err = Lookup(nil)
+	assert.Error(t, err)
+}
+
+func TestClassify(t *testing.T) {
+	cases := map[string]Class{
+		"8.8.8.8":            Public,
+		"0.0.0.0":            Unspecified,
+		"0.1.2.3":            Reserved,
+		"127.0.0.1":          Loopback,
+		"10.1.2.3":           Private,
+		"172.31.255.255":     Private,
+		"192.168.1.1":        Private,
+		"::ffff:192.168.1.1": Private,
+		"100.64.0.1":         Shared,
+		"169.254.169.254":    LinkLocal,
+		"224.0.0.251":        Multicast,
+		"192.0.2.1":          Documentation,
+		"198.51.100.1":       Documentation,
+		"203.0.113.1":        Documentation,
+		"198.19.0.1":         Benchmarking,
+		"255.255.255.255":    Broadcast,
+		"240.0.0.1":          Reserved,
+		"192.0.0.9":          Public,
+		"192.0.0.1":          Translation,
+		"::":                 Unspecified,
+		"::1":                Loopback,
+		"2001:4860::8888":    Public,
+		"fd00::1":            Private,
+		"fe80::1":            LinkLocal,
+		"ff02::1":            Multicast,
+		"2001:db8::1":        Documentation,
+		"3fff::1":            Documentation,
+		"2001:2::1":          Benchmarking,
+		"2002:c000:201::1":   Translation,
+		"2001:0:4136::1":     Translation,
+		"64:ff9b::808:808":   Translation,
+		"2001:1::1":          Public,
+		"2001:100::1":        Reserved,
+		"100::1":             Reserved,
+	}
+	for ip, class := range cases {
+		assert.Equal(t, class, Classify(net.ParseIP(ip)), ip)
+	}
+	assert.Equal(t, Class(""), Classify(nil))
+}
+
+func TestIsBogon(t *testing.T) {
+	cases := map[string]bool{
+		"8.8.8.8":          false,
+		"10.0.0.1":         true,
+		"100.64.0.1":       true,
+		"127.0.0.1":        true,
+		"224.0.0.1":        true,
+		"240.0.0.1":        true,
+		"192.0.2.1":        true,
+		"192.0.0.9":        false,
+		"192.0.0.1":        true,
+		"192.31.196.1":     false,
+		"2001:4860::8888":  false,
+		"2001:db8::1":      true,
+		"fd00::1":          true,
+		"ff02::1":          true,
+		"2002:c000:201::1": false,
+		"2001:0:4136::1":   false,
+		"64:ff9b::808:808": false,
+	}
+	for ip, bogon := range cases {
+		assert.Equal(t, bogon, IsBogon(net.ParseIP(ip)), ip)
+	}
+	assert.True(t, IsBogon(nil))
+}
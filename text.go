@@ -0,0 +1,250 @@
+package cidranger
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"net"
+	"strings"
+	"unicode/utf8"
+)
+
+// The JSON format is an array of objects, one per line in address order:
+//
+//	[
+//	{"network":"10.0.0.0/8","value":"private"},
+//	{"network":"192.168.0.0/16"}
+//	]
+//
+// and the text format holds one entry per line, also in address order, the
+// value separated from the network by a tab:
+//
+//	10.0.0.0/8	private
+//	192.168.0.0/16
+//
+// Values are the codec encoding of entries as text, omitted when empty, so
+// the codecs used with these formats must encode values as UTF-8 text, and
+// without line breaks for the text format.  Blank lines and lines starting
+// with '#', once trimmed, are ignored when reading text.
+
+// ErrInvalidTextValue is returned upon writing a value the JSON or text format
+// can not hold, such as invalid UTF-8.
+var ErrInvalidTextValue = fmt.Errorf("Invalid text value")
+
+type jsonEntry struct {
+	Network string  `json:"network"`
+	Value   *string `json:"value,omitempty"`
+}
+
+// WriteJSON writes the entries of ranger to w as JSON in address order, using
+// codec to encode their payload.
+func WriteJSON(w io.Writer, ranger Ranger, codec Codec[RangerEntry]) error {
+	return writeJSON(w, rangerEntries(ranger), codec)
+}
+
+// ReadJSON inserts the entries read from JSON written by WriteJSON into
+// ranger, using codec to decode them.  Entries are inserted all at once, or
+// not at all upon error.
+func ReadJSON(r io.Reader, ranger Ranger, codec Codec[RangerEntry]) error {
+	entries, err := readJSON(r, codec)
+	if err != nil {
+		return err
+	}
+	return insertEntries(ranger, entries)
+}
+
+// WriteText writes the entries of ranger to w as text in address order, using
+// codec to encode their payload.
+func WriteText(w io.Writer, ranger Ranger, codec Codec[RangerEntry]) error {
+	return writeText(w, rangerEntries(ranger), codec)
+}
+
+// ReadText inserts the entries read from text written by WriteText into
+// ranger, using codec to decode them.  Entries are inserted all at once, or
+// not at all upon error.
+func ReadText(r io.Reader, ranger Ranger, codec Codec[RangerEntry]) error {
+	entries, err := readText(r, codec)
+	if err != nil {
+		return err
+	}
+	return insertEntries(ranger, entries)
+}
+
+// WriteTypedJSON writes the entries of ranger to w as JSON in address order,
+// using codec to encode their value.
+func WriteTypedJSON[V any](w io.Writer, ranger TypedRanger[V], codec Codec[V]) error {
+	return writeJSON(w, ranger.All(), codec)
+}
+
+// ReadTypedJSON inserts the entries read from JSON written by WriteTypedJSON
+// into ranger, using codec to decode their value.  Entries are inserted all at
+// once, or not at all upon error.
+func ReadTypedJSON[V any](r io.Reader, ranger TypedRanger[V], codec Codec[V]) error {
+	entries, err := readJSON(r, codec)
+	if err != nil {
+		return err
+	}
+	return insertTypedEntries(ranger, entries)
+}
+
+// WriteTypedText writes the entries of ranger to w as text in address order,
+// using codec to encode their value.
+func WriteTypedText[V any](w io.Writer, ranger TypedRanger[V], codec Codec[V]) error {
+	return writeText(w, ranger.All(), codec)
+}
+
+// ReadTypedText inserts the entries read from text written by WriteTypedText
+// into ranger, using codec to decode their value.  Entries are inserted all at
+// once, or not at all upon error.
+func ReadTypedText[V any](r io.Reader, ranger TypedRanger[V], codec Codec[V]) error {
+	entries, err := readText(r, codec)
+	if err != nil {
+		return err
+	}
+	return insertTypedEntries(ranger, entries)
+}
+
+// rangerEntries returns an iterator over the entries of ranger as Entry(s).
+func rangerEntries(ranger Ranger) iter.Seq[Entry[RangerEntry]] {
+	return func(yield func(Entry[RangerEntry]) bool) {
+		for entry := range ranger.All() {
+			if !yield(Entry[RangerEntry]{Network: entry.Network(), Value: entry}) {
+				return
+			}
+		}
+	}
+}
+
+func insertEntries(ranger Ranger, entries []Entry[RangerEntry]) error {
+	batch := NewBatch()
+	for _, entry := range entries {
+		batch.Insert(entry.Value)
+	}
+	return batch.Commit(ranger)
+}
+
+// insertTypedEntries inserts entries into ranger all at once, as a Batch
+// does for a Ranger.
+func insertTypedEntries[V any](ranger TypedRanger[V], entries []Entry[V]) error {
+	for _, entry := range entries {
+		if err := validateBatchOp(batchOp{network: entry.Network}); err != nil {
+			return err
+		}
+	}
+	type replaced struct {
+		Entry[V]
+		exists bool
+	}
+	return applyOrUndo(entries, func(entry Entry[V]) (replaced, error) {
+		previous, exists, err := ranger.Swap(entry.Network, entry.Value)
+		return replaced{Entry[V]{Network: entry.Network, Value: previous}, exists}, err
+	}, func(r replaced) {
+		if r.exists {
+			ranger.Insert(r.Network, r.Value)
+		} else {
+			ranger.Remove(r.Network)
+		}
+	})
+}
+
+func writeJSON[V any](w io.Writer, entries iter.Seq[Entry[V]], codec Codec[V]) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("[")
+	separator := "\n"
+	for entry := range entries {
+		value, err := codec.MarshalValue(entry.Network, entry.Value)
+		if err != nil {
+			return err
+		}
+		// Invalid UTF-8 would silently be replaced by JSON encoding.
+		if !utf8.Valid(value) {
+			return ErrInvalidTextValue
+		}
+		object := jsonEntry{Network: entry.Network.String()}
+		if len(value) > 0 {
+			text := string(value)
+			object.Value = &text
+		}
+		line, err := json.Marshal(object)
+		if err != nil {
+			return err
+		}
+		bw.WriteString(separator)
+		bw.Write(line)
+		separator = ",\n"
+	}
+	bw.WriteString("\n]\n")
+	return bw.Flush()
+}
+
+func readJSON[V any](r io.Reader, codec Codec[V]) ([]Entry[V], error) {
+	var objects []jsonEntry
+	if err := json.NewDecoder(r).Decode(&objects); err != nil {
+		return nil, err
+	}
+	entries := make([]Entry[V], 0, len(objects))
+	for _, object := range objects {
+		var value string
+		if object.Value != nil {
+			value = *object.Value
+		}
+		entry, err := decodeTextEntry(object.Network, value, codec)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func writeText[V any](w io.Writer, entries iter.Seq[Entry[V]], codec Codec[V]) error {
+	bw := bufio.NewWriter(w)
+	for entry := range entries {
+		value, err := codec.MarshalValue(entry.Network, entry.Value)
+		if err != nil {
+			return err
+		}
+		if strings.ContainsAny(string(value), "\r\n") || !utf8.Valid(value) {
+			return ErrInvalidTextValue
+		}
+		bw.WriteString(entry.Network.String())
+		if len(value) > 0 {
+			bw.WriteByte('\t')
+			bw.Write(value)
+		}
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
+
+func readText[V any](r io.Reader, codec Codec[V]) ([]Entry[V], error) {
+	var entries []Entry[V]
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if trimmed := strings.TrimSpace(text); trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		network, value, _ := strings.Cut(text, "\t")
+		entry, err := decodeTextEntry(strings.TrimSpace(network), value, codec)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+func decodeTextEntry[V any](cidr, value string, codec Codec[V]) (Entry[V], error) {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return Entry[V]{}, ErrInvalidNetworkInput
+	}
+	decoded, err := codec.UnmarshalValue(*network, []byte(value))
+	if err != nil {
+		return Entry[V]{}, err
+	}
+	return Entry[V]{Network: *network, Value: decoded}, nil
+}
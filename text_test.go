@@ -0,0 +1,139 @@
+package cidranger
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONAndTextRoundTrip(t *testing.T) {
+	ranger := NewPCTrieRanger()
+	configureRangerWithAWSRanges(t, ranger)
+	for name, format := range map[string]struct {
+		write func(*bytes.Buffer, Ranger) error
+		read  func(*bytes.Buffer, Ranger) error
+	}{
+		"json": {
+			func(buf *bytes.Buffer, ranger Ranger) error { return WriteJSON(buf, ranger, BasicEntryCodec) },
+			func(buf *bytes.Buffer, ranger Ranger) error { return ReadJSON(buf, ranger, BasicEntryCodec) },
+		},
+		"text": {
+			func(buf *bytes.Buffer, ranger Ranger) error { return WriteText(buf, ranger, BasicEntryCodec) },
+			func(buf *bytes.Buffer, ranger Ranger) error { return ReadText(buf, ranger, BasicEntryCodec) },
+		},
+	} {
+		var dump bytes.Buffer
+		assert.NoError(t, format.write(&dump, ranger), name)
+		expected := dump.String()
+
+		// Dumps do not depend on the implementation nor the insertion order.
+		brute := newBruteRanger()
+		assert.NoError(t, format.read(&dump, brute), name)
+		assert.Equal(t, ranger.Len(), brute.Len(), name)
+		var bruteDump bytes.Buffer
+		assert.NoError(t, format.write(&bruteDump, brute), name)
+		assert.Equal(t, expected, bruteDump.String(), name)
+	}
+}
+
+func TestWriteTypedJSONAndText(t *testing.T) {
+	ranger := NewTypedPCTrieRanger[string]()
+	assert.NoError(t, ranger.InsertPrefix(netip.MustParsePrefix("2001:db8::/32"), "documentation"))
+	assert.NoError(t, ranger.InsertPrefix(netip.MustParsePrefix("192.168.0.0/16"), ""))
+	assert.NoError(t, ranger.InsertPrefix(netip.MustParsePrefix("10.0.0.0/8"), `"private"`))
+
+	var buf bytes.Buffer
+	assert.NoError(t, WriteTypedJSON(&buf, ranger, StringCodec))
+	assert.Equal(t, `[
+{"network":"10.0.0.0/8","value":"\"private\""},
+{"network":"192.168.0.0/16"},
+{"network":"2001:db8::/32","value":"documentation"}
+]
+`, buf.String())
+	decoded := NewTypedPCTrieRanger[string]()
+	assert.NoError(t, ReadTypedJSON(&buf, decoded, StringCodec))
+	value, _, err := decoded.GetPrefix(netip.MustParsePrefix("10.0.0.0/8"))
+	assert.NoError(t, err)
+	assert.Equal(t, `"private"`, value)
+
+	buf.Reset()
+	assert.NoError(t, WriteTypedText(&buf, ranger, StringCodec))
+	assert.Equal(t, "10.0.0.0/8\t\"private\"\n192.168.0.0/16\n2001:db8::/32\tdocumentation\n", buf.String())
+	decoded = NewTypedPCTrieRanger[string]()
+	assert.NoError(t, ReadTypedText(&buf, decoded, StringCodec))
+	assert.Equal(t, 3, decoded.Len())
+
+	// Values must be UTF-8 text, which JSON would not keep otherwise.
+	invalid := NewTypedPCTrieRanger[string]()
+	assert.NoError(t, invalid.InsertPrefix(netip.MustParsePrefix("10.0.0.0/8"), "\xff\xfe"))
+	assert.Equal(t, ErrInvalidTextValue, WriteTypedJSON(&buf, invalid, StringCodec))
+	assert.Equal(t, ErrInvalidTextValue, WriteTypedText(&buf, invalid, StringCodec))
+
+	buf.Reset()
+	assert.NoError(t, WriteTypedJSON(&buf, NewTypedPCTrieRanger[string](), StringCodec))
+	assert.Equal(t, "[\n]\n", buf.String())
+}
+
+func TestJSONAndTextCustomEntries(t *testing.T) {
+	ranger := NewPCTrieRanger()
+	assert.NoError(t, ranger.Insert(&taggedRangerEntry{*parseCIDRUnsafe("10.0.0.0/8"), []string{"feed1", "feed2"}}))
+
+	var buf bytes.Buffer
+	assert.NoError(t, WriteJSON(&buf, ranger, taggedCodec{}))
+	decoded := NewPCTrieRanger()
+	assert.NoError(t, ReadJSON(&buf, decoded, taggedCodec{}))
+	entry, _, err := decoded.Get(*parseCIDRUnsafe("10.0.0.0/8"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"feed1", "feed2"}, entry.(*taggedRangerEntry).tags)
+
+	// Tags are separated by line breaks, which text can not hold.
+	assert.Equal(t, ErrInvalidTextValue, WriteText(&buf, ranger, taggedCodec{}))
+	assert.Equal(t, ErrUnsupportedEntry, WriteText(&buf, ranger, BasicEntryCodec))
+}
+
+func TestReadText(t *testing.T) {
+	ranger := NewTypedPCTrieRanger[string]()
+	input := "# offices\n10.0.0.0/8\tprivate\n\n192.168.1.7/24\thome\tlab\n"
+	assert.NoError(t, ReadTypedText(strings.NewReader(input), ranger, StringCodec))
+	assert.Equal(t, 2, ranger.Len())
+	value, found, err := ranger.GetPrefix(netip.MustParsePrefix("192.168.1.0/24"))
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "home\tlab", value)
+
+	untouched := NewPCTrieRanger()
+	err = ReadText(strings.NewReader("10.0.0.0/8\n10.0.0.0/33\n"), untouched, BasicEntryCodec)
+	assert.True(t, errors.Is(err, ErrInvalidNetworkInput))
+	assert.Contains(t, err.Error(), "line 2")
+	assert.Equal(t, 0, untouched.Len())
+
+	typed := NewTypedPCTrieRanger[string]()
+	assert.NoError(t, typed.InsertPrefix(netip.MustParsePrefix("10.0.0.0/8"), "private"))
+	input = "  # indented comment\n10.0.0.0/8\toffice\n192.168.0.0/16\thome\n10.0.0.0/33\tbroken\n"
+	err = ReadTypedText(strings.NewReader(input), typed, StringCodec)
+	assert.True(t, errors.Is(err, ErrInvalidNetworkInput))
+	assert.Contains(t, err.Error(), "line 4")
+	err = ReadTypedJSON(strings.NewReader(`[{"network":"192.168.0.0/16"},{"network":"10.0.0.0/33"}]`), typed, StringCodec)
+	assert.Error(t, err)
+	assert.Equal(t, 1, typed.Len())
+
+	// Networks are validated before any entry is inserted.
+	err = insertTypedEntries(typed, []Entry[string]{
+		{Network: *parseCIDRUnsafe("10.0.0.0/8"), Value: "office"},
+		{Network: *parseCIDRUnsafe("192.168.0.0/16"), Value: "home"},
+		{Network: net.IPNet{IP: net.ParseIP("172.16.0.0").To4(), Mask: net.CIDRMask(12, 128)}},
+	})
+	assert.Equal(t, ErrInvalidNetworkInput, err)
+	assert.Equal(t, 1, typed.Len())
+	value, _, err = typed.GetPrefix(netip.MustParsePrefix("10.0.0.0/8"))
+	assert.NoError(t, err)
+	assert.Equal(t, "private", value)
+
+	assert.NoError(t, ReadTypedText(strings.NewReader(input[:strings.LastIndex(input, "10.0.0.0/33")]), typed, StringCodec))
+	assert.Equal(t, 2, typed.Len())
+}
@@ -2,7 +2,9 @@ package cidranger
 
 import (
 	"fmt"
+	"iter"
 	"net"
+	"net/netip"
 	"strings"
 
 	rnet "github.com/yl2chen/cidranger/net"
@@ -24,45 +26,66 @@ import (
 // node, decrease the amount of lookups necessary during containment tests.
 //
 // Level compression dictates the amount of direct children of a node by
-// allowing it to handle multiple bits in the path.  The heuristic (based on
-// children population) to decide when the compression and decompression happens
-// is outlined in the prior linked blog, and will be experimented with in more
-// depth in this project in the future.
+// allowing it to handle multiple bits in the path, a node handling k bits has
+// 2^k children indexed by the k bits following its prefix.  The heuristic
+// (based on children population) to decide when the compression and
+// decompression happens is outlined in the prior linked blog: a node is
+// inflated to handle one more bit when at least inflateThreshold percent of
+// the doubled children would be in use, and halved when less than
+// halveThreshold percent of its children are in use.  As entries are only
+// stored at the prefix of a node, a node is never inflated over a child
+// holding an entry, and is halved as needed to insert one within its bits.
 //
 // Note: Can not insert both IPv4 and IPv6 network addresses into the same
 // prefix trie, use versionedRanger wrapper instead.
-//
-// TODO: Implement level-compressed component of the LPC trie.
-type prefixTrie struct {
-	parent   *prefixTrie
-	children []*prefixTrie
+type prefixTrie[V any] struct {
+	parent   *prefixTrie[V]
+	children []*prefixTrie[V]
+
+	// Population of children maintained by setChild, children are exact when
+	// they start right where current trie ends, and full when also without
+	// an entry.
+	numChildren      int
+	numExactChildren int
+	numFullChildren  int
 
 	numBitsSkipped uint
 	numBitsHandled uint
 
-	network rnet.Network
-	entry   RangerEntry
+	network  rnet.Network
+	value    V
+	hasValue bool
 
 	size int // This is only maintained in the root trie.
 }
 
+// Level compression thresholds, in percent of children in use.
+const (
+	inflateThreshold = 75
+	halveThreshold   = 25
+
+	// maxBitsHandled is the most bits a node handles, bounding its children
+	// to 65536.
+	maxBitsHandled = 16
+)
+
 // newPrefixTree creates a new prefixTrie.
-func newPrefixTree(version rnet.IPVersion) Ranger {
+func newPrefixTree[V any](version rnet.IPVersion) TypedRanger[V] {
 	_, rootNet, _ := net.ParseCIDR("0.0.0.0/0")
 	if version == rnet.IPv6 {
 		_, rootNet, _ = net.ParseCIDR("0::0/0")
 	}
-	return &prefixTrie{
-		children:       make([]*prefixTrie, 2, 2),
+	return &prefixTrie[V]{
+		children:       make([]*prefixTrie[V], 2, 2),
 		numBitsSkipped: 0,
 		numBitsHandled: 1,
 		network:        rnet.NewNetwork(*rootNet),
 	}
 }
 
-func newPathprefixTrie(network rnet.Network, numBitsSkipped uint) *prefixTrie {
-	path := &prefixTrie{
-		children:       make([]*prefixTrie, 2, 2),
+func newPathprefixTrie[V any](network rnet.Network, numBitsSkipped uint) *prefixTrie[V] {
+	path := &prefixTrie[V]{
+		children:       make([]*prefixTrie[V], 2, 2),
 		numBitsSkipped: numBitsSkipped,
 		numBitsHandled: 1,
 		network:        network.Masked(int(numBitsSkipped)),
@@ -70,35 +93,140 @@ func newPathprefixTrie(network rnet.Network, numBitsSkipped uint) *prefixTrie {
 	return path
 }
 
-func newEntryTrie(network rnet.Network, entry RangerEntry) *prefixTrie {
+func newEntryTrie[V any](network rnet.Network, value V) *prefixTrie[V] {
 	ones, _ := network.IPNet.Mask.Size()
-	leaf := newPathprefixTrie(network, uint(ones))
-	leaf.entry = entry
+	leaf := newPathprefixTrie[V](network, uint(ones))
+	leaf.value = value
+	leaf.hasValue = true
 	return leaf
 }
 
-// Insert inserts a RangerEntry into prefix trie.
-func (p *prefixTrie) Insert(entry RangerEntry) error {
-	network := entry.Network()
-	sizeIncreased, err := p.insert(rnet.NewNetwork(network), entry)
-	if sizeIncreased {
-		p.size++
+// newInnerTrie returns a node with no entry handling given number of bits
+// for given children, or the lone child itself if there is only one, or nil
+// if there are none.
+func newInnerTrie[V any](children []*prefixTrie[V], numBitsSkipped, numBitsHandled uint) *prefixTrie[V] {
+	var lone *prefixTrie[V]
+	count := 0
+	for _, child := range children {
+		if child != nil {
+			lone = child
+			count++
+		}
+	}
+	if count <= 1 {
+		return lone
+	}
+	inner := newPathprefixTrie[V](lone.network, numBitsSkipped)
+	inner.numBitsHandled = numBitsHandled
+	inner.children = make([]*prefixTrie[V], len(children))
+	for i, child := range children {
+		if child != nil {
+			inner.setChild(uint32(i), child)
+		}
 	}
-	return err
+	return inner
 }
 
-// Remove removes RangerEntry identified by given network from trie.
-func (p *prefixTrie) Remove(network net.IPNet) (RangerEntry, error) {
-	entry, err := p.remove(rnet.NewNetwork(network))
-	if entry != nil {
-		p.size--
+// newCanonicalNetwork returns the Network of ipNet with its host bits
+// cleared, so that 10.1.2.3/16 is stored and found as 10.1.0.0/16.
+func newCanonicalNetwork(ipNet net.IPNet) rnet.Network {
+	network := rnet.NewNetwork(ipNet)
+	ones, bits := ipNet.Mask.Size()
+	if network.Number == nil || bits != len(network.Number)*rnet.BitsPerUint32 {
+		return network
+	}
+	return network.Masked(ones)
+}
+
+// Insert inserts value for given network into prefix trie.
+func (p *prefixTrie[V]) Insert(network net.IPNet, value V) error {
+	return p.insertNetwork(newCanonicalNetwork(network), value)
+}
+
+// InsertPrefix inserts value for given prefix into prefix trie.
+func (p *prefixTrie[V]) InsertPrefix(prefix netip.Prefix, value V) error {
+	network := rnet.NewNetworkFromPrefix(prefix)
+	if network.Number == nil {
+		return ErrInvalidNetworkInput
+	}
+	return p.insertNetwork(network, value)
+}
+
+// Upsert stores the value returned by fn for given network, fn is passed the
+// value currently stored for the network and whether there is one.
+func (p *prefixTrie[V]) Upsert(network net.IPNet, fn func(old V, exists bool) V) error {
+	return p.upsertNetwork(newCanonicalNetwork(network), fn)
+}
+
+// Swap inserts value for given network, returning the value it replaced and
+// whether there was one.
+func (p *prefixTrie[V]) Swap(network net.IPNet, value V) (V, bool, error) {
+	var previous V
+	var replaced bool
+	err := p.Upsert(network, func(old V, exists bool) V {
+		previous, replaced = old, exists
+		return value
+	})
+	return previous, replaced, err
+}
+
+// Remove removes the value identified by given network from trie.
+func (p *prefixTrie[V]) Remove(network net.IPNet) (V, bool, error) {
+	return p.removeNetwork(newCanonicalNetwork(network), nil)
+}
+
+// RemovePrefix removes the value identified by given prefix from trie.
+func (p *prefixTrie[V]) RemovePrefix(prefix netip.Prefix) (V, bool, error) {
+	network := rnet.NewNetworkFromPrefix(prefix)
+	if network.Number == nil {
+		var zero V
+		return zero, false, ErrInvalidNetworkInput
+	}
+	return p.removeNetwork(network, nil)
+}
+
+// RemoveIf removes the value identified by given network from trie only if fn
+// returns true for it, returning the value and whether it was removed.
+func (p *prefixTrie[V]) RemoveIf(network net.IPNet, fn func(value V) bool) (V, bool, error) {
+	return p.removeNetwork(newCanonicalNetwork(network), fn)
+}
+
+// RemoveCovered removes every value whose network is covered by given network
+// from trie, detaching the covered subtree at once, and returns the removed
+// Entry(s) in address order.
+func (p *prefixTrie[V]) RemoveCovered(network net.IPNet) ([]Entry[V], error) {
+	return p.removeCovered(newCanonicalNetwork(network))
+}
+
+// Clear removes every value from trie.
+func (p *prefixTrie[V]) Clear() {
+	var zero V
+	p.setChildren(make([]*prefixTrie[V], 2, 2), 1)
+	p.value = zero
+	p.hasValue = false
+	p.size = 0
+}
+
+// Get returns the value stored for exactly given network, and whether it
+// was found.
+func (p *prefixTrie[V]) Get(network net.IPNet) (V, bool, error) {
+	return p.get(newCanonicalNetwork(network))
+}
+
+// GetPrefix returns the value stored for exactly given prefix, and whether it
+// was found.
+func (p *prefixTrie[V]) GetPrefix(prefix netip.Prefix) (V, bool, error) {
+	network := rnet.NewNetworkFromPrefix(prefix)
+	if network.Number == nil {
+		var zero V
+		return zero, false, ErrInvalidNetworkInput
 	}
-	return entry, err
+	return p.get(network)
 }
 
 // Contains returns boolean indicating whether given ip is contained in any
 // of the inserted networks.
-func (p *prefixTrie) Contains(ip net.IP) (bool, error) {
+func (p *prefixTrie[V]) Contains(ip net.IP) (bool, error) {
 	nn := rnet.NewNetworkNumber(ip)
 	if nn == nil {
 		return false, ErrInvalidNetworkNumberInput
@@ -106,9 +234,9 @@ func (p *prefixTrie) Contains(ip net.IP) (bool, error) {
 	return p.contains(nn)
 }
 
-// ContainingNetworks returns the list of RangerEntry(s) the given ip is
+// ContainingNetworks returns the list of Entry(s) the given ip is
 // contained in in ascending prefix order.
-func (p *prefixTrie) ContainingNetworks(ip net.IP) ([]RangerEntry, error) {
+func (p *prefixTrie[V]) ContainingNetworks(ip net.IP) ([]Entry[V], error) {
 	nn := rnet.NewNetworkNumber(ip)
 	if nn == nil {
 		return nil, ErrInvalidNetworkNumberInput
@@ -116,36 +244,193 @@ func (p *prefixTrie) ContainingNetworks(ip net.IP) ([]RangerEntry, error) {
 	return p.containingNetworks(nn)
 }
 
-// CoveredNetworks returns the list of RangerEntry(s) the given ipnet
+// ContainsAddr returns boolean indicating whether given addr is contained in
+// any of the inserted networks, without allocating.
+func (p *prefixTrie[V]) ContainsAddr(addr netip.Addr) (bool, error) {
+	var buf [rnet.IPv6Uint32Count]uint32
+	nn := rnet.AppendNetworkNumber(buf[:0], addr)
+	if len(nn) == 0 {
+		return false, ErrInvalidNetworkNumberInput
+	}
+	return p.contains(nn)
+}
+
+// ContainingPrefixes returns the list of Entry(s) the given addr is
+// contained in in ascending prefix order.
+func (p *prefixTrie[V]) ContainingPrefixes(addr netip.Addr) ([]Entry[V], error) {
+	var buf [rnet.IPv6Uint32Count]uint32
+	nn := rnet.AppendNetworkNumber(buf[:0], addr)
+	if len(nn) == 0 {
+		return nil, ErrInvalidNetworkNumberInput
+	}
+	return p.containingNetworks(nn)
+}
+
+// LongestMatch returns the most specific Entry containing given ip, and
+// whether such an entry was found, without allocating.
+func (p *prefixTrie[V]) LongestMatch(ip net.IP) (Entry[V], bool, error) {
+	addr, _ := netip.AddrFromSlice(ip)
+	return p.LongestMatchAddr(addr)
+}
+
+// LongestMatchAddr returns the most specific Entry containing given addr, and
+// whether such an entry was found, without allocating.
+func (p *prefixTrie[V]) LongestMatchAddr(addr netip.Addr) (Entry[V], bool, error) {
+	var buf [rnet.IPv6Uint32Count]uint32
+	nn := rnet.AppendNetworkNumber(buf[:0], addr)
+	if len(nn) == 0 {
+		return Entry[V]{}, false, ErrInvalidNetworkNumberInput
+	}
+	return p.match(nn, true)
+}
+
+// ShortestMatch returns the least specific Entry containing given ip, and
+// whether such an entry was found, without allocating.
+func (p *prefixTrie[V]) ShortestMatch(ip net.IP) (Entry[V], bool, error) {
+	addr, _ := netip.AddrFromSlice(ip)
+	return p.ShortestMatchAddr(addr)
+}
+
+// ShortestMatchAddr returns the least specific Entry containing given addr,
+// and whether such an entry was found, without allocating.
+func (p *prefixTrie[V]) ShortestMatchAddr(addr netip.Addr) (Entry[V], bool, error) {
+	var buf [rnet.IPv6Uint32Count]uint32
+	nn := rnet.AppendNetworkNumber(buf[:0], addr)
+	if len(nn) == 0 {
+		return Entry[V]{}, false, ErrInvalidNetworkNumberInput
+	}
+	return p.match(nn, false)
+}
+
+// CoveredNetworks returns the list of Entry(s) the given ipnet
 // covers.  That is, the networks that are completely subsumed by the
 // specified network.
-func (p *prefixTrie) CoveredNetworks(network net.IPNet) ([]RangerEntry, error) {
+func (p *prefixTrie[V]) CoveredNetworks(network net.IPNet) ([]Entry[V], error) {
 	net := rnet.NewNetwork(network)
 	return p.coveredNetworks(net)
 }
 
+// CoveredPrefixes returns the list of Entry(s) the given prefix covers.
+func (p *prefixTrie[V]) CoveredPrefixes(prefix netip.Prefix) ([]Entry[V], error) {
+	network := rnet.NewNetworkFromPrefix(prefix)
+	if network.Number == nil {
+		return nil, ErrInvalidNetworkInput
+	}
+	return p.coveredNetworks(network)
+}
+
+// CoveringNetworks returns the list of Entry(s) that cover the given ipnet
+// in ascending prefix order.  That is, the networks that completely subsume
+// the specified network.
+func (p *prefixTrie[V]) CoveringNetworks(network net.IPNet) ([]Entry[V], error) {
+	return p.coveringNetworks(rnet.NewNetwork(network))
+}
+
+// CoveringPrefixes returns the list of Entry(s) that cover the given prefix
+// in ascending prefix order.
+func (p *prefixTrie[V]) CoveringPrefixes(prefix netip.Prefix) ([]Entry[V], error) {
+	network := rnet.NewNetworkFromPrefix(prefix)
+	if network.Number == nil {
+		return nil, ErrInvalidNetworkInput
+	}
+	return p.coveringNetworks(network)
+}
+
+// OverlappingNetworks returns the list of Entry(s) that either cover or are
+// covered by the given ipnet, the covering networks come first in ascending
+// prefix order.
+func (p *prefixTrie[V]) OverlappingNetworks(network net.IPNet) ([]Entry[V], error) {
+	return p.overlappingNetworks(rnet.NewNetwork(network))
+}
+
+// OverlappingPrefixes returns the list of Entry(s) that either cover or are
+// covered by the given prefix.
+func (p *prefixTrie[V]) OverlappingPrefixes(prefix netip.Prefix) ([]Entry[V], error) {
+	network := rnet.NewNetworkFromPrefix(prefix)
+	if network.Number == nil {
+		return nil, ErrInvalidNetworkInput
+	}
+	return p.overlappingNetworks(network)
+}
+
+// Walk calls fn for every entry in the trie in address order, a network
+// before the networks it covers, until fn returns false.
+func (p *prefixTrie[V]) Walk(fn func(Entry[V]) bool) {
+	p.walk(fn)
+}
+
+// All returns an iterator over every entry in the trie in the same order as
+// Walk.
+func (p *prefixTrie[V]) All() iter.Seq[Entry[V]] {
+	return func(yield func(Entry[V]) bool) {
+		p.walk(yield)
+	}
+}
+
+// Backward returns an iterator over every entry in the trie in the reverse
+// order of Walk.
+func (p *prefixTrie[V]) Backward() iter.Seq[Entry[V]] {
+	return func(yield func(Entry[V]) bool) {
+		p.walkBackward(yield)
+	}
+}
+
+// Next returns the first entry after given network in address order, and
+// whether there is one.  The network itself does not need to be inserted.
+func (p *prefixTrie[V]) Next(network net.IPNet) (Entry[V], bool, error) {
+	node, err := p.next(rnet.NewNetwork(network))
+	if err != nil || node == nil {
+		return Entry[V]{}, false, err
+	}
+	return node.toEntry(), true, nil
+}
+
+// Prev returns the last entry before given network in address order, and
+// whether there is one.  The network itself does not need to be inserted.
+func (p *prefixTrie[V]) Prev(network net.IPNet) (Entry[V], bool, error) {
+	node, err := p.prev(rnet.NewNetwork(network))
+	if err != nil || node == nil {
+		return Entry[V]{}, false, err
+	}
+	return node.toEntry(), true, nil
+}
+
+// EntriesBetween returns the list of Entry(s) overlapping the address range
+// from start to end inclusive, in address order.
+func (p *prefixTrie[V]) EntriesBetween(start, end net.IP) ([]Entry[V], error) {
+	startNumber, endNumber := rnet.NewNetworkNumber(start), rnet.NewNetworkNumber(end)
+	if startNumber == nil || endNumber == nil || len(startNumber) != len(endNumber) {
+		return nil, ErrInvalidNetworkNumberInput
+	}
+	var results []Entry[V]
+	p.entriesBetween(startNumber, endNumber, func(entry Entry[V]) {
+		results = append(results, entry)
+	})
+	return results, nil
+}
+
 // Len returns number of networks in ranger.
-func (p *prefixTrie) Len() int {
+func (p *prefixTrie[V]) Len() int {
 	return p.size
 }
 
 // String returns string representation of trie, mainly for visualization and
 // debugging.
-func (p *prefixTrie) String() string {
+func (p *prefixTrie[V]) String() string {
 	children := []string{}
 	padding := strings.Repeat("| ", p.level()+1)
 	for bits, child := range p.children {
 		if child == nil {
 			continue
 		}
-		childStr := fmt.Sprintf("\n%s%d--> %s", padding, bits, child.String())
+		childStr := fmt.Sprintf("\n%s%0*b--> %s", padding, p.numBitsHandled, bits, child.String())
 		children = append(children, childStr)
 	}
 	return fmt.Sprintf("%s (target_pos:%d:has_entry:%t)%s", p.network,
 		p.targetBitPosition(), p.hasEntry(), strings.Join(children, ""))
 }
 
-func (p *prefixTrie) contains(number rnet.NetworkNumber) (bool, error) {
+func (p *prefixTrie[V]) contains(number rnet.NetworkNumber) (bool, error) {
 	if !p.network.Contains(number) {
 		return false, nil
 	}
@@ -155,7 +440,7 @@ func (p *prefixTrie) contains(number rnet.NetworkNumber) (bool, error) {
 	if p.targetBitPosition() < 0 {
 		return false, nil
 	}
-	bit, err := p.targetBitFromIP(number)
+	bit, err := p.childIndex(number)
 	if err != nil {
 		return false, err
 	}
@@ -166,18 +451,18 @@ func (p *prefixTrie) contains(number rnet.NetworkNumber) (bool, error) {
 	return false, nil
 }
 
-func (p *prefixTrie) containingNetworks(number rnet.NetworkNumber) ([]RangerEntry, error) {
-	results := []RangerEntry{}
+func (p *prefixTrie[V]) containingNetworks(number rnet.NetworkNumber) ([]Entry[V], error) {
+	results := []Entry[V]{}
 	if !p.network.Contains(number) {
 		return results, nil
 	}
 	if p.hasEntry() {
-		results = []RangerEntry{p.entry}
+		results = []Entry[V]{p.toEntry()}
 	}
 	if p.targetBitPosition() < 0 {
 		return results, nil
 	}
-	bit, err := p.targetBitFromIP(number)
+	bit, err := p.childIndex(number)
 	if err != nil {
 		return nil, err
 	}
@@ -198,33 +483,276 @@ func (p *prefixTrie) containingNetworks(number rnet.NetworkNumber) ([]RangerEntr
 	return results, nil
 }
 
-func (p *prefixTrie) coveredNetworks(network rnet.Network) ([]RangerEntry, error) {
-	var results []RangerEntry
+// match walks down the trie along given number once, returning the deepest
+// entry on the path if longest is true, the first one otherwise.
+func (p *prefixTrie[V]) match(number rnet.NetworkNumber, longest bool) (Entry[V], bool, error) {
+	var matched *prefixTrie[V]
+	for node := p; node != nil && node.network.Contains(number); {
+		if node.hasEntry() {
+			matched = node
+			if !longest {
+				break
+			}
+		}
+		if node.targetBitPosition() < 0 {
+			break
+		}
+		bit, err := node.childIndex(number)
+		if err != nil {
+			return Entry[V]{}, false, err
+		}
+		node = node.children[bit]
+	}
+	if matched == nil {
+		return Entry[V]{}, false, nil
+	}
+	return matched.toEntry(), true, nil
+}
+
+func (p *prefixTrie[V]) coveredNetworks(network rnet.Network) ([]Entry[V], error) {
+	var results []Entry[V]
 	if network.Covers(p.network) {
-		for entry := range p.walkDepth() {
+		p.walk(func(entry Entry[V]) bool {
 			results = append(results, entry)
-		}
-	} else if p.targetBitPosition() >= 0 {
-		bit, err := p.targetBitFromIP(network.Number)
+			return true
+		})
+	} else if p.network.Covers(network) && p.targetBitPosition() >= 0 {
+		first, last, err := p.childRange(network)
 		if err != nil {
 			return results, err
 		}
-		child := p.children[bit]
-		if child != nil {
-			return child.coveredNetworks(network)
+		for _, child := range p.children[first : last+1] {
+			if child == nil {
+				continue
+			}
+			entries, err := child.coveredNetworks(network)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, entries...)
 		}
 	}
 	return results, nil
 }
 
-func (p *prefixTrie) insert(network rnet.Network, entry RangerEntry) (bool, error) {
+func (p *prefixTrie[V]) get(network rnet.Network) (V, bool, error) {
+	node, err := p.find(network)
+	if err != nil || node == nil {
+		var zero V
+		return zero, false, err
+	}
+	return node.value, true, nil
+}
+
+func (p *prefixTrie[V]) coveringNetworks(network rnet.Network) ([]Entry[V], error) {
+	var results []Entry[V]
+	for node := p; node != nil && node.network.Covers(network); {
+		if node.hasEntry() {
+			results = append(results, node.toEntry())
+		}
+		if node.targetBitPosition() < 0 {
+			break
+		}
+		bit, err := node.childIndex(network.Number)
+		if err != nil {
+			return nil, err
+		}
+		node = node.children[bit]
+	}
+	return results, nil
+}
+
+// overlappingNetworks walks down the path to network once, collecting the
+// covering entries on the way and then the entire subtree of the first node
+// covered by network.
+func (p *prefixTrie[V]) overlappingNetworks(network rnet.Network) ([]Entry[V], error) {
+	var results []Entry[V]
+	for node := p; node != nil; {
+		if network.Covers(node.network) {
+			node.walk(func(entry Entry[V]) bool {
+				results = append(results, entry)
+				return true
+			})
+			break
+		}
+		if !node.network.Covers(network) {
+			break
+		}
+		if node.hasEntry() {
+			results = append(results, node.toEntry())
+		}
+		if node.targetBitPosition() < 0 {
+			break
+		}
+		first, last, err := node.childRange(network)
+		if err != nil {
+			return nil, err
+		}
+		if first != last {
+			// Every child in range is covered by network.
+			for _, child := range node.children[first : last+1] {
+				if child != nil {
+					child.walk(func(entry Entry[V]) bool {
+						results = append(results, entry)
+						return true
+					})
+				}
+			}
+			break
+		}
+		node = node.children[first]
+	}
+	return results, nil
+}
+
+// next returns the first node with an entry in the subtree of p that sorts
+// after network.
+func (p *prefixTrie[V]) next(network rnet.Network) (*prefixTrie[V], error) {
+	if p.network.Compare(network) > 0 {
+		// Every network in the subtree sorts after the subtree root.
+		return p.first(), nil
+	}
+	if !p.network.Contains(network.Number) || p.targetBitPosition() < 0 {
+		return nil, nil
+	}
+	bit, err := p.childIndex(network.Number)
+	if err != nil {
+		return nil, err
+	}
+	if child := p.children[bit]; child != nil {
+		node, err := child.next(network)
+		if err != nil || node != nil {
+			return node, err
+		}
+	}
+	for _, child := range p.children[bit+1:] {
+		if child != nil {
+			if node := child.first(); node != nil {
+				return node, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// prev returns the last node with an entry in the subtree of p that sorts
+// before network.
+func (p *prefixTrie[V]) prev(network rnet.Network) (*prefixTrie[V], error) {
+	if p.network.Compare(network) >= 0 {
+		return nil, nil
+	}
+	if !p.network.Contains(network.Number) {
+		// The subtree lies entirely before network.
+		return p.last(), nil
+	}
+	if p.targetBitPosition() >= 0 {
+		bit, err := p.childIndex(network.Number)
+		if err != nil {
+			return nil, err
+		}
+		if child := p.children[bit]; child != nil {
+			node, err := child.prev(network)
+			if err != nil || node != nil {
+				return node, err
+			}
+		}
+		for i := int(bit) - 1; i >= 0; i-- {
+			if child := p.children[i]; child != nil {
+				if node := child.last(); node != nil {
+					return node, nil
+				}
+			}
+		}
+	}
+	if p.hasEntry() {
+		return p, nil
+	}
+	return nil, nil
+}
+
+// first returns the first node with an entry in the subtree of p.
+func (p *prefixTrie[V]) first() *prefixTrie[V] {
+	if p.hasEntry() {
+		return p
+	}
+	for _, child := range p.children {
+		if child != nil {
+			if node := child.first(); node != nil {
+				return node
+			}
+		}
+	}
+	return nil
+}
+
+// last returns the last node with an entry in the subtree of p.
+func (p *prefixTrie[V]) last() *prefixTrie[V] {
+	for i := len(p.children) - 1; i >= 0; i-- {
+		if child := p.children[i]; child != nil {
+			if node := child.last(); node != nil {
+				return node
+			}
+		}
+	}
+	if p.hasEntry() {
+		return p
+	}
+	return nil
+}
+
+// entriesBetween calls fn for every entry in the subtree of p overlapping the
+// address range from start to end, in address order.
+func (p *prefixTrie[V]) entriesBetween(start, end rnet.NetworkNumber, fn func(Entry[V])) {
+	// The subtree overlaps the range if it starts within the range, or if
+	// it starts before the range and contains its start.
+	number := p.network.Number
+	if number.Compare(end) > 0 || (number.Compare(start) < 0 && !p.network.Contains(start)) {
+		return
+	}
+	if p.hasEntry() {
+		fn(p.toEntry())
+	}
+	for _, child := range p.children {
+		if child != nil {
+			child.entriesBetween(start, end, fn)
+		}
+	}
+}
+
+func (p *prefixTrie[V]) insertNetwork(network rnet.Network, value V) error {
+	return p.upsertNetwork(network, func(V, bool) V {
+		return value
+	})
+}
+
+func (p *prefixTrie[V]) upsertNetwork(network rnet.Network, fn func(old V, exists bool) V) error {
+	sizeIncreased, err := p.insert(network, fn)
+	if sizeIncreased {
+		p.size++
+	}
+	if err != nil {
+		return err
+	}
+	return p.resizeAlong(network)
+}
+
+// insert stores the value returned by fn for network, fn is called with the
+// value currently stored for network, if any, once its position is found.
+func (p *prefixTrie[V]) insert(network rnet.Network, fn func(old V, exists bool) V) (bool, error) {
 	if p.network.Equal(network) {
-		sizeIncreased := p.entry == nil
-		p.entry = entry
+		sizeIncreased := !p.hasValue
+		p.setEntry(fn(p.value, p.hasValue), true)
 		return sizeIncreased, nil
 	}
 
-	bit, err := p.targetBitFromIP(network.Number)
+	// Entries are only stored at the prefix of a node, halve current trie
+	// until network falls below the bits it handles.
+	ones, _ := network.IPNet.Mask.Size()
+	for uint(ones) < p.numBitsSkipped+p.numBitsHandled && p.numBitsHandled > 1 {
+		p.halve()
+	}
+
+	bit, err := p.childIndex(network.Number)
 	if err != nil {
 		return false, err
 	}
@@ -232,7 +760,8 @@ func (p *prefixTrie) insert(network rnet.Network, entry RangerEntry) (bool, erro
 
 	// No existing child, insert new leaf trie.
 	if existingChild == nil {
-		p.appendTrie(bit, newEntryTrie(network, entry))
+		var zero V
+		p.setChild(bit, newEntryTrie(network, fn(zero, false)))
 		return true, nil
 	}
 
@@ -241,7 +770,7 @@ func (p *prefixTrie) insert(network rnet.Network, entry RangerEntry) (bool, erro
 	lcb, err := network.LeastCommonBitPosition(existingChild.network)
 	divergingBitPos := int(lcb) - 1
 	if divergingBitPos > existingChild.targetBitPosition() {
-		pathPrefix := newPathprefixTrie(network, p.totalNumberOfBits()-lcb)
+		pathPrefix := newPathprefixTrie[V](network, p.totalNumberOfBits()-lcb)
 		err := p.insertPrefix(bit, pathPrefix, existingChild)
 		if err != nil {
 			return false, err
@@ -249,55 +778,154 @@ func (p *prefixTrie) insert(network rnet.Network, entry RangerEntry) (bool, erro
 		// Update new child
 		existingChild = pathPrefix
 	}
-	return existingChild.insert(network, entry)
+	return existingChild.insert(network, fn)
 }
 
-func (p *prefixTrie) appendTrie(bit uint32, prefix *prefixTrie) {
-	p.children[bit] = prefix
-	prefix.parent = p
+// setChild sets the child at given index, which may be nil, keeping the
+// population of children up to date.
+func (p *prefixTrie[V]) setChild(bit uint32, child *prefixTrie[V]) {
+	if existing := p.children[bit]; existing != nil {
+		p.countChild(existing, -1)
+	}
+	p.children[bit] = child
+	if child != nil {
+		child.parent = p
+		p.countChild(child, 1)
+	}
 }
 
-func (p *prefixTrie) insertPrefix(bit uint32, pathPrefix, child *prefixTrie) error {
+func (p *prefixTrie[V]) countChild(child *prefixTrie[V], delta int) {
+	p.numChildren += delta
+	if child.numBitsSkipped == p.numBitsSkipped+p.numBitsHandled {
+		p.numExactChildren += delta
+		if !child.hasEntry() {
+			p.numFullChildren += delta
+		}
+	}
+}
+
+// setEntry sets the value of current trie and whether it has one, keeping
+// the population of children of its parent up to date.
+func (p *prefixTrie[V]) setEntry(value V, hasValue bool) {
+	if p.parent != nil {
+		p.parent.countChild(p, -1)
+	}
+	p.value = value
+	p.hasValue = hasValue
+	if p.parent != nil {
+		p.parent.countChild(p, 1)
+	}
+}
+
+func (p *prefixTrie[V]) insertPrefix(bit uint32, pathPrefix, child *prefixTrie[V]) error {
 	// Set parent/child relationship between current trie and inserted pathPrefix
-	p.children[bit] = pathPrefix
-	pathPrefix.parent = p
+	p.setChild(bit, pathPrefix)
 
 	// Set parent/child relationship between inserted pathPrefix and original child
-	pathPrefixBit, err := pathPrefix.targetBitFromIP(child.network.Number)
+	pathPrefixBit, err := pathPrefix.childIndex(child.network.Number)
 	if err != nil {
 		return err
 	}
-	pathPrefix.children[pathPrefixBit] = child
-	child.parent = pathPrefix
+	pathPrefix.setChild(pathPrefixBit, child)
 	return nil
 }
 
-func (p *prefixTrie) remove(network rnet.Network) (RangerEntry, error) {
-	if p.hasEntry() && p.network.Equal(network) {
-		entry := p.entry
-		p.entry = nil
+func (p *prefixTrie[V]) removeNetwork(network rnet.Network, fn func(V) bool) (V, bool, error) {
+	value, removed, err := p.remove(network, fn)
+	if removed {
+		p.size--
+	}
+	return value, removed, err
+}
 
-		err := p.compressPathIfPossible()
+// remove removes the value stored for network, if fn is not nil the value is
+// only removed when fn returns true for it.
+func (p *prefixTrie[V]) remove(network rnet.Network, fn func(V) bool) (V, bool, error) {
+	var zero V
+	node, err := p.find(network)
+	if err != nil || node == nil {
+		return zero, false, err
+	}
+	value := node.value
+	if fn != nil && !fn(value) {
+		return value, false, nil
+	}
+	node.setEntry(zero, false)
+
+	err = node.compressPathIfPossible()
+	if err != nil {
+		return zero, false, err
+	}
+	err = p.resizeAlong(network)
+	if err != nil {
+		return zero, false, err
+	}
+	return value, true, nil
+}
+
+// removeCovered walks down the path to network and detaches the children
+// covered by network from the last node covering it, along with their entire
+// subtrees.
+func (p *prefixTrie[V]) removeCovered(network rnet.Network) ([]Entry[V], error) {
+	var results []Entry[V]
+	collect := func(entry Entry[V]) bool {
+		results = append(results, entry)
+		return true
+	}
+	if network.Covers(p.network) {
+		p.walk(collect)
+		p.Clear()
+		return results, nil
+	}
+	for node := p; node.network.Covers(network) && node.targetBitPosition() >= 0; {
+		first, last, err := node.childRange(network)
 		if err != nil {
 			return nil, err
 		}
-		return entry, nil
+		if child := node.children[first]; first == last && child != nil && !network.Covers(child.network) {
+			node = child
+			continue
+		}
+		for i := first; i <= last; i++ {
+			if child := node.children[i]; child != nil && network.Covers(child.network) {
+				child.walk(collect)
+				node.setChild(i, nil)
+				child.parent = nil
+			}
+		}
+		if len(results) == 0 {
+			break
+		}
+		p.size -= len(results)
+		if err := node.compressPathIfPossible(); err != nil {
+			return nil, err
+		}
+		return results, p.resizeAlong(network)
+	}
+	return results, nil
+}
+
+// find returns the trie node holding an entry for exactly given network, or
+// nil if there is none.
+func (p *prefixTrie[V]) find(network rnet.Network) (*prefixTrie[V], error) {
+	if p.hasEntry() && p.network.Equal(network) {
+		return p, nil
 	}
 	if p.targetBitPosition() < 0 {
 		return nil, nil
 	}
-	bit, err := p.targetBitFromIP(network.Number)
+	bit, err := p.childIndex(network.Number)
 	if err != nil {
 		return nil, err
 	}
 	child := p.children[bit]
 	if child != nil {
-		return child.remove(network)
+		return child.find(network)
 	}
 	return nil, nil
 }
 
-func (p *prefixTrie) qualifiesForPathCompression() bool {
+func (p *prefixTrie[V]) qualifiesForPathCompression() bool {
 	// Current prefix trie can be path compressed if it meets all following.
 	//		1. records no CIDR entry
 	//		2. has single or no child
@@ -305,14 +933,14 @@ func (p *prefixTrie) qualifiesForPathCompression() bool {
 	return !p.hasEntry() && p.childrenCount() <= 1 && p.parent != nil
 }
 
-func (p *prefixTrie) compressPathIfPossible() error {
+func (p *prefixTrie[V]) compressPathIfPossible() error {
 	if !p.qualifiesForPathCompression() {
 		// Does not qualify to be compressed
 		return nil
 	}
 
 	// Find lone child.
-	var loneChild *prefixTrie
+	var loneChild *prefixTrie[V]
 	for _, child := range p.children {
 		if child != nil {
 			loneChild = child
@@ -324,72 +952,185 @@ func (p *prefixTrie) compressPathIfPossible() error {
 	parent := p.parent
 	for ; parent.qualifiesForPathCompression(); parent = parent.parent {
 	}
-	parentBit, err := parent.targetBitFromIP(p.network.Number)
+	parentBit, err := parent.childIndex(p.network.Number)
 	if err != nil {
 		return err
 	}
-	parent.children[parentBit] = loneChild
+	parent.setChild(parentBit, loneChild)
 
 	// Attempts to furthur apply path compression at current lineage parent, in case current lineage
 	// compressed into parent.
 	return parent.compressPathIfPossible()
 }
 
-func (p *prefixTrie) childrenCount() int {
-	count := 0
-	for _, child := range p.children {
-		if child != nil {
-			count++
-		}
-	}
-	return count
+func (p *prefixTrie[V]) childrenCount() int {
+	return p.numChildren
 }
 
-func (p *prefixTrie) totalNumberOfBits() uint {
+func (p *prefixTrie[V]) totalNumberOfBits() uint {
 	return rnet.BitsPerUint32 * uint(len(p.network.Number))
 }
 
-func (p *prefixTrie) targetBitPosition() int {
+func (p *prefixTrie[V]) targetBitPosition() int {
 	return int(p.totalNumberOfBits()-p.numBitsSkipped) - 1
 }
 
-func (p *prefixTrie) targetBitFromIP(n rnet.NetworkNumber) (uint32, error) {
+// childIndex returns the index of the child given number falls under, that is
+// the bits handled by current trie.
+func (p *prefixTrie[V]) childIndex(n rnet.NetworkNumber) (uint32, error) {
 	// This is a safe uint boxing of int since we should never attempt to get
 	// target bit at a negative position.
-	return n.Bit(uint(p.targetBitPosition()))
+	return n.Bits(uint(p.targetBitPosition()), p.numBitsHandled)
 }
 
-func (p *prefixTrie) hasEntry() bool {
-	return p.entry != nil
+// childRange returns the indices of the first and last children overlapping
+// given network, which current trie must cover.  It is a single child unless
+// network is shorter than the bits handled by current trie, in which case
+// every child in range is covered by network.
+func (p *prefixTrie[V]) childRange(network rnet.Network) (uint32, uint32, error) {
+	index, err := p.childIndex(network.Number)
+	if err != nil {
+		return 0, 0, err
+	}
+	ones, _ := network.IPNet.Mask.Size()
+	if end := p.numBitsSkipped + p.numBitsHandled; uint(ones) < end {
+		span := uint32(1)<<(end-uint(ones)) - 1
+		return index &^ span, index | span, nil
+	}
+	return index, index, nil
 }
 
-func (p *prefixTrie) level() int {
+// resizeAlong resizes every node on the path to given network, bottom up,
+// compressing the nodes left with a single child by halving.
+func (p *prefixTrie[V]) resizeAlong(network rnet.Network) error {
+	var path []*prefixTrie[V]
+	for node := p; node != nil && node.network.Contains(network.Number); {
+		path = append(path, node)
+		if node.targetBitPosition() < 0 {
+			break
+		}
+		bit, err := node.childIndex(network.Number)
+		if err != nil {
+			return err
+		}
+		node = node.children[bit]
+	}
+	for i := len(path) - 1; i >= 0; i-- {
+		path[i].resize()
+		if err := path[i].compressPathIfPossible(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resize inflates or halves current trie according to the level compression
+// thresholds.
+func (p *prefixTrie[V]) resize() {
+	for p.shouldInflate() {
+		p.inflate()
+	}
+	for p.shouldHalve() {
+		p.halve()
+	}
+}
+
+func (p *prefixTrie[V]) shouldInflate() bool {
+	// Handling one more bit must not go past the last bit, nor over a child
+	// holding an entry right where current trie ends.
+	if p.targetBitPosition() < int(p.numBitsHandled) || p.numExactChildren > p.numFullChildren ||
+		p.numBitsHandled >= maxBitsHandled {
+		return false
+	}
+	// Full children are split into halves when inflated, counting twice.
+	used := p.numChildren + p.numFullChildren
+	return 100*used >= inflateThreshold*2*len(p.children)
+}
+
+func (p *prefixTrie[V]) shouldHalve() bool {
+	return p.numBitsHandled > 1 && 100*p.childrenCount() < halveThreshold*len(p.children)
+}
+
+// inflate doubles the children of current trie by handling one more bit,
+// pulling up the bit handled by children that start right where current trie
+// ends.
+func (p *prefixTrie[V]) inflate() {
+	end := p.numBitsSkipped + p.numBitsHandled
+	children := make([]*prefixTrie[V], 2*len(p.children))
+	for i, child := range p.children {
+		if child == nil {
+			continue
+		}
+		if child.numBitsSkipped > end {
+			// Safe to ignore error, as the child is longer than the new bit.
+			bit, _ := child.network.Number.Bit(uint(p.targetBitPosition()) - p.numBitsHandled)
+			children[2*uint32(i)+bit] = child
+			continue
+		}
+		half := len(child.children) / 2
+		children[2*i] = newInnerTrie(child.children[:half], end+1, child.numBitsHandled-1)
+		children[2*i+1] = newInnerTrie(child.children[half:], end+1, child.numBitsHandled-1)
+	}
+	p.setChildren(children, p.numBitsHandled+1)
+}
+
+// halve halves the children of current trie by handling one bit less, pairs
+// of children are joined under a new node handling the dropped bit.
+func (p *prefixTrie[V]) halve() {
+	end := p.numBitsSkipped + p.numBitsHandled
+	children := make([]*prefixTrie[V], len(p.children)/2)
+	for i := range children {
+		children[i] = newInnerTrie(p.children[2*i:2*i+2], end-1, 1)
+	}
+	p.setChildren(children, p.numBitsHandled-1)
+}
+
+func (p *prefixTrie[V]) setChildren(children []*prefixTrie[V], numBitsHandled uint) {
+	p.numBitsHandled = numBitsHandled
+	p.children = make([]*prefixTrie[V], len(children))
+	p.numChildren, p.numExactChildren, p.numFullChildren = 0, 0, 0
+	for i, child := range children {
+		if child != nil {
+			p.setChild(uint32(i), child)
+		}
+	}
+}
+
+func (p *prefixTrie[V]) hasEntry() bool {
+	return p.hasValue
+}
+
+func (p *prefixTrie[V]) toEntry() Entry[V] {
+	return Entry[V]{Network: p.network.IPNet, Value: p.value}
+}
+
+func (p *prefixTrie[V]) level() int {
 	if p.parent == nil {
 		return 0
 	}
 	return p.parent.level() + 1
 }
 
-// walkDepth walks the trie in depth order, for unit testing.
-func (p *prefixTrie) walkDepth() <-chan RangerEntry {
-	entries := make(chan RangerEntry)
-	go func() {
-		if p.hasEntry() {
-			entries <- p.entry
-		}
-		childEntriesList := []<-chan RangerEntry{}
-		for _, trie := range p.children {
-			if trie == nil {
-				continue
-			}
-			childEntriesList = append(childEntriesList, trie.walkDepth())
+// walk calls fn for every entry in the trie in address order, parents before
+// children, stopping early and returning false if fn returns false.
+func (p *prefixTrie[V]) walk(fn func(Entry[V]) bool) bool {
+	if p.hasEntry() && !fn(p.toEntry()) {
+		return false
+	}
+	for _, child := range p.children {
+		if child != nil && !child.walk(fn) {
+			return false
 		}
-		for _, childEntries := range childEntriesList {
-			for entry := range childEntries {
-				entries <- entry
-			}
+	}
+	return true
+}
+
+// walkBackward is the reverse of walk, children before parents.
+func (p *prefixTrie[V]) walkBackward(fn func(Entry[V]) bool) bool {
+	for i := len(p.children) - 1; i >= 0; i-- {
+		if child := p.children[i]; child != nil && !child.walkBackward(fn) {
+			return false
 		}
-		close(entries)
-	}()
-	return entries
+	}
+	return !p.hasEntry() || fn(p.toEntry())
 }
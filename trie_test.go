@@ -72,41 +72,134 @@ func TestPrefixTrieInsert(t *testing.T) {
 	}
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
-			trie := newPrefixTree(tc.version).(*prefixTrie)
+			trie := newPrefixTree[RangerEntry](tc.version).(*prefixTrie[RangerEntry])
+			ranger := newEntryRanger(trie)
 			for _, insert := range tc.inserts {
 				_, network, _ := net.ParseCIDR(insert)
-				err := trie.Insert(NewBasicRangerEntry(*network))
+				err := ranger.Insert(NewBasicRangerEntry(*network))
 				assert.NoError(t, err)
 			}
 
-			assert.Equal(t, len(tc.expectedNetworksInDepthOrder), trie.Len(), "trie size should match")
+			assert.Equal(t, len(tc.expectedNetworksInDepthOrder), ranger.Len(), "trie size should match")
 
-			allNetworks, err := trie.CoveredNetworks(*getAllByVersion(tc.version))
+			allNetworks, err := ranger.CoveredNetworks(*getAllByVersion(tc.version))
 			assert.Nil(t, err)
-			assert.Equal(t, len(allNetworks), trie.Len(), "trie size should match")
+			assert.Equal(t, len(allNetworks), ranger.Len(), "trie size should match")
 
-			walk := trie.walkDepth()
+			var expected, walked []RangerEntry
 			for _, network := range tc.expectedNetworksInDepthOrder {
 				_, ipnet, _ := net.ParseCIDR(network)
-				expected := NewBasicRangerEntry(*ipnet)
-				actual := <-walk
-				assert.Equal(t, expected, actual)
+				expected = append(expected, NewBasicRangerEntry(*ipnet))
+			}
+			for entry := range trie.All() {
+				walked = append(walked, entry.Value)
 			}
+			// Also ensures no unexpected elements in trie.
+			assert.Equal(t, expected, walked)
+		})
+	}
+}
+
+func TestPrefixTrieWalk(t *testing.T) {
+	inserts := []string{"192.168.0.1/24", "192.168.1.1/24", "192.168.1.1/30", "10.0.0.0/8"}
+	trie := newEntryRanger(newPrefixTree[RangerEntry](rnet.IPv4))
+	for _, insert := range inserts {
+		_, network, _ := net.ParseCIDR(insert)
+		trie.Insert(NewBasicRangerEntry(*network))
+	}
+
+	var walked []string
+	trie.Walk(func(entry RangerEntry) bool {
+		network := entry.Network()
+		walked = append(walked, network.String())
+		return true
+	})
+	assert.Equal(t, []string{"10.0.0.0/8", "192.168.0.0/24", "192.168.1.0/24", "192.168.1.0/30"}, walked)
+
+	walked = nil
+	trie.Walk(func(entry RangerEntry) bool {
+		network := entry.Network()
+		walked = append(walked, network.String())
+		return len(walked) < 2
+	})
+	assert.Equal(t, []string{"10.0.0.0/8", "192.168.0.0/24"}, walked)
+
+	walked = nil
+	for entry := range trie.All() {
+		network := entry.Network()
+		walked = append(walked, network.String())
+		if len(walked) == 3 {
+			break
+		}
+	}
+	assert.Equal(t, []string{"10.0.0.0/8", "192.168.0.0/24", "192.168.1.0/24"}, walked)
+}
 
-			// Ensure no unexpected elements in trie.
-			for network := range walk {
-				assert.Nil(t, network)
+func TestPrefixTrieOrdered(t *testing.T) {
+	inserts := []string{"192.168.0.0/16", "192.168.1.0/24", "192.168.1.0/30", "192.168.2.0/24", "10.0.0.0/8"}
+	trie := newEntryRanger(newPrefixTree[RangerEntry](rnet.IPv4))
+	for _, insert := range inserts {
+		_, network, _ := net.ParseCIDR(insert)
+		trie.Insert(NewBasicRangerEntry(*network))
+	}
+
+	cases := []struct {
+		network string
+		next    string
+		prev    string
+		name    string
+	}{
+		{"192.168.1.0/24", "192.168.1.0/30", "192.168.0.0/16", "stored network"},
+		{"192.168.1.0/28", "192.168.1.0/30", "192.168.1.0/24", "between parent and child"},
+		{"192.168.1.128/25", "192.168.2.0/24", "192.168.1.0/30", "after last child"},
+		{"0.0.0.0/0", "10.0.0.0/8", "", "before first"},
+		{"192.168.2.0/24", "", "192.168.1.0/30", "last"},
+		{"172.16.0.0/12", "192.168.0.0/16", "10.0.0.0/8", "between subtrees"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, network, _ := net.ParseCIDR(tc.network)
+			for _, expected := range []struct {
+				network string
+				find    func(net.IPNet) (RangerEntry, bool, error)
+			}{{tc.next, trie.Next}, {tc.prev, trie.Prev}} {
+				entry, found, err := expected.find(*network)
+				assert.NoError(t, err)
+				if expected.network == "" {
+					assert.False(t, found)
+					continue
+				}
+				_, ipnet, _ := net.ParseCIDR(expected.network)
+				assert.True(t, found)
+				assert.Equal(t, NewBasicRangerEntry(*ipnet), entry)
 			}
 		})
 	}
+
+	var between []string
+	entries, err := trie.EntriesBetween(net.ParseIP("192.168.1.2"), net.ParseIP("192.168.2.0"))
+	assert.NoError(t, err)
+	for _, entry := range entries {
+		network := entry.Network()
+		between = append(between, network.String())
+	}
+	assert.Equal(t, []string{"192.168.0.0/16", "192.168.1.0/24", "192.168.1.0/30", "192.168.2.0/24"}, between)
+
+	var backward []string
+	for entry := range trie.Backward() {
+		network := entry.Network()
+		backward = append(backward, network.String())
+	}
+	assert.Equal(t, []string{"192.168.2.0/24", "192.168.1.0/30", "192.168.1.0/24", "192.168.0.0/16", "10.0.0.0/8"}, backward)
 }
 
 func TestPrefixTrieString(t *testing.T) {
 	inserts := []string{"192.168.0.1/24", "192.168.1.1/24", "192.168.1.1/30"}
-	trie := newPrefixTree(rnet.IPv4).(*prefixTrie)
+	trie := newPrefixTree[RangerEntry](rnet.IPv4).(*prefixTrie[RangerEntry])
+	ranger := newEntryRanger(trie)
 	for _, insert := range inserts {
 		_, network, _ := net.ParseCIDR(insert)
-		trie.Insert(NewBasicRangerEntry(*network))
+		ranger.Insert(NewBasicRangerEntry(*network))
 	}
 	expected := `0.0.0.0/0 (target_pos:31:has_entry:false)
 | 1--> 192.168.0.0/23 (target_pos:8:has_entry:false)
@@ -204,15 +297,16 @@ func TestPrefixTrieRemove(t *testing.T) {
 
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
-			trie := newPrefixTree(tc.version).(*prefixTrie)
+			trie := newPrefixTree[RangerEntry](tc.version).(*prefixTrie[RangerEntry])
+			ranger := newEntryRanger(trie)
 			for _, insert := range tc.inserts {
 				_, network, _ := net.ParseCIDR(insert)
-				err := trie.Insert(NewBasicRangerEntry(*network))
+				err := ranger.Insert(NewBasicRangerEntry(*network))
 				assert.NoError(t, err)
 			}
 			for i, remove := range tc.removes {
 				_, network, _ := net.ParseCIDR(remove)
-				removed, err := trie.Remove(*network)
+				removed, err := ranger.Remove(*network)
 				assert.NoError(t, err)
 				if str := tc.expectedRemoves[i]; str != "" {
 					_, ipnet, _ := net.ParseCIDR(str)
@@ -223,30 +317,254 @@ func TestPrefixTrieRemove(t *testing.T) {
 				}
 			}
 
-			assert.Equal(t, len(tc.expectedNetworksInDepthOrder), trie.Len(), "trie size should match after revmoval")
+			assert.Equal(t, len(tc.expectedNetworksInDepthOrder), ranger.Len(), "trie size should match after revmoval")
 
-			allNetworks, err := trie.CoveredNetworks(*getAllByVersion(tc.version))
+			allNetworks, err := ranger.CoveredNetworks(*getAllByVersion(tc.version))
 			assert.Nil(t, err)
-			assert.Equal(t, len(allNetworks), trie.Len(), "trie size should match")
+			assert.Equal(t, len(allNetworks), ranger.Len(), "trie size should match")
 
-			walk := trie.walkDepth()
+			var expected, walked []RangerEntry
 			for _, network := range tc.expectedNetworksInDepthOrder {
 				_, ipnet, _ := net.ParseCIDR(network)
-				expected := NewBasicRangerEntry(*ipnet)
-				actual := <-walk
-				assert.Equal(t, expected, actual)
+				expected = append(expected, NewBasicRangerEntry(*ipnet))
 			}
-
-			// Ensure no unexpected elements in trie.
-			for network := range walk {
-				assert.Nil(t, network)
+			for entry := range trie.All() {
+				walked = append(walked, entry.Value)
 			}
+			// Also ensures no unexpected elements in trie.
+			assert.Equal(t, expected, walked)
+
+			assert.Equal(t, tc.expectedTrieString, trie.String())
+		})
+	}
+}
+
+func TestPrefixTrieLevelCompression(t *testing.T) {
+	inflated := `0.0.0.0/0 (target_pos:31:has_entry:false)
+| 1--> 192.168.0.0/24 (target_pos:7:has_entry:false)
+| | 00--> 192.168.0.0/26 (target_pos:5:has_entry:true)
+| | 01--> 192.168.0.64/26 (target_pos:5:has_entry:true)
+| | 10--> 192.168.0.128/26 (target_pos:5:has_entry:true)
+| | 11--> 192.168.0.192/26 (target_pos:5:has_entry:true)`
+	halved := `0.0.0.0/0 (target_pos:31:has_entry:false)
+| 1--> 192.168.0.0/24 (target_pos:7:has_entry:false)
+| | 0--> 192.168.0.0/25 (target_pos:6:has_entry:true)
+| | | 0--> 192.168.0.0/26 (target_pos:5:has_entry:true)
+| | | 1--> 192.168.0.64/26 (target_pos:5:has_entry:true)
+| | 1--> 192.168.0.128/25 (target_pos:6:has_entry:false)
+| | | 0--> 192.168.0.128/26 (target_pos:5:has_entry:true)
+| | | 1--> 192.168.0.192/26 (target_pos:5:has_entry:true)`
+
+	trie := newPrefixTree[int](rnet.IPv4).(*prefixTrie[int])
+	for i, network := range []string{"192.168.0.0/26", "192.168.0.64/26", "192.168.0.128/26", "192.168.0.192/26"} {
+		assert.NoError(t, trie.Insert(*parseCIDRUnsafe(network), i))
+	}
+	assert.Equal(t, inflated, trie.String())
+	entry, found, err := trie.LongestMatch(net.ParseIP("192.168.0.130"))
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, 2, entry.Value)
+
+	// Inserting within the bits handled by a node halves it.
+	assert.NoError(t, trie.Insert(*parseCIDRUnsafe("192.168.0.0/25"), 4))
+	assert.Equal(t, halved, trie.String())
+
+	_, removed, err := trie.Remove(*parseCIDRUnsafe("192.168.0.0/25"))
+	assert.NoError(t, err)
+	assert.True(t, removed)
+	assert.Equal(t, inflated, trie.String())
+
+	for _, network := range []string{"192.168.0.64/26", "192.168.0.128/26", "192.168.0.192/26"} {
+		_, removed, err := trie.Remove(*parseCIDRUnsafe(network))
+		assert.NoError(t, err)
+		assert.True(t, removed)
+	}
+	assert.Equal(t, `0.0.0.0/0 (target_pos:31:has_entry:false)
+| 1--> 192.168.0.0/26 (target_pos:5:has_entry:true)`, trie.String())
+}
+
+func TestPrefixTrieNonCanonicalNetwork(t *testing.T) {
+	trie := newPrefixTree[int](rnet.IPv4).(*prefixTrie[int])
+	ranger, base := NewPCTrieRanger(), newBruteRanger()
+	for i, network := range []string{"10.0.0.0/8", "10.1.0.0/16", "10.1.2.0/24", "10.1.3.0/24", "10.2.0.0/16"} {
+		entry := NewBasicRangerEntry(*parseCIDRUnsafe(network))
+		assert.NoError(t, trie.Insert(entry.Network(), i))
+		assert.NoError(t, ranger.Insert(entry))
+		assert.NoError(t, base.Insert(entry))
+	}
+
+	// Host bits are cleared, replacing the entry of 10.1.0.0/16.
+	network := net.IPNet{IP: net.ParseIP("10.1.2.3").To4(), Mask: net.CIDRMask(16, 32)}
+	entry := NewBasicRangerEntry(network)
+	assert.NoError(t, trie.Insert(network, 5))
+	assert.NoError(t, ranger.Insert(entry))
+	assert.NoError(t, base.Insert(entry))
+	assert.Equal(t, 5, trie.Len())
+	value, found, err := trie.Get(*parseCIDRUnsafe("10.1.0.0/16"))
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, 5, value)
 
+	assert.Equal(t, base.Len(), ranger.Len())
+	for _, ip := range []string{"10.1.2.3", "10.1.3.1", "10.1.4.1", "10.2.0.1", "10.3.0.1", "11.0.0.1"} {
+		expected, err := base.ContainingNetworks(net.ParseIP(ip))
+		assert.NoError(t, err)
+		actual, err := ranger.ContainingNetworks(net.ParseIP(ip))
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, expected, actual, ip)
+	}
+	stored, found, err := ranger.Get(*parseCIDRUnsafe("10.1.0.0/16"))
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Same(t, entry, stored)
+}
+
+func TestPrefixTrieRemoveCovered(t *testing.T) {
+	cases := []struct {
+		inserts            []string
+		remove             string
+		expectedRemoved    []string
+		expectedTrieString string
+		name               string
+	}{
+		{
+			[]string{"192.168.0.0/16", "203.0.113.0/25", "203.0.113.128/25", "203.0.113.128/26"},
+			"203.0.113.0/24",
+			[]string{"203.0.113.0/25", "203.0.113.128/25", "203.0.113.128/26"},
+			`0.0.0.0/0 (target_pos:31:has_entry:false)
+| 1--> 192.168.0.0/16 (target_pos:15:has_entry:true)`,
+			"remove path prefix subtree and compress its parent",
+		},
+		{
+			[]string{"203.0.113.0/24", "203.0.113.0/25", "203.0.113.128/26"},
+			"203.0.113.128/25",
+			[]string{"203.0.113.128/26"},
+			`0.0.0.0/0 (target_pos:31:has_entry:false)
+| 1--> 203.0.113.0/24 (target_pos:7:has_entry:true)
+| | 0--> 203.0.113.0/25 (target_pos:6:has_entry:true)`,
+			"remove subtree smaller than path prefix",
+		},
+		{
+			[]string{"10.0.0.0/8", "203.0.113.0/24"},
+			"0.0.0.0/0",
+			[]string{"10.0.0.0/8", "203.0.113.0/24"},
+			`0.0.0.0/0 (target_pos:31:has_entry:false)`,
+			"remove all",
+		},
+		{
+			[]string{"10.0.0.0/8"},
+			"203.0.113.0/24",
+			nil,
+			`0.0.0.0/0 (target_pos:31:has_entry:false)
+| 0--> 10.0.0.0/8 (target_pos:23:has_entry:true)`,
+			"remove nothing",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			trie := newPrefixTree[int](rnet.IPv4).(*prefixTrie[int])
+			for i, insert := range tc.inserts {
+				assert.NoError(t, trie.Insert(*parseCIDRUnsafe(insert), i))
+			}
+			removed, err := trie.RemoveCovered(*parseCIDRUnsafe(tc.remove))
+			assert.NoError(t, err)
+			var removedNetworks []string
+			for _, entry := range removed {
+				removedNetworks = append(removedNetworks, entry.Network.String())
+			}
+			assert.Equal(t, tc.expectedRemoved, removedNetworks)
+			assert.Equal(t, len(tc.inserts)-len(tc.expectedRemoved), trie.Len())
 			assert.Equal(t, tc.expectedTrieString, trie.String())
 		})
 	}
 }
 
+func TestPrefixTrieRemoveIf(t *testing.T) {
+	trie := newPrefixTree[int](rnet.IPv4)
+	network := *parseCIDRUnsafe("192.168.0.0/24")
+	assert.NoError(t, trie.Insert(network, 1))
+
+	value, removed, err := trie.RemoveIf(network, func(value int) bool { return value == 2 })
+	assert.NoError(t, err)
+	assert.False(t, removed)
+	assert.Equal(t, 1, value)
+	assert.Equal(t, 1, trie.Len())
+
+	value, removed, err = trie.RemoveIf(network, func(value int) bool { return value == 1 })
+	assert.NoError(t, err)
+	assert.True(t, removed)
+	assert.Equal(t, 1, value)
+	assert.Equal(t, 0, trie.Len())
+}
+
+func TestPrefixTrieUpsert(t *testing.T) {
+	trie := newPrefixTree[int](rnet.IPv4)
+	counts := []string{"192.168.0.0/24", "192.168.0.0/16", "192.168.0.0/24", "192.168.1.0/24", "192.168.0.0/24"}
+	for _, network := range counts {
+		err := trie.Upsert(*parseCIDRUnsafe(network), func(old int, exists bool) int {
+			if exists {
+				return old + 1
+			}
+			return 1
+		})
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 3, trie.Len())
+	value, found, err := trie.Get(*parseCIDRUnsafe("192.168.0.0/24"))
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, 3, value)
+
+	previous, replaced, err := trie.Swap(*parseCIDRUnsafe("192.168.0.0/16"), 10)
+	assert.NoError(t, err)
+	assert.True(t, replaced)
+	assert.Equal(t, 1, previous)
+
+	previous, replaced, err = trie.Swap(*parseCIDRUnsafe("10.0.0.0/8"), 20)
+	assert.NoError(t, err)
+	assert.False(t, replaced)
+	assert.Equal(t, 0, previous)
+	assert.Equal(t, 4, trie.Len())
+}
+
+func TestPrefixTrieGet(t *testing.T) {
+	cases := []struct {
+		version rnet.IPVersion
+		inserts []string
+		get     string
+		found   bool
+		name    string
+	}{
+		{rnet.IPv4, []string{"192.168.0.0/24"}, "192.168.0.0/24", true, "basic get"},
+		{rnet.IPv4, []string{"192.168.0.0/24", "192.168.0.0/25"}, "192.168.0.0/24", true, "get with children"},
+		{rnet.IPv4, []string{"192.168.0.0/24", "192.168.1.0/24"}, "192.168.0.0/23", false, "path prefix without entry"},
+		{rnet.IPv4, []string{"192.168.0.0/24"}, "192.168.0.0/25", false, "covered but not inserted"},
+		{rnet.IPv4, []string{"192.168.0.0/25"}, "192.168.0.0/24", false, "covering but not inserted"},
+		{rnet.IPv6, []string{"8000::/96", "8000::/128"}, "8000::/128", true, "IPv6 get"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			trie := newEntryRanger(newPrefixTree[RangerEntry](tc.version))
+			for _, insert := range tc.inserts {
+				_, network, _ := net.ParseCIDR(insert)
+				err := trie.Insert(NewBasicRangerEntry(*network))
+				assert.NoError(t, err)
+			}
+			_, network, _ := net.ParseCIDR(tc.get)
+			entry, found, err := trie.Get(*network)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.found, found)
+			if tc.found {
+				assert.Equal(t, NewBasicRangerEntry(*network), entry)
+			} else {
+				assert.Nil(t, entry)
+			}
+			assert.Equal(t, len(tc.inserts), trie.Len())
+		})
+	}
+}
+
 func TestToReplicateIssue(t *testing.T) {
 	cases := []struct {
 		version  rnet.IPVersion
@@ -272,7 +590,7 @@ func TestToReplicateIssue(t *testing.T) {
 	}
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
-			trie := newPrefixTree(tc.version)
+			trie := newEntryRanger(newPrefixTree[RangerEntry](tc.version))
 			for _, insert := range tc.inserts {
 				_, network, _ := net.ParseCIDR(insert)
 				err := trie.Insert(NewBasicRangerEntry(*network))
@@ -326,7 +644,7 @@ func TestPrefixTrieContains(t *testing.T) {
 
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
-			trie := newPrefixTree(tc.version)
+			trie := newEntryRanger(newPrefixTree[RangerEntry](tc.version))
 			for _, insert := range tc.inserts {
 				_, network, _ := net.ParseCIDR(insert)
 				err := trie.Insert(NewBasicRangerEntry(*network))
@@ -379,7 +697,7 @@ func TestPrefixTrieContainingNetworks(t *testing.T) {
 	}
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
-			trie := newPrefixTree(tc.version)
+			trie := newEntryRanger(newPrefixTree[RangerEntry](tc.version))
 			for _, insert := range tc.inserts {
 				_, network, _ := net.ParseCIDR(insert)
 				err := trie.Insert(NewBasicRangerEntry(*network))
@@ -397,6 +715,84 @@ func TestPrefixTrieContainingNetworks(t *testing.T) {
 	}
 }
 
+func TestPrefixTrieMatch(t *testing.T) {
+	cases := []struct {
+		version  rnet.IPVersion
+		inserts  []string
+		ip       net.IP
+		longest  string
+		shortest string
+		name     string
+	}{
+		{
+			rnet.IPv4,
+			[]string{"192.168.0.0/16", "192.168.0.0/24", "192.168.0.0/25"},
+			net.ParseIP("192.168.0.1"),
+			"192.168.0.0/25",
+			"192.168.0.0/16",
+			"nested networks",
+		},
+		{
+			rnet.IPv4,
+			[]string{"192.168.0.0/16", "192.168.0.0/24", "192.168.0.0/25"},
+			net.ParseIP("192.168.0.200"),
+			"192.168.0.0/24",
+			"192.168.0.0/16",
+			"most specific not containing",
+		},
+		{
+			rnet.IPv4,
+			[]string{"0.0.0.0/0", "192.168.0.0/24"},
+			net.ParseIP("10.0.0.1"),
+			"0.0.0.0/0",
+			"0.0.0.0/0",
+			"root",
+		},
+		{
+			rnet.IPv4,
+			[]string{"192.168.0.0/24"},
+			net.ParseIP("10.0.0.1"),
+			"",
+			"",
+			"no match",
+		},
+		{
+			rnet.IPv6,
+			[]string{"8000::/16", "8000::/96", "8000::/128"},
+			net.ParseIP("8000::1"),
+			"8000::/96",
+			"8000::/16",
+			"IPv6",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			trie := newPrefixTree[RangerEntry](tc.version)
+			for _, insert := range tc.inserts {
+				_, network, _ := net.ParseCIDR(insert)
+				err := trie.Insert(*network, NewBasicRangerEntry(*network))
+				assert.NoError(t, err)
+			}
+			for _, expected := range []struct {
+				network string
+				match   func(net.IP) (Entry[RangerEntry], bool, error)
+			}{{tc.longest, trie.LongestMatch}, {tc.shortest, trie.ShortestMatch}} {
+				entry, found, err := expected.match(tc.ip)
+				assert.NoError(t, err)
+				if expected.network == "" {
+					assert.False(t, found)
+					assert.Nil(t, entry.Value)
+					continue
+				}
+				_, ipnet, _ := net.ParseCIDR(expected.network)
+				assert.True(t, found)
+				assert.Equal(t, *ipnet, entry.Network)
+				assert.Equal(t, NewBasicRangerEntry(*ipnet), entry.Value)
+			}
+		})
+	}
+}
+
 type coveredNetworkTest struct {
 	version  rnet.IPVersion
 	inserts  []string
@@ -472,7 +868,7 @@ var coveredNetworkTests = []coveredNetworkTest{
 func TestPrefixTrieCoveredNetworks(t *testing.T) {
 	for _, tc := range coveredNetworkTests {
 		t.Run(tc.name, func(t *testing.T) {
-			trie := newPrefixTree(tc.version)
+			trie := newEntryRanger(newPrefixTree[RangerEntry](tc.version))
 			for _, insert := range tc.inserts {
 				_, network, _ := net.ParseCIDR(insert)
 				err := trie.Insert(NewBasicRangerEntry(*network))
@@ -492,6 +888,113 @@ func TestPrefixTrieCoveredNetworks(t *testing.T) {
 	}
 }
 
+var coveringNetworkTests = []coveredNetworkTest{
+	{
+		rnet.IPv4,
+		[]string{"192.168.0.0/16"},
+		"192.168.1.0/24",
+		[]string{"192.168.0.0/16"},
+		"basic covering networks",
+	},
+	{
+		rnet.IPv4,
+		[]string{"192.168.0.0/16"},
+		"10.1.0.0/24",
+		nil,
+		"nothing",
+	},
+	{
+		rnet.IPv4,
+		[]string{"0.0.0.0/0", "192.168.0.0/16", "192.168.1.0/24", "192.168.1.0/25"},
+		"192.168.1.0/24",
+		[]string{"0.0.0.0/0", "192.168.0.0/16", "192.168.1.0/24"},
+		"multiple networks including equal",
+	},
+	{
+		rnet.IPv4,
+		[]string{"192.168.0.0/16", "192.168.0.0/24", "192.168.2.0/24"},
+		"192.168.1.0/24",
+		[]string{"192.168.0.0/16"},
+		"siblings not covering",
+	},
+	{
+		rnet.IPv6,
+		[]string{"8000::/16", "8000::/96", "8000::/128"},
+		"8000::/112",
+		[]string{"8000::/16", "8000::/96"},
+		"IPv6",
+	},
+}
+
+func TestPrefixTrieCoveringNetworks(t *testing.T) {
+	for _, tc := range coveringNetworkTests {
+		t.Run(tc.name, func(t *testing.T) {
+			trie := newEntryRanger(newPrefixTree[RangerEntry](tc.version))
+			for _, insert := range tc.inserts {
+				_, network, _ := net.ParseCIDR(insert)
+				err := trie.Insert(NewBasicRangerEntry(*network))
+				assert.NoError(t, err)
+			}
+			var expectedEntries []RangerEntry
+			for _, network := range tc.networks {
+				_, net, _ := net.ParseCIDR(network)
+				expectedEntries = append(expectedEntries,
+					NewBasicRangerEntry(*net))
+			}
+			_, snet, _ := net.ParseCIDR(tc.search)
+			networks, err := trie.CoveringNetworks(*snet)
+			assert.NoError(t, err)
+			assert.Equal(t, expectedEntries, networks)
+		})
+	}
+}
+
+func TestPrefixTrieOverlappingNetworks(t *testing.T) {
+	cases := []coveredNetworkTest{
+		{
+			rnet.IPv4,
+			[]string{"0.0.0.0/0", "192.168.0.0/16", "192.168.1.0/24", "192.168.1.0/25", "192.168.2.0/24"},
+			"192.168.1.0/24",
+			[]string{"0.0.0.0/0", "192.168.0.0/16", "192.168.1.0/24", "192.168.1.0/25"},
+			"covering, equal and covered",
+		},
+		{
+			rnet.IPv4,
+			[]string{"192.168.0.0/16", "192.168.1.0/25", "192.168.1.128/25", "192.168.2.0/24"},
+			"192.168.1.0/24",
+			[]string{"192.168.0.0/16", "192.168.1.0/25", "192.168.1.128/25"},
+			"covering and covered",
+		},
+		{
+			rnet.IPv4,
+			[]string{"192.168.0.0/24"},
+			"10.0.0.0/8",
+			nil,
+			"nothing",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			trie := newEntryRanger(newPrefixTree[RangerEntry](tc.version))
+			for _, insert := range tc.inserts {
+				_, network, _ := net.ParseCIDR(insert)
+				err := trie.Insert(NewBasicRangerEntry(*network))
+				assert.NoError(t, err)
+			}
+			var expectedEntries []RangerEntry
+			for _, network := range tc.networks {
+				_, net, _ := net.ParseCIDR(network)
+				expectedEntries = append(expectedEntries,
+					NewBasicRangerEntry(*net))
+			}
+			_, snet, _ := net.ParseCIDR(tc.search)
+			networks, err := trie.OverlappingNetworks(*snet)
+			assert.NoError(t, err)
+			assert.Equal(t, expectedEntries, networks)
+		})
+	}
+}
+
 func TestTrieMemUsage(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping memory test in `-short` mode")
@@ -503,7 +1006,7 @@ func TestTrieMemUsage(t *testing.T) {
 	// by threshold, picking 1% as sane number for detecting memory leak.
 	thresh := 1.01
 
-	trie := newPrefixTree(rnet.IPv4)
+	trie := newEntryRanger(newPrefixTree[RangerEntry](rnet.IPv4))
 
 	var baseLineHeap, totalHeapAllocOverRuns uint64
 	for i := 0; i < runs; i++ {
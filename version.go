@@ -1,43 +1,120 @@
 package cidranger
 
 import (
+	"iter"
 	"net"
+	"net/netip"
 
 	rnet "github.com/yl2chen/cidranger/net"
 )
 
-type rangerFactory func(rnet.IPVersion) Ranger
+type rangerFactory[V any] func(rnet.IPVersion) TypedRanger[V]
 
-type versionedRanger struct {
-	ipV4Ranger Ranger
-	ipV6Ranger Ranger
+type versionedRanger[V any] struct {
+	ipV4Ranger TypedRanger[V]
+	ipV6Ranger TypedRanger[V]
 }
 
-func newVersionedRanger(factory rangerFactory) Ranger {
-	return &versionedRanger{
+func newVersionedRanger[V any](factory rangerFactory[V]) TypedRanger[V] {
+	return &versionedRanger[V]{
 		ipV4Ranger: factory(rnet.IPv4),
 		ipV6Ranger: factory(rnet.IPv6),
 	}
 }
 
-func (v *versionedRanger) Insert(entry RangerEntry) error {
-	network := entry.Network()
+func (v *versionedRanger[V]) Insert(network net.IPNet, value V) error {
 	ranger, err := v.getRangerForIP(network.IP)
 	if err != nil {
 		return err
 	}
-	return ranger.Insert(entry)
+	return ranger.Insert(network, value)
 }
 
-func (v *versionedRanger) Remove(network net.IPNet) (RangerEntry, error) {
+func (v *versionedRanger[V]) InsertPrefix(prefix netip.Prefix, value V) error {
+	ranger, err := v.getRangerForPrefix(prefix)
+	if err != nil {
+		return err
+	}
+	return ranger.InsertPrefix(prefix, value)
+}
+
+func (v *versionedRanger[V]) Upsert(network net.IPNet, fn func(old V, exists bool) V) error {
 	ranger, err := v.getRangerForIP(network.IP)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	return ranger.Upsert(network, fn)
+}
+
+func (v *versionedRanger[V]) Swap(network net.IPNet, value V) (V, bool, error) {
+	ranger, err := v.getRangerForIP(network.IP)
+	if err != nil {
+		var zero V
+		return zero, false, err
+	}
+	return ranger.Swap(network, value)
+}
+
+func (v *versionedRanger[V]) Remove(network net.IPNet) (V, bool, error) {
+	ranger, err := v.getRangerForIP(network.IP)
+	if err != nil {
+		var zero V
+		return zero, false, err
 	}
 	return ranger.Remove(network)
 }
 
-func (v *versionedRanger) Contains(ip net.IP) (bool, error) {
+func (v *versionedRanger[V]) RemovePrefix(prefix netip.Prefix) (V, bool, error) {
+	ranger, err := v.getRangerForPrefix(prefix)
+	if err != nil {
+		var zero V
+		return zero, false, err
+	}
+	return ranger.RemovePrefix(prefix)
+}
+
+func (v *versionedRanger[V]) RemoveIf(network net.IPNet, fn func(value V) bool) (V, bool, error) {
+	ranger, err := v.getRangerForIP(network.IP)
+	if err != nil {
+		var zero V
+		return zero, false, err
+	}
+	return ranger.RemoveIf(network, fn)
+}
+
+func (v *versionedRanger[V]) RemoveCovered(network net.IPNet) ([]Entry[V], error) {
+	ranger, err := v.getRangerForIP(network.IP)
+	if err != nil {
+		return nil, err
+	}
+	return ranger.RemoveCovered(network)
+}
+
+// Clear removes every IPv4 and IPv6 entry.
+func (v *versionedRanger[V]) Clear() {
+	v.ipV4Ranger.Clear()
+	v.ipV6Ranger.Clear()
+}
+
+func (v *versionedRanger[V]) Get(network net.IPNet) (V, bool, error) {
+	ranger, err := v.getRangerForIP(network.IP)
+	if err != nil {
+		var zero V
+		return zero, false, err
+	}
+	return ranger.Get(network)
+}
+
+func (v *versionedRanger[V]) GetPrefix(prefix netip.Prefix) (V, bool, error) {
+	ranger, err := v.getRangerForPrefix(prefix)
+	if err != nil {
+		var zero V
+		return zero, false, err
+	}
+	return ranger.GetPrefix(prefix)
+}
+
+func (v *versionedRanger[V]) Contains(ip net.IP) (bool, error) {
 	ranger, err := v.getRangerForIP(ip)
 	if err != nil {
 		return false, err
@@ -45,7 +122,15 @@ func (v *versionedRanger) Contains(ip net.IP) (bool, error) {
 	return ranger.Contains(ip)
 }
 
-func (v *versionedRanger) ContainingNetworks(ip net.IP) ([]RangerEntry, error) {
+func (v *versionedRanger[V]) ContainsAddr(addr netip.Addr) (bool, error) {
+	ranger, err := v.getRangerForAddr(addr)
+	if err != nil {
+		return false, err
+	}
+	return ranger.ContainsAddr(addr)
+}
+
+func (v *versionedRanger[V]) ContainingNetworks(ip net.IP) ([]Entry[V], error) {
 	ranger, err := v.getRangerForIP(ip)
 	if err != nil {
 		return nil, err
@@ -53,7 +138,47 @@ func (v *versionedRanger) ContainingNetworks(ip net.IP) ([]RangerEntry, error) {
 	return ranger.ContainingNetworks(ip)
 }
 
-func (v *versionedRanger) CoveredNetworks(network net.IPNet) ([]RangerEntry, error) {
+func (v *versionedRanger[V]) ContainingPrefixes(addr netip.Addr) ([]Entry[V], error) {
+	ranger, err := v.getRangerForAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+	return ranger.ContainingPrefixes(addr)
+}
+
+func (v *versionedRanger[V]) LongestMatch(ip net.IP) (Entry[V], bool, error) {
+	ranger, err := v.getRangerForIP(ip)
+	if err != nil {
+		return Entry[V]{}, false, err
+	}
+	return ranger.LongestMatch(ip)
+}
+
+func (v *versionedRanger[V]) LongestMatchAddr(addr netip.Addr) (Entry[V], bool, error) {
+	ranger, err := v.getRangerForAddr(addr)
+	if err != nil {
+		return Entry[V]{}, false, err
+	}
+	return ranger.LongestMatchAddr(addr)
+}
+
+func (v *versionedRanger[V]) ShortestMatch(ip net.IP) (Entry[V], bool, error) {
+	ranger, err := v.getRangerForIP(ip)
+	if err != nil {
+		return Entry[V]{}, false, err
+	}
+	return ranger.ShortestMatch(ip)
+}
+
+func (v *versionedRanger[V]) ShortestMatchAddr(addr netip.Addr) (Entry[V], bool, error) {
+	ranger, err := v.getRangerForAddr(addr)
+	if err != nil {
+		return Entry[V]{}, false, err
+	}
+	return ranger.ShortestMatchAddr(addr)
+}
+
+func (v *versionedRanger[V]) CoveredNetworks(network net.IPNet) ([]Entry[V], error) {
 	ranger, err := v.getRangerForIP(network.IP)
 	if err != nil {
 		return nil, err
@@ -61,12 +186,131 @@ func (v *versionedRanger) CoveredNetworks(network net.IPNet) ([]RangerEntry, err
 	return ranger.CoveredNetworks(network)
 }
 
+func (v *versionedRanger[V]) CoveredPrefixes(prefix netip.Prefix) ([]Entry[V], error) {
+	ranger, err := v.getRangerForPrefix(prefix)
+	if err != nil {
+		return nil, err
+	}
+	return ranger.CoveredPrefixes(prefix)
+}
+
+func (v *versionedRanger[V]) CoveringNetworks(network net.IPNet) ([]Entry[V], error) {
+	ranger, err := v.getRangerForIP(network.IP)
+	if err != nil {
+		return nil, err
+	}
+	return ranger.CoveringNetworks(network)
+}
+
+func (v *versionedRanger[V]) CoveringPrefixes(prefix netip.Prefix) ([]Entry[V], error) {
+	ranger, err := v.getRangerForPrefix(prefix)
+	if err != nil {
+		return nil, err
+	}
+	return ranger.CoveringPrefixes(prefix)
+}
+
+func (v *versionedRanger[V]) OverlappingNetworks(network net.IPNet) ([]Entry[V], error) {
+	ranger, err := v.getRangerForIP(network.IP)
+	if err != nil {
+		return nil, err
+	}
+	return ranger.OverlappingNetworks(network)
+}
+
+func (v *versionedRanger[V]) OverlappingPrefixes(prefix netip.Prefix) ([]Entry[V], error) {
+	ranger, err := v.getRangerForPrefix(prefix)
+	if err != nil {
+		return nil, err
+	}
+	return ranger.OverlappingPrefixes(prefix)
+}
+
+// Walk calls fn for every IPv4 entry, then every IPv6 entry, in address order
+// until fn returns false.
+func (v *versionedRanger[V]) Walk(fn func(Entry[V]) bool) {
+	stopped := false
+	v.ipV4Ranger.Walk(func(entry Entry[V]) bool {
+		stopped = !fn(entry)
+		return !stopped
+	})
+	if !stopped {
+		v.ipV6Ranger.Walk(fn)
+	}
+}
+
+// All returns an iterator over every entry in the same order as Walk.
+func (v *versionedRanger[V]) All() iter.Seq[Entry[V]] {
+	return v.Walk
+}
+
+// Backward returns an iterator over every entry in the reverse order of Walk.
+func (v *versionedRanger[V]) Backward() iter.Seq[Entry[V]] {
+	return func(yield func(Entry[V]) bool) {
+		for entry := range v.ipV6Ranger.Backward() {
+			if !yield(entry) {
+				return
+			}
+		}
+		for entry := range v.ipV4Ranger.Backward() {
+			if !yield(entry) {
+				return
+			}
+		}
+	}
+}
+
+// Next returns the first entry after given network in the order of Walk,
+// continuing onto IPv6 entries after the last IPv4 entry.
+func (v *versionedRanger[V]) Next(network net.IPNet) (Entry[V], bool, error) {
+	ranger, err := v.getRangerForIP(network.IP)
+	if err != nil {
+		return Entry[V]{}, false, err
+	}
+	entry, found, err := ranger.Next(network)
+	if err != nil || found || ranger == v.ipV6Ranger {
+		return entry, found, err
+	}
+	for entry := range v.ipV6Ranger.All() {
+		return entry, true, nil
+	}
+	return Entry[V]{}, false, nil
+}
+
+// Prev returns the last entry before given network in the order of Walk,
+// continuing onto IPv4 entries before the first IPv6 entry.
+func (v *versionedRanger[V]) Prev(network net.IPNet) (Entry[V], bool, error) {
+	ranger, err := v.getRangerForIP(network.IP)
+	if err != nil {
+		return Entry[V]{}, false, err
+	}
+	entry, found, err := ranger.Prev(network)
+	if err != nil || found || ranger == v.ipV4Ranger {
+		return entry, found, err
+	}
+	for entry := range v.ipV4Ranger.Backward() {
+		return entry, true, nil
+	}
+	return Entry[V]{}, false, nil
+}
+
+func (v *versionedRanger[V]) EntriesBetween(start, end net.IP) ([]Entry[V], error) {
+	ranger, err := v.getRangerForIP(start)
+	if err != nil {
+		return nil, err
+	}
+	if endRanger, err := v.getRangerForIP(end); err != nil || endRanger != ranger {
+		return nil, ErrInvalidNetworkNumberInput
+	}
+	return ranger.EntriesBetween(start, end)
+}
+
 // Len returns number of networks in ranger.
-func (v *versionedRanger) Len() int {
+func (v *versionedRanger[V]) Len() int {
 	return v.ipV4Ranger.Len() + v.ipV6Ranger.Len()
 }
 
-func (v *versionedRanger) getRangerForIP(ip net.IP) (Ranger, error) {
+func (v *versionedRanger[V]) getRangerForIP(ip net.IP) (TypedRanger[V], error) {
 	if ip.To4() != nil {
 		return v.ipV4Ranger, nil
 	}
@@ -75,3 +319,21 @@ func (v *versionedRanger) getRangerForIP(ip net.IP) (Ranger, error) {
 	}
 	return nil, ErrInvalidNetworkNumberInput
 }
+
+func (v *versionedRanger[V]) getRangerForAddr(addr netip.Addr) (TypedRanger[V], error) {
+	addr = addr.Unmap()
+	if addr.Is4() {
+		return v.ipV4Ranger, nil
+	}
+	if addr.Is6() {
+		return v.ipV6Ranger, nil
+	}
+	return nil, ErrInvalidNetworkNumberInput
+}
+
+func (v *versionedRanger[V]) getRangerForPrefix(prefix netip.Prefix) (TypedRanger[V], error) {
+	if !prefix.IsValid() {
+		return nil, ErrInvalidNetworkInput
+	}
+	return v.getRangerForAddr(prefix.Masked().Addr())
+}